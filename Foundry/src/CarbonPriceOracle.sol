@@ -158,8 +158,8 @@ contract CarbonPriceOracle is FunctionsClient, ICarbonPriceOracle, Ownable {
      * 
      * JavaScript code description:
      * - Calls https://greentrace-api.onrender.com/api/carbon-price for carbon price
-     * - Validates response format and data type
-     * - Multiplies price by 1e8 to convert to 8-decimal precision
+     * - Validates response format: priceE8 must be the API's decimal string of the 8-decimal price
+     * - Converts it with BigInt, exact unlike rounding data.price * 1e8 in floating point
      * - Uses Functions.encodeUint256 to encode return data
      * 
      * Security considerations:
@@ -198,8 +198,8 @@ contract CarbonPriceOracle is FunctionsClient, ICarbonPriceOracle, Ownable {
                 "});",
                 "if (response.error) { throw Error('Request failed'); }",
                 "const data = response.data;",
-                "if (!data || typeof data.price !== 'number') { throw Error('Invalid response format'); }",
-                "return Functions.encodeUint256(Math.round(data.price * 1e8));"
+                "if (!data || typeof data.priceE8 !== 'string') { throw Error('Invalid response format'); }",
+                "return Functions.encodeUint256(BigInt(data.priceE8));"
             )
         );
         
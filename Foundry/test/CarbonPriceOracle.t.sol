@@ -54,6 +54,56 @@ contract CarbonPriceOracleTest is Test {
         assertTrue(true, "Permission test passed");
     }
     
+    /**
+     * @dev Test the Functions script returns the API's exact priceE8 instead of rounding a float
+     */
+    function testRequestCarbonPriceSource() public {
+        // Stand in for the Functions router so the request goes through
+        address router = address(0x123);
+        vm.etch(router, hex"00");
+        vm.mockCall(
+            router,
+            abi.encodeWithSelector(bytes4(keccak256("sendRequest(uint64,bytes,uint16,uint32,bytes32)"))),
+            abi.encode(bytes32("request_1"))
+        );
+
+        vm.recordLogs();
+        carbonPriceOracle.requestCarbonPrice();
+        Vm.Log[] memory logs = vm.getRecordedLogs();
+
+        string memory source;
+        for (uint i = 0; i < logs.length; i++) {
+            if (logs[i].topics[0] == keccak256("JavaScriptSourceBuilt(string)")) {
+                source = abi.decode(logs[i].data, (string));
+            }
+        }
+        assertTrue(bytes(source).length > 0, "Source should be emitted");
+        assertTrue(contains(source, "typeof data.priceE8 !== 'string'"), "Source should validate priceE8 as a string");
+        assertTrue(contains(source, "Functions.encodeUint256(BigInt(data.priceE8))"), "Source should encode priceE8 exactly");
+        assertFalse(contains(source, "data.price * 1e8"), "Source should not round a floating point price");
+    }
+
+    /**
+     * @dev Whether text contains part
+     */
+    function contains(string memory text, string memory part) internal pure returns (bool) {
+        bytes memory t = bytes(text);
+        bytes memory p = bytes(part);
+        for (uint i = 0; i + p.length <= t.length; i++) {
+            bool found = true;
+            for (uint j = 0; j < p.length; j++) {
+                if (t[i + j] != p[j]) {
+                    found = false;
+                    break;
+                }
+            }
+            if (found) {
+                return true;
+            }
+        }
+        return false;
+    }
+
     /**
      * @dev Test that unauthorized user requesting carbon price update fails
      */
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"backend/pkg/adapter"
+	"backend/pkg/decimal"
+	"backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// priceAdapter Chainlink External Adapter over the latest stored price
+var priceAdapter *adapter.Adapter
+
+// newPriceAdapter configure adapter from ADAPTER_FX_RATES ("USD:1.08,GBP:0.85") and ADAPTER_MAX_AGE_HOURS
+func newPriceAdapter() *adapter.Adapter {
+	rates := make(map[string]decimal.Decimal)
+	for _, pair := range strings.Split(getEnv("ADAPTER_FX_RATES", ""), ",") {
+		currency, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			continue
+		}
+		rate, err := decimal.Parse(value)
+		if err != nil || rate.Sign() <= 0 {
+			logger.ErrorLogger.Printf("Invalid adapter FX rate %q, skipping", pair)
+			continue
+		}
+		rates[strings.ToUpper(strings.TrimSpace(currency))] = rate
+	}
+	return &adapter.Adapter{
+		Rates:  rates,
+		MaxAge: time.Duration(getEnvInt("ADAPTER_MAX_AGE_HOURS", 0)) * time.Hour,
+	}
+}
+
+// adapterHandler POST /adapter, Chainlink External Adapter endpoint
+func adapterHandler(c *gin.Context) {
+	start := time.Now()
+	atomic.AddInt64(&apiCalls, 1)
+
+	var req adapter.Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, adapter.Response{
+			StatusCode: 400,
+			Status:     "errored",
+			Error:      &adapter.Error{Name: "AdapterInputError", Message: "Invalid request body: " + err.Error()},
+		})
+		return
+	}
+	logger.InfoLogger.Printf("Received adapter request: id=%s data=%v", req.ID, req.Data)
+
+	priceMutex.RLock()
+	latest := priceStorage.GetLatest()
+	priceMutex.RUnlock()
+
+	resp := priceAdapter.Handle(req, latest)
+	if resp.Error != nil {
+		logger.ErrorLogger.Printf("Adapter request %s failed: %s", req.ID, resp.Error.Message)
+		atomic.AddInt64(&errorCount, 1)
+	}
+	c.JSON(resp.StatusCode, resp)
+	recordLatency(time.Since(start))
+}
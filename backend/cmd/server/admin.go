@@ -0,0 +1,175 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"backend/pkg/audit"
+	"backend/pkg/auth"
+	"backend/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authentication and audit state
+var (
+	apiKeys        *auth.KeyStore
+	auditLog       *audit.Log
+	requireReadKey bool // AUTH_REQUIRE_READ, also protect read endpoints
+)
+
+// Gin context keys
+const (
+	contextAPIKey       = "apiKey"
+	contextAuditDetails = "auditDetails"
+)
+
+// newAuth load API keys from API_KEYS (hashed, static) and API_KEYS_FILE (managed), open the audit log
+func newAuth() {
+	static, err := auth.ParseStaticKeys(getEnv("API_KEYS", ""))
+	if err != nil {
+		logger.ErrorLogger.Fatalf("Invalid API_KEYS: %v", err)
+	}
+	apiKeys, err = auth.NewKeyStore(static, getEnv("API_KEYS_FILE", "data/api_keys.json"))
+	if err != nil {
+		logger.ErrorLogger.Fatalf("Failed to load API keys: %v", err)
+	}
+	if apiKeys.Empty() {
+		logger.ErrorLogger.Println("No API keys configured, mutating and admin endpoints are locked. Create one with: app keys generate <name> admin")
+	}
+
+	auditLog, err = audit.NewLog(getEnv("AUDIT_LOG_FILE", "data/audit.log"), 1000)
+	if err != nil {
+		logger.ErrorLogger.Fatalf("Failed to open audit log: %v", err)
+	}
+	requireReadKey = getEnv("AUTH_REQUIRE_READ", "false") == "true"
+}
+
+// requestKey API key from "Authorization: Bearer <key>" or "X-API-Key: <key>"
+func requestKey(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+	return c.GetHeader("X-API-Key")
+}
+
+// requireScope reject requests without a key granting scope
+func requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := apiKeys.Authenticate(requestKey(c))
+		if key == nil {
+			logger.ErrorLogger.Printf("Rejected unauthenticated %s %s from %s", c.Request.Method, c.Request.URL.Path, c.ClientIP())
+			c.AbortWithStatusJSON(401, gin.H{"error": "Missing or invalid API key"})
+			return
+		}
+		c.Set(contextAPIKey, key)
+		if !key.Allows(scope) {
+			logger.ErrorLogger.Printf("Key %s lacks scope %s for %s %s", key.ID, scope, c.Request.Method, c.Request.URL.Path)
+			c.AbortWithStatusJSON(403, gin.H{"error": "API key lacks scope " + scope})
+			return
+		}
+		c.Next()
+	}
+}
+
+// readAccess require the read scope only when AUTH_REQUIRE_READ is enabled
+func readAccess() gin.HandlerFunc {
+	check := requireScope(auth.ScopeRead)
+	return func(c *gin.Context) {
+		if requireReadKey {
+			check(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// auditCall record the call in the audit log once the handler has finished
+func auditCall() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		entry := audit.Entry{
+			Actor:  actor(c),
+			Action: c.Request.Method + " " + c.FullPath(),
+			Status: c.Writer.Status(),
+			IP:     c.ClientIP(),
+		}
+		if details, ok := c.Get(contextAuditDetails); ok {
+			entry.Details = details.(map[string]interface{})
+		}
+		auditLog.Record(entry)
+	}
+}
+
+// actor identity of the authenticated caller
+func actor(c *gin.Context) string {
+	if value, ok := c.Get(contextAPIKey); ok {
+		key := value.(*auth.Key)
+		return key.Name + " (" + key.ID + ")"
+	}
+	return "anonymous"
+}
+
+// auditDetail attach context to the audit entry of the current call
+func auditDetail(c *gin.Context, name string, value interface{}) {
+	details, ok := c.Get(contextAuditDetails)
+	if !ok {
+		details = make(map[string]interface{})
+		c.Set(contextAuditDetails, details)
+	}
+	details.(map[string]interface{})[name] = value
+}
+
+// registerAdminRoutes key management and audit trail under /admin, admin scope only
+func registerAdminRoutes(r *gin.Engine) *gin.RouterGroup {
+	// Audit first so rejected calls are recorded too
+	admin := r.Group("/admin", auditCall(), requireScope(auth.ScopeAdmin))
+
+	admin.GET("/keys", func(c *gin.Context) {
+		c.JSON(200, apiKeys.List())
+	})
+
+	admin.POST("/keys", func(c *gin.Context) {
+		var body struct {
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+		plaintext, key, err := apiKeys.Create(body.Name, body.Scopes)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		auditDetail(c, "keyId", key.ID)
+		auditDetail(c, "name", key.Name)
+		auditDetail(c, "scopes", key.Scopes)
+		c.JSON(201, gin.H{
+			"key":     plaintext,
+			"info":    key,
+			"message": "Store this key now, it cannot be shown again",
+		})
+	})
+
+	admin.DELETE("/keys/:id", func(c *gin.Context) {
+		id := c.Param("id")
+		auditDetail(c, "keyId", id)
+		switch err := apiKeys.Revoke(id); err {
+		case nil:
+			c.JSON(200, gin.H{"message": "Key revoked", "id": id})
+		case auth.ErrUnknownKey:
+			c.JSON(404, gin.H{"error": err.Error()})
+		default:
+			c.JSON(400, gin.H{"error": err.Error()})
+		}
+	})
+
+	admin.GET("/audit", func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+		c.JSON(200, auditLog.Recent(limit))
+	})
+
+	return admin
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"backend/pkg/attest"
+	"backend/pkg/logger"
+	"backend/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// priceSigner signs served prices, nil when no signing key is configured
+var priceSigner *attest.Signer
+
+// signedPrice price with its attestation, price fields stay at the top level for existing clients
+type signedPrice struct {
+	priceInfoV2
+	Attestation *attest.Attestation `json:"attestation,omitempty"`
+}
+
+// newPriceSigner load signing keys from SIGNER_KEYS_FILE (rotatable) or SIGNER_PRIVATE_KEY
+func newPriceSigner() *attest.Signer {
+	domain := attest.Domain{
+		Name:              getEnv("SIGNER_DOMAIN_NAME", "GreenTrace Carbon Price"),
+		Version:           getEnv("SIGNER_DOMAIN_VERSION", "1"),
+		ChainID:           int64(getEnvInt("SIGNER_CHAIN_ID", 11155111)),
+		VerifyingContract: os.Getenv("SIGNER_VERIFYING_CONTRACT"),
+	}
+
+	var configs []attest.KeyConfig
+	keysFile := os.Getenv("SIGNER_KEYS_FILE")
+	switch {
+	case keysFile != "":
+		var err error
+		if configs, err = attest.LoadKeysFile(keysFile); err != nil {
+			logger.ErrorLogger.Fatalf("Failed to load signer keys: %v", err)
+		}
+	case os.Getenv("SIGNER_PRIVATE_KEY") != "":
+		configs = []attest.KeyConfig{{ID: os.Getenv("SIGNER_KEY_ID"), PrivateKey: os.Getenv("SIGNER_PRIVATE_KEY")}}
+	default:
+		logger.InfoLogger.Println("No signer key configured, prices are served unsigned")
+		return nil
+	}
+
+	signer, err := attest.NewSigner(domain, configs)
+	if err != nil {
+		logger.ErrorLogger.Fatalf("Failed to initialize price signer: %v", err)
+	}
+	for _, key := range signer.Keys() {
+		logger.InfoLogger.Printf("Signer key %s: address=%s active=%v", key.ID, key.Address, key.Active)
+	}
+
+	// Rotate by editing the keys file: add the new key as active and keep the old one as retired
+	if keysFile != "" {
+		go signer.WatchKeysFile(keysFile, time.Duration(getEnvInt("SIGNER_RELOAD_SECONDS", 30))*time.Second, nil, func(err error) {
+			if err != nil {
+				logger.ErrorLogger.Printf("Failed to reload signer keys, keeping previous keys: %v", err)
+				return
+			}
+			logger.InfoLogger.Printf("Signer keys reloaded: %d key(s)", len(signer.Keys()))
+		})
+	}
+	return signer
+}
+
+// signPrice price in the representation version, with an attestation when signing is enabled
+func signPrice(priceInfo *types.PriceInfo, version int) interface{} {
+	if priceInfo == nil {
+		return nil
+	}
+	var attestation *attest.Attestation
+	if priceSigner != nil {
+		var err error
+		if attestation, err = priceSigner.Sign(*priceInfo); err != nil {
+			logger.ErrorLogger.Printf("Failed to sign price: %v", err)
+		}
+	}
+	if version == apiVersionLegacy {
+		return signedPriceV1{priceInfoV1: toV1(*priceInfo), Attestation: attestation}
+	}
+	return signedPrice{priceInfoV2: toV2(*priceInfo), Attestation: attestation}
+}
+
+// signersHandler GET /api/carbon-price/signers, public key discovery for verifiers
+func signersHandler(c *gin.Context) {
+	if priceSigner == nil {
+		c.JSON(404, gin.H{"error": "Price signing is not enabled"})
+		return
+	}
+	keys := make([]gin.H, 0)
+	for _, key := range priceSigner.Keys() {
+		keys = append(keys, gin.H{
+			"id":        key.ID,
+			"address":   key.Address,
+			"publicKey": key.PublicKey(),
+			"active":    key.Active,
+		})
+	}
+	c.JSON(200, gin.H{
+		"domain":      priceSigner.Domain(),
+		"primaryType": "CarbonPrice",
+		"type":        attest.PriceType,
+		"decimals":    attest.PriceDecimals,
+		"keys":        keys,
+	})
+}
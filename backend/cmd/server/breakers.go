@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// registerBreakerRoutes inspect and override the per-source circuit breakers
+func registerBreakerRoutes(admin *gin.RouterGroup) {
+	admin.GET("/breakers", func(c *gin.Context) {
+		c.JSON(200, priceSources.BreakerStats())
+	})
+
+	// Body {"state": "open" | "closed" | "auto"}, auto hands the breaker back to automatic operation
+	admin.POST("/breakers/:source", func(c *gin.Context) {
+		source := c.Param("source")
+		var body struct {
+			State string `json:"state"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+		auditDetail(c, "source", source)
+		auditDetail(c, "state", body.State)
+
+		breaker := priceSources.Breaker(source)
+		if breaker == nil {
+			c.JSON(404, gin.H{"error": "Unknown price source " + source})
+			return
+		}
+		if err := breaker.Force(body.State); err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, breaker.Stats())
+	})
+}
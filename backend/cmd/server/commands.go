@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"backend/pkg/archive"
+	"backend/pkg/auth"
+	"backend/pkg/storage"
+)
+
+// runCommand run a CLI subcommand instead of the server, returns the process exit code
+func runCommand(args []string) int {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(args[1:])
+	case "keys":
+		return runKeys(args[1:])
+	case "reparse":
+		return runReparseCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", args[0])
+		fmt.Fprintln(os.Stderr, "Usage: app [migrate [status] | keys generate <name> <scope|scope> | keys hash <key> | reparse [flags]]")
+		return 2
+	}
+}
+
+// runKeys generate an API key with its API_KEYS entry, or hash an existing key
+func runKeys(args []string) int {
+	switch {
+	case len(args) == 3 && args[0] == "generate":
+		scopes := strings.Split(args[2], "|")
+		if err := auth.ValidateScopes(scopes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		plaintext, err := auth.GenerateKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to generate key: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Key (give to the client, not stored anywhere): %s\n", plaintext)
+		fmt.Printf("API_KEYS entry: %s:%s:%s\n", args[1], args[2], auth.HashKey(plaintext))
+		return 0
+	case len(args) == 2 && args[0] == "hash":
+		fmt.Println(auth.HashKey(args[1]))
+		return 0
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: app keys generate <name> <scope|scope> | app keys hash <key>")
+		return 2
+	}
+}
+
+// runMigrate apply pending schema migrations, or list them with "status"
+func runMigrate(args []string) int {
+	backend := getEnv("STORAGE_BACKEND", storage.BackendMemory)
+	if backend != storage.BackendSQLite && backend != storage.BackendPostgres {
+		fmt.Fprintf(os.Stderr, "Migrations require STORAGE_BACKEND=sqlite or postgres, got %q\n", backend)
+		return 1
+	}
+	db, err := storage.OpenSQL(backend, getEnv("STORAGE_PATH", "data/prices.db"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	if len(args) == 0 || args[0] != "status" {
+		applied, err := storage.Migrate(db, backend)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Applied %d migration(s): %v\n", len(applied), applied)
+	}
+
+	status, err := storage.MigrationsStatus(db)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read migration status: %v\n", err)
+		return 1
+	}
+	for _, s := range status {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%3d  %-24s %s\n", s.Version, s.Name, appliedAt)
+	}
+	return 0
+}
+
+// runReparseCommand reparse archived pages into the configured storage. Bolt files are locked
+// by a running server, use POST /admin/reparse there instead.
+func runReparseCommand(args []string) int {
+	flags := flag.NewFlagSet("reparse", flag.ContinueOnError)
+	apply := flags.Bool("apply", false, "write backfills and corrections, otherwise only report them")
+	source := flags.String("source", "", "only pages of this source")
+	from := flags.String("from", "", "first fetch day, YYYY-MM-DD")
+	to := flags.String("to", "", "last fetch day, YYYY-MM-DD")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	filter := archive.Filter{Source: *source}
+	var err error
+	if filter.From, err = parseDateParam(*from); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -from: %v\n", err)
+		return 2
+	}
+	if filter.To, err = parseDateParam(*to); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -to: %v\n", err)
+		return 2
+	}
+
+	pages := newPageArchive()
+	if pages == nil {
+		fmt.Fprintln(os.Stderr, "Page archive is disabled, set ARCHIVE_DIR")
+		return 1
+	}
+	store, err := storage.New(getEnv("STORAGE_BACKEND", storage.BackendMemory), getEnv("STORAGE_PATH", "data/prices.db"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open storage: %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	results, err := runReparse(pages, newPriceSources(), store, filter, *apply)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, r := range results {
+		fmt.Printf("%-45s %-16s %-18s %10.2f %10.2f  %-10s %s\n", r.PageID, r.Source, r.Date, r.Price, r.StoredPrice, r.Action, r.Error)
+	}
+	fmt.Printf("Summary: %v (applied: %t)\n", summarizeReparse(results), *apply)
+	return 0
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/pkg/cassette"
+	"backend/pkg/consensus"
+	"backend/pkg/crawler"
+	"backend/pkg/logger"
+	"backend/pkg/types"
+	"backend/pkg/validation"
+)
+
+// getEnv read environment variable with fallback
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvInt read integer environment variable with fallback
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		logger.ErrorLogger.Printf("Invalid %s=%q, using %d", key, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// getEnvFloat read float environment variable with fallback
+func getEnvFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		logger.ErrorLogger.Printf("Invalid %s=%q, using %g", key, value, fallback)
+		return fallback
+	}
+	return f
+}
+
+// getEnvDate read a YYYY-MM-DD environment variable, zero when unset or invalid
+func getEnvDate(key string) time.Time {
+	value := os.Getenv(key)
+	if value == "" {
+		return time.Time{}
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		logger.ErrorLogger.Printf("Invalid %s=%q, using the default", key, value)
+	}
+	return date
+}
+
+// hasSyntheticSource whether a synthetic source is registered, API responses are then labelled
+func hasSyntheticSource() bool {
+	for _, source := range priceSources.Sources() {
+		if _, ok := source.(*crawler.SyntheticSource); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// syntheticTick shortest synthetic tick when every source is synthetic, 0 otherwise
+func syntheticTick() time.Duration {
+	var tick time.Duration
+	for _, source := range priceSources.Sources() {
+		synthetic, ok := source.(*crawler.SyntheticSource)
+		if !ok {
+			return 0
+		}
+		if tick == 0 || synthetic.Tick() < tick {
+			tick = synthetic.Tick()
+		}
+	}
+	return tick
+}
+
+// newConsensusConfig build consensus settings from CONSENSUS_* variables
+func newConsensusConfig() consensus.Config {
+	config := consensus.DefaultConfig()
+	config.Method = getEnv("CONSENSUS_METHOD", config.Method)
+	config.Tolerance = getEnvFloat("CONSENSUS_TOLERANCE_PCT", config.Tolerance)
+	config.TrimFraction = getEnvFloat("CONSENSUS_TRIM_FRACTION", config.TrimFraction)
+	return config
+}
+
+// newValidationConfig build price validation thresholds from VALIDATION_* variables, 0 disables a check
+func newValidationConfig() validation.Config {
+	config := validation.DefaultConfig()
+	config.MinPrice = getEnvFloat("VALIDATION_MIN_PRICE", config.MinPrice)
+	config.MaxPrice = getEnvFloat("VALIDATION_MAX_PRICE", config.MaxPrice)
+	config.MaxJumpPercent = getEnvFloat("VALIDATION_MAX_JUMP_PCT", config.MaxJumpPercent)
+	config.ChangeTolerance = getEnvFloat("VALIDATION_CHANGE_TOLERANCE_PCT", config.ChangeTolerance)
+	config.MaxGapDays = getEnvInt("VALIDATION_MAX_GAP_DAYS", config.MaxGapDays)
+	return config
+}
+
+// newPriceSources build the source registry from PRICE_SOURCES (comma separated, in priority order)
+// and the scraper definitions of SCRAPERS_DIR
+func newPriceSources() *crawler.Registry {
+	registry := crawler.NewRegistry()
+	for _, name := range strings.Split(getEnv("PRICE_SOURCES", "tradingeconomics"), ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "tradingeconomics":
+			registry.Register(crawler.NewCarbonCrawler().WithSession(newTradingEconomicsSession()))
+		case "eex", "ice":
+			prefix := strings.ToUpper(name) + "_"
+			registry.Register(crawler.NewSettlementSource(crawler.SettlementConfig{
+				Name:             name,
+				URL:              os.Getenv(prefix + "URL"),
+				RowSelector:      os.Getenv(prefix + "ROW_SELECTOR"),
+				Product:          os.Getenv(prefix + "PRODUCT"),
+				DateColumn:       getEnvInt(prefix+"DATE_COLUMN", 0),
+				SettlementColumn: getEnvInt(prefix+"SETTLEMENT_COLUMN", 1),
+				DateLayout:       os.Getenv(prefix + "DATE_LAYOUT"),
+			}))
+		case "jsonapi":
+			if os.Getenv("JSON_SOURCE_URL") == "" {
+				logger.ErrorLogger.Println("Source jsonapi requires JSON_SOURCE_URL, skipping")
+				continue
+			}
+			registry.Register(crawler.NewJSONSource(crawler.JSONConfig{
+				URL:        os.Getenv("JSON_SOURCE_URL"),
+				Headers:    parseHeaders(os.Getenv("JSON_SOURCE_HEADERS")),
+				PriceField: os.Getenv("JSON_SOURCE_PRICE_FIELD"),
+				DateField:  os.Getenv("JSON_SOURCE_DATE_FIELD"),
+				DateLayout: os.Getenv("JSON_SOURCE_DATE_LAYOUT"),
+			}))
+		case "synthetic":
+			source, err := crawler.NewSyntheticSource(crawler.SyntheticConfig{
+				Model:      os.Getenv("SYNTHETIC_MODEL"),
+				Initial:    getEnvFloat("SYNTHETIC_PRICE", 0),
+				Drift:      getEnvFloat("SYNTHETIC_DRIFT", 0),
+				Volatility: getEnvFloat("SYNTHETIC_VOLATILITY", 0.4),
+				Mean:       getEnvFloat("SYNTHETIC_MEAN", 0),
+				Reversion:  getEnvFloat("SYNTHETIC_REVERSION", 0),
+				Seed:       int64(getEnvInt("SYNTHETIC_SEED", 1)),
+				Tick:       time.Duration(getEnvInt("SYNTHETIC_TICK_SECONDS", 300)) * time.Second,
+				Start:      getEnvDate("SYNTHETIC_START"),
+			})
+			if err != nil {
+				logger.ErrorLogger.Printf("Source synthetic: %v, skipping", err)
+				continue
+			}
+			logger.InfoLogger.Println("Synthetic price source enabled, prices are not market data")
+			registry.Register(source)
+		case "":
+		default:
+			logger.ErrorLogger.Printf("Unknown price source %q, skipping", name)
+		}
+	}
+	for _, name := range registry.Names() {
+		configureSource(registry, name)
+	}
+
+	// Declarative scrapers come after the configured sources, or replace the one of the same name
+	scrapers = newScraperSet(getEnv("SCRAPERS_DIR", "scrapers"), registry)
+	if err := scrapers.reload(); err != nil {
+		logger.ErrorLogger.Printf("Failed to load scraper definitions: %v", err)
+	}
+	logger.InfoLogger.Printf("Price sources: %v", registry.Names())
+	return registry
+}
+
+// configureSource set the retry policy and circuit breaker of a source from the environment
+func configureSource(registry *crawler.Registry, name string) {
+	registry.SetPolicy(name, newRetryPolicy(name))
+	registry.SetBreakerConfig(name, newBreakerConfig(name))
+}
+
+// newCrawlerTransport route crawler requests through cassettes when CASSETTE_MODE is record or replay
+func newCrawlerTransport() {
+	mode, err := cassette.ParseMode(getEnv("CASSETTE_MODE", "off"))
+	if err != nil {
+		logger.ErrorLogger.Fatalf("Invalid CASSETTE_MODE: %v", err)
+	}
+	if mode == cassette.ModeOff {
+		return
+	}
+	dir := getEnv("CASSETTE_DIR", "data/cassettes")
+	recorder, err := cassette.New(dir, mode, nil)
+	if err != nil {
+		logger.ErrorLogger.Fatalf("Failed to open cassettes: %v", err)
+	}
+	crawler.SetTransport(recorder)
+	logger.InfoLogger.Printf("Crawler requests are in %s mode, cassettes in %s", mode, dir)
+}
+
+// setExchangeTimezone count trading dates in EXCHANGE_TIMEZONE, an unknown zone would misdate every record
+func setExchangeTimezone() {
+	name := getEnv("EXCHANGE_TIMEZONE", "Europe/Berlin")
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		logger.ErrorLogger.Fatalf("Invalid EXCHANGE_TIMEZONE %q: %v", name, err)
+	}
+	types.ExchangeLocation = location
+}
+
+// newBreakerConfig circuit breaker of a source from <SOURCE>_BREAKER_* variables, falling back to the global BREAKER_* ones
+func newBreakerConfig(source string) crawler.BreakerConfig {
+	config := crawler.DefaultBreakerConfig()
+	prefix := sourceEnvPrefix(source)
+	config.FailureThreshold = getEnvInt(prefix+"BREAKER_FAILURES", getEnvInt("BREAKER_FAILURES", config.FailureThreshold))
+	openSeconds := getEnvInt(prefix+"BREAKER_OPEN_SECONDS", getEnvInt("BREAKER_OPEN_SECONDS", int(config.OpenTimeout/time.Second)))
+	config.OpenTimeout = time.Duration(openSeconds) * time.Second
+	return config
+}
+
+// sourceEnvPrefix environment variable prefix of per-source settings, e.g. "TRADINGECONOMICS_"
+func sourceEnvPrefix(source string) string {
+	return strings.ToUpper(strings.ReplaceAll(source, "-", "_")) + "_"
+}
+
+// newRetryPolicy retry policy of a source from <SOURCE>_RETRY_* variables, falling back to the global RETRY_* ones
+func newRetryPolicy(source string) crawler.RetryPolicy {
+	policy := crawler.DefaultRetryPolicy()
+	prefix := sourceEnvPrefix(source)
+	setting := func(name string, fallback int) int {
+		return getEnvInt(prefix+name, getEnvInt(name, fallback))
+	}
+	policy.MaxAttempts = setting("RETRY_ATTEMPTS", policy.MaxAttempts)
+	policy.Timeout = time.Duration(setting("RETRY_TIMEOUT_SECONDS", int(policy.Timeout/time.Second))) * time.Second
+	policy.InitialBackoff = time.Duration(setting("RETRY_BACKOFF_MS", int(policy.InitialBackoff/time.Millisecond))) * time.Millisecond
+	policy.MaxBackoff = time.Duration(setting("RETRY_MAX_BACKOFF_MS", int(policy.MaxBackoff/time.Millisecond))) * time.Millisecond
+	policy.Jitter = getEnvFloat(prefix+"RETRY_JITTER", getEnvFloat("RETRY_JITTER", policy.Jitter))
+	return policy
+}
+
+// newTradingEconomicsSession upstream session from TE_COOKIE, TE_USERNAME and TE_PASSWORD,
+// or TE_CREDENTIALS_FILE whose non-empty fields take precedence and which is re-read on every re-login
+func newTradingEconomicsSession() *crawler.Session {
+	path := os.Getenv("TE_CREDENTIALS_FILE")
+	credentials := func() (crawler.Credentials, error) {
+		creds := crawler.Credentials{
+			Cookie:   os.Getenv("TE_COOKIE"),
+			Username: os.Getenv("TE_USERNAME"),
+			Password: os.Getenv("TE_PASSWORD"),
+		}
+		if path == "" {
+			return creds, nil
+		}
+		file, err := crawler.LoadCredentialsFile(path)
+		if err != nil {
+			return creds, err
+		}
+		if file.Cookie != "" {
+			creds.Cookie = file.Cookie
+		}
+		if file.Username != "" {
+			creds.Username = file.Username
+		}
+		if file.Password != "" {
+			creds.Password = file.Password
+		}
+		return creds, nil
+	}
+	if creds, err := credentials(); err != nil {
+		logger.ErrorLogger.Printf("Failed to load TradingEconomics credentials: %v", err)
+	} else if creds.Empty() {
+		logger.InfoLogger.Println("No TradingEconomics credentials configured, fetching anonymously")
+	}
+	return crawler.NewSession(getEnv("TE_LOGIN_URL", crawler.TradingEconomicsLoginURL), credentials)
+}
+
+// parseHeaders parse "Name=Value,Name2=Value2" into a header map
+func parseHeaders(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return headers
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"strconv"
+
+	"backend/pkg/crawler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// registerErrorRoutes crawler error history, newest first, filtered with ?source= and ?kind=
+func registerErrorRoutes(admin *gin.RouterGroup) {
+	admin.GET("/errors", func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		errorLog := priceSources.Errors()
+		c.JSON(200, gin.H{
+			"stats":  errorLog.Stats(),
+			"errors": errorLog.Recent(limit, c.Query("source"), crawler.ErrorKind(c.Query("kind"))),
+		})
+	})
+}
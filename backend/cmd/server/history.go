@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"backend/pkg/storage"
+	"backend/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseHistoryQuery read from, to, limit, cursor and interval query parameters
+func parseHistoryQuery(c *gin.Context) (storage.HistoryQuery, error) {
+	var q storage.HistoryQuery
+	var err error
+
+	if q.From, err = parseDateParam(c.Query("from")); err != nil {
+		return q, fmt.Errorf("Invalid from: %w", err)
+	}
+	if q.To, err = parseDateParam(c.Query("to")); err != nil {
+		return q, fmt.Errorf("Invalid to: %w", err)
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit <= 0 {
+			return q, fmt.Errorf("Invalid limit %q", limit)
+		}
+	}
+	q.Cursor = c.Query("cursor")
+	q.Interval = c.Query("interval")
+	return q, q.Validate()
+}
+
+// parseDateParam parse "2006-01-02" or RFC3339 into a UTC trading date, empty means unbounded
+func parseDateParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.Parse("2006-01-02", value); err == nil {
+		return day, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC3339, got %q", value)
+	}
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// loadRange read every record between from and to, following cursors across pages
+func loadRange(from, to time.Time) ([]types.PriceInfo, error) {
+	records := make([]types.PriceInfo, 0)
+	query := storage.HistoryQuery{From: from, To: to, Limit: storage.MaxQueryLimit}
+	for {
+		priceMutex.RLock()
+		page, err := priceStorage.Query(query)
+		priceMutex.RUnlock()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, page.Items...)
+		if page.NextCursor == "" {
+			return records, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
@@ -1,19 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
+	"backend/pkg/auth"
+	"backend/pkg/candle"
+	"backend/pkg/consensus"
 	"backend/pkg/crawler"
 	"backend/pkg/logger"
 	"backend/pkg/storage"
+	"backend/pkg/stream"
 	"backend/pkg/types"
+	"backend/pkg/validation"
 
 	"github.com/gin-gonic/gin"
 	"github.com/robfig/cron/v3"
@@ -21,16 +31,22 @@ import (
 
 // Global variables
 var (
-	priceStorage storage.Storage
-	priceMutex   sync.RWMutex
-	lastUpdate   time.Time
-	startTime    time.Time // Service startup time
+	priceStorage     storage.Storage
+	priceSources     *crawler.Registry
+	sourcesPerUpdate int // CONSENSUS_SOURCES, healthy sources fetched per update, 0 for all
+	consensusConfig  consensus.Config
+	validationConfig validation.Config
+	priceMutex       sync.RWMutex
+	lastUpdate       time.Time
+	startTime        time.Time // Service startup time
 
 	// Statistics metrics
-	apiCalls    int64 // API call count
-	updateCount int64 // Update count
-	lastError   error // Last error
-	errorCount  int64 // Error count
+	apiCalls    int64                               // API call count
+	updateCount int64                               // Update count
+	lastError   atomic.Pointer[crawler.ErrorRecord] // Last error, typed so /metrics can serialize it
+	errorCount  int64                               // Error count
+
+	quarantinedCount int64 // Prices rejected by validation since startup
 
 	// Monitoring metrics
 	apiLatency    []time.Duration // API response time
@@ -38,52 +54,148 @@ var (
 	maxLatencyLen = 1000 // Keep recent 1000 request latency data
 )
 
-// Update price information
-func updatePriceInfo() error {
+// setLastError remember the latest failure with its kind and source for /metrics
+func setLastError(source string, err error, attempts int) {
+	record := crawler.NewErrorRecord(source, err, attempts)
+	lastError.Store(&record)
+}
+
+// Update price information, sources are abandoned once ctx is done
+func updatePriceInfo(ctx context.Context) error {
 	logger.InfoLogger.Println("Starting price update...")
-	crawler := crawler.NewCarbonCrawler()
 
-	priceInfo, err := crawler.FetchPrice()
+	// Fetch the healthy sources concurrently, failed ones are replaced by the next in priority order
+	results := priceSources.FetchAll(ctx, sourcesPerUpdate)
+	if len(results) == 0 {
+		err := errors.New("No price sources configured")
+		if len(priceSources.Names()) > 0 {
+			err = errors.New("All price sources are unavailable, circuit breakers open")
+		}
+		logger.ErrorLogger.Println(err)
+		setLastError("update", err, 0)
+		return err
+	}
+
+	quotes := make([]types.SourceQuote, len(results))
+	for i, result := range results {
+		recordAttempt(result)
+		quotes[i] = types.SourceQuote{
+			Source:    result.Source,
+			FetchedAt: result.FetchedAt,
+		}
+		if result.Err != nil {
+			logger.ErrorLogger.Printf("Failed to get price info from %s: %v", result.Source, result.Err)
+			atomic.AddInt64(&errorCount, 1)
+			setLastError(result.Source, result.Err, result.Attempts)
+			quotes[i].Error = result.Err.Error()
+			continue
+		}
+		quotes[i].Price = result.PriceInfo.Price
+		quotes[i].Date = result.PriceInfo.Date
+		quotes[i].Extractor = result.PriceInfo.Extractor
+		quotes[i].Confidence = result.PriceInfo.Confidence
+		quotes[i].Synthetic = result.PriceInfo.Synthetic
+		if result.PriceInfo.Confidence == string(crawler.ConfidenceLow) {
+			logger.InfoLogger.Printf("Low confidence price from %s via %s extractor: %.2f", result.Source, result.PriceInfo.Extractor, result.PriceInfo.Price)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		err = fmt.Errorf("Price update cancelled: %w", err)
+		setLastError("update", err, 0)
+		return err
+	}
+
+	agreed, err := consensus.Aggregate(quotes, consensusConfig)
 	if err != nil {
-		logger.ErrorLogger.Printf("Failed to get price info: %v", err)
-		atomic.AddInt64(&errorCount, 1)
-		lastError = err
+		logger.ErrorLogger.Printf("Failed to build consensus price: %v", err)
+		setLastError("update", err, 0)
 		return err
 	}
+	if agreed.Disagreement {
+		logger.ErrorLogger.Printf("Price sources disagree: max deviation %.2f%% exceeds tolerance %.2f%%, quotes=%+v",
+			agreed.MaxDeviation, consensusConfig.Tolerance, quotes)
+	}
 
-	// Convert to types.PriceInfo
-	lastUpdated, _ := time.Parse(time.RFC3339, priceInfo.LastUpdated)
-	priceInfoType := types.PriceInfo{
-		Price:         priceInfo.Price,
-		Date:          priceInfo.Date,
-		DailyChange:   priceInfo.DailyChange,
-		MonthlyChange: priceInfo.MonthlyChange,
-		YearlyChange:  priceInfo.YearlyChange,
-		LastUpdated:   lastUpdated,
+	// Date, change figures and provenance come from the source closest to the consensus price
+	priceInfo := results[agreed.Primary].PriceInfo.Normalized()
+	priceInfo.Price = agreed.Price
+	priceInfo.Quotes = quotes
+	priceInfo.Spread = agreed.Spread
+	priceInfo.SpreadPercent = agreed.SpreadPercent
+	priceInfo.Disagreement = agreed.Disagreement
+	if agreed.Used > 1 {
+		// Extraction details stay with the quotes they describe
+		priceInfo.Source = "consensus"
+		priceInfo.Extractor, priceInfo.Confidence = "", ""
+	}
+	for _, quote := range quotes {
+		if quote.Synthetic && quote.Error == "" {
+			priceInfo.Synthetic = true
+		}
 	}
 
-	// Save to memory storage
+	// Validate against stored history, rejected records are quarantined instead of saved
 	priceMutex.Lock()
-	if err := priceStorage.Save(priceInfoType); err != nil {
+	if violations := validation.Check(priceInfo, priceBaseline(priceInfo), validationConfig, time.Now()); len(violations) > 0 {
+		err := quarantinePrice(priceInfo, violations)
+		priceMutex.Unlock()
+		return err
+	}
+
+	// Save to storage, replacing the record of the same trading date
+	if err := priceStorage.Save(priceInfo); err != nil {
 		priceMutex.Unlock()
 		logger.ErrorLogger.Printf("Failed to save price info: %v", err)
 		atomic.AddInt64(&errorCount, 1)
-		lastError = err
+		setLastError("update", err, 0)
 		return err
 	}
+	// Storage sets the status against the record it replaced
+	if saved := priceStorage.GetLatest(); saved != nil {
+		priceInfo = *saved
+	}
 	lastUpdate = time.Now()
 	priceMutex.Unlock()
 
+	// Push to stream subscribers
+	priceBroker.Publish(priceInfo)
+
 	atomic.AddInt64(&updateCount, 1)
-	logger.InfoLogger.Printf("Price info updated: price=%.2f, date=%s, daily_change=%.2f%%, monthly_change=%.2f%%, yearly_change=%.2f%%",
+	logger.InfoLogger.Printf("Price info %s: source=%s, price=%.2f, date=%s, daily_change=%.2f%%, monthly_change=%.2f%%, yearly_change=%.2f%%, spread=%.2f",
+		priceInfo.Status,
+		priceInfo.Source,
 		priceInfo.Price,
 		priceInfo.Date,
 		priceInfo.DailyChange,
 		priceInfo.MonthlyChange,
-		priceInfo.YearlyChange)
+		priceInfo.YearlyChange,
+		priceInfo.Spread)
 	return nil
 }
 
+// Record crawl attempt when the storage backend supports it
+func recordAttempt(result crawler.FetchResult) {
+	recorder, ok := priceStorage.(storage.AttemptRecorder)
+	if !ok {
+		return
+	}
+	attempt := types.CrawlAttempt{
+		Source:     result.Source,
+		StartedAt:  result.StartedAt,
+		FinishedAt: result.FetchedAt,
+		Success:    result.Err == nil,
+	}
+	if result.Err != nil {
+		attempt.Error = result.Err.Error()
+	} else {
+		attempt.Price = result.PriceInfo.Price
+	}
+	if err := recorder.RecordAttempt(attempt); err != nil {
+		logger.ErrorLogger.Printf("Failed to record crawl attempt: %v", err)
+	}
+}
+
 // Record API latency
 func recordLatency(duration time.Duration) {
 	latencyMutex.Lock()
@@ -132,6 +244,11 @@ func calculateLatencyStats() map[string]interface{} {
 }
 
 func main() {
+	// CLI subcommands, e.g. "app migrate"
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	// Record startup time
 	startTime = time.Now()
 	fmt.Println("=== Service startup begins ===")
@@ -143,10 +260,49 @@ func main() {
 		log.Fatalf("Failed to initialize logger: %v", err)
 	}
 
-	// Initialize memory storage
-	priceStorage = storage.NewMemoryStorage()
+	// Cancelled on SIGINT/SIGTERM, aborts running scrapes and starts the graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	updateTimeout := time.Duration(getEnvInt("UPDATE_TIMEOUT_SECONDS", 90)) * time.Second
+
+	// Trading dates are counted in the exchange timezone
+	setExchangeTimezone()
+
+	// Initialize storage, STORAGE_BACKEND selects memory or bolt
+	var err error
+	priceStorage, err = storage.New(getEnv("STORAGE_BACKEND", storage.BackendMemory), getEnv("STORAGE_PATH", "data/prices.db"))
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
 	fmt.Println("Storage initialization completed")
 
+	// Initialize price sources, optionally recording or replaying their responses
+	newCrawlerTransport()
+	priceSources = newPriceSources()
+	sourcesPerUpdate = getEnvInt("CONSENSUS_SOURCES", 0)
+	if pageArchive = newPageArchive(); pageArchive != nil {
+		priceSources.SetArchiver(pageArchive)
+		pruneArchive()
+	}
+	if reload := getEnvInt("SCRAPERS_RELOAD_SECONDS", 10); reload > 0 {
+		go scrapers.watch(ctx, time.Duration(reload)*time.Second)
+	}
+	consensusConfig = newConsensusConfig()
+	validationConfig = newValidationConfig()
+
+	// Initialize API keys and audit log
+	newAuth()
+
+	// Initialize price signer
+	priceSigner = newPriceSigner()
+
+	// Initialize Chainlink External Adapter
+	priceAdapter = newPriceAdapter()
+
+	// Initialize price update broadcaster
+	priceBroker = stream.NewBroker(getEnvInt("STREAM_HISTORY", 100), getEnvInt("STREAM_BUFFER", 16))
+	streamHeartbeat = time.Duration(getEnvInt("STREAM_HEARTBEAT_SECONDS", 15)) * time.Second
+
 	// Create Gin router
 	fmt.Println("Creating Gin router...")
 	r := gin.Default()
@@ -154,8 +310,12 @@ func main() {
 	// Set CORS
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Last-Event-ID, Authorization, X-API-Key, X-API-Version")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor, X-Synthetic-Prices, X-API-Version")
+		if hasSyntheticSource() {
+			c.Writer.Header().Set("X-Synthetic-Prices", "true")
+		}
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -183,8 +343,23 @@ func main() {
 			status = "no_data"
 		}
 
+		// Upstream sessions, a revoked or expired login degrades the service
+		sessions := make(map[string]crawler.SessionStatus)
+		for _, source := range priceSources.Sources() {
+			reporter, ok := source.(crawler.SessionReporter)
+			if !ok {
+				continue
+			}
+			session := reporter.SessionStatus()
+			sessions[source.Name()] = session
+			if session.State == crawler.SessionExpired || session.State == crawler.SessionLoginFailed {
+				status = "degraded"
+			}
+		}
+
 		c.JSON(200, gin.H{
-			"status": status,
+			"status":   status,
+			"sessions": sessions,
 			"system": gin.H{
 				"uptime":     time.Since(startTime).String(),
 				"version":    "1.0.0",
@@ -230,22 +405,30 @@ func main() {
 			"api": gin.H{
 				"calls":     atomic.LoadInt64(&apiCalls),
 				"errors":    atomic.LoadInt64(&errorCount),
-				"lastError": lastError,
+				"lastError": lastError.Load(),
+				"crawler":   priceSources.Errors().Stats(),
 				"latency":   calculateLatencyStats(),
 			},
+			"stream":   priceBroker.Stats(),
+			"breakers": priceSources.BreakerStats(),
 			"data": gin.H{
 				"lastUpdate":  lastUpdate.Format(time.RFC3339),
 				"hasData":     latestPrice != nil,
 				"updateCount": atomic.LoadInt64(&updateCount),
+				"quarantined": atomic.LoadInt64(&quarantinedCount),
 			},
 		})
 	})
 
 	// API routes
-	r.GET("/api/carbon-price", func(c *gin.Context) {
+	r.GET("/api/carbon-price", readAccess(), func(c *gin.Context) {
 		start := time.Now()
 		atomic.AddInt64(&apiCalls, 1)
 		logger.InfoLogger.Println("Received price request")
+		version, ok := negotiateVersion(c)
+		if !ok {
+			return
+		}
 		priceMutex.RLock()
 		priceInfo := priceStorage.GetLatest()
 		priceMutex.RUnlock()
@@ -257,63 +440,171 @@ func main() {
 			return
 		}
 		logger.InfoLogger.Printf("Returning price info: %+v", priceInfo)
-		c.JSON(200, priceInfo)
+		c.JSON(200, signPrice(priceInfo, version))
 		recordLatency(time.Since(start))
 	})
 
-	r.GET("/api/carbon-price/history", func(c *gin.Context) {
+	r.GET("/api/carbon-price/history", readAccess(), func(c *gin.Context) {
 		start := time.Now()
 		atomic.AddInt64(&apiCalls, 1)
 		logger.InfoLogger.Println("Received history request")
+		version, ok := negotiateVersion(c)
+		if !ok {
+			return
+		}
+		query, err := parseHistoryQuery(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
 		priceMutex.RLock()
-		history := priceStorage.GetHistory()
+		page, err := priceStorage.Query(query)
 		priceMutex.RUnlock()
-		c.JSON(200, history)
+		if err != nil {
+			logger.ErrorLogger.Printf("History query failed: %v", err)
+			atomic.AddInt64(&errorCount, 1)
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		// Body stays a plain list, the next page is announced in a header
+		if page.NextCursor != "" {
+			c.Header("X-Next-Cursor", page.NextCursor)
+		}
+		c.JSON(200, renderPrices(page.Items, version))
 		recordLatency(time.Since(start))
 	})
 
-	r.POST("/api/carbon-price/update", func(c *gin.Context) {
+	r.GET("/api/carbon-price/candles", readAccess(), func(c *gin.Context) {
 		start := time.Now()
 		atomic.AddInt64(&apiCalls, 1)
-		logger.InfoLogger.Println("Received manual update request")
-		if err := updatePriceInfo(); err != nil {
-			logger.ErrorLogger.Printf("Manual update failed: %v", err)
+		logger.InfoLogger.Println("Received candles request")
+		interval, err := candle.ParseInterval(c.DefaultQuery("interval", "1d"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		from, err := parseDateParam(c.Query("from"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from: " + err.Error()})
+			return
+		}
+		to, err := parseDateParam(c.Query("to"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to: " + err.Error()})
+			return
+		}
+		records, err := loadRange(from, to)
+		if err != nil {
+			logger.ErrorLogger.Printf("Candles query failed: %v", err)
 			atomic.AddInt64(&errorCount, 1)
 			c.JSON(500, gin.H{"error": err.Error()})
 			return
 		}
+		c.JSON(200, gin.H{
+			"interval": interval.String(),
+			"candles":  candle.Aggregate(records, interval),
+		})
+		recordLatency(time.Since(start))
+	})
+
+	// Signer public keys and EIP-712 domain for attestation verification
+	r.GET("/api/carbon-price/signers", signersHandler)
+
+	// Chainlink External Adapter
+	r.POST("/adapter", readAccess(), adapterHandler)
+
+	// Push updates: Server-Sent Events and WebSocket
+	r.GET("/api/carbon-price/stream", readAccess(), streamSSE)
+	r.GET("/api/carbon-price/ws", readAccess(), streamWebSocket)
+
+	// Forcing a scrape needs the update scope, see /admin/keys
+	r.POST("/api/carbon-price/update", auditCall(), requireScope(auth.ScopeUpdate), func(c *gin.Context) {
+		start := time.Now()
+		atomic.AddInt64(&apiCalls, 1)
+		logger.InfoLogger.Println("Received manual update request")
+		version, ok := negotiateVersion(c)
+		if !ok {
+			return
+		}
+
+		// The scrape ends with the request, and retries may outlast the server WriteTimeout
+		ctx, cancel := context.WithTimeout(c.Request.Context(), updateTimeout)
+		defer cancel()
+		http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(updateTimeout + 5*time.Second))
+
+		if err := updatePriceInfo(ctx); err != nil {
+			logger.ErrorLogger.Printf("Manual update failed: %v", err)
+			status := 500
+			if errors.Is(err, errQuarantined) {
+				status = 422 // Already counted by quarantinePrice
+			} else {
+				atomic.AddInt64(&errorCount, 1)
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
 		priceMutex.RLock()
 		priceInfo := priceStorage.GetLatest()
 		priceMutex.RUnlock()
 		logger.InfoLogger.Printf("Manual update successful: %+v", priceInfo)
 		c.JSON(200, gin.H{
 			"message": "Price info updated",
-			"data":    priceInfo,
+			"data":    signPrice(priceInfo, version),
 		})
 		recordLatency(time.Since(start))
 	})
 
+	// Admin API: key management and audit trail
+	admin := registerAdminRoutes(r)
+	registerBreakerRoutes(admin)
+	registerErrorRoutes(admin)
+	registerReparseRoutes(admin)
+	registerScraperRoutes(admin)
+	registerQuarantineRoutes(admin)
+
 	// Set up scheduled tasks
 	logger.InfoLogger.Println("Setting up scheduled tasks...")
 	c := cron.New()
 	// Update every 12 hours (daily at 0:00 and 12:00), note: no data on weekends
-	_, err := c.AddFunc("0 0,12 * * *", func() {
+	_, err = c.AddFunc("0 0,12 * * *", func() {
 		logger.InfoLogger.Println("Executing scheduled update...")
-		if err := updatePriceInfo(); err != nil {
+		ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+		defer cancel()
+		if err := updatePriceInfo(ctx); err != nil {
 			logger.ErrorLogger.Printf("Scheduled update failed: %v", err)
 		}
 	})
 	if err != nil {
 		logger.ErrorLogger.Fatalf("Failed to set up scheduled task: %v", err)
 	}
+	// Synthetic prices move every tick, update as often when no source needs scraping
+	if tick := syntheticTick(); tick > 0 {
+		_, err = c.AddFunc(fmt.Sprintf("@every %s", tick), func() {
+			ctx, cancel := context.WithTimeout(ctx, updateTimeout)
+			defer cancel()
+			if err := updatePriceInfo(ctx); err != nil {
+				logger.ErrorLogger.Printf("Synthetic update failed: %v", err)
+			}
+		})
+		if err != nil {
+			logger.ErrorLogger.Fatalf("Failed to set up synthetic updates: %v", err)
+		}
+	}
+	// Prune the page archive daily, after the midnight update
+	_, err = c.AddFunc("30 0 * * *", pruneArchive)
+	if err != nil {
+		logger.ErrorLogger.Fatalf("Failed to set up archive pruning: %v", err)
+	}
 	c.Start()
 	logger.InfoLogger.Println("Scheduled tasks setup completed")
 
 	// Run initial update immediately
 	logger.InfoLogger.Println("Starting initial update...")
-	if err := updatePriceInfo(); err != nil {
+	initialCtx, cancelInitial := context.WithTimeout(ctx, updateTimeout)
+	if err := updatePriceInfo(initialCtx); err != nil {
 		logger.ErrorLogger.Printf("Initial update failed: %v", err)
 	}
+	cancelInitial()
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -331,6 +622,8 @@ func main() {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
+		// Request contexts end on shutdown, so streams and running updates stop
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
 
 	// Start server in goroutine
@@ -359,6 +652,27 @@ func main() {
 	fmt.Println("=== Service startup completed ===")
 	fmt.Printf("Startup time: %v\n", time.Since(startTime))
 
-	// Keep main program running
-	select {}
+	// Run until SIGINT/SIGTERM, then shut down gracefully
+	<-ctx.Done()
+	stop()
+	logger.InfoLogger.Println("Shutdown signal received, stopping...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.ErrorLogger.Printf("HTTP server shutdown: %v", err)
+	}
+	// Wait for a running scheduled update, it was cancelled with ctx
+	select {
+	case <-c.Stop().Done():
+	case <-shutdownCtx.Done():
+		logger.ErrorLogger.Println("Scheduled update did not stop in time")
+	}
+	if err := priceStorage.Close(); err != nil {
+		logger.ErrorLogger.Printf("Failed to close storage: %v", err)
+	}
+	if err := auditLog.Close(); err != nil {
+		logger.ErrorLogger.Printf("Failed to close audit log: %v", err)
+	}
+	logger.InfoLogger.Println("Shutdown completed")
 }
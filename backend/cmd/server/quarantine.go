@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/pkg/logger"
+	"backend/pkg/storage"
+	"backend/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// quarantineStore quarantine capability of the storage backend, answers 503 when missing
+func quarantineStore(c *gin.Context) (storage.Quarantiner, bool) {
+	quarantiner, ok := priceStorage.(storage.Quarantiner)
+	if !ok {
+		c.JSON(503, gin.H{"error": "Storage backend does not support quarantine"})
+	}
+	return quarantiner, ok
+}
+
+// quarantineID parse the :id parameter, answers 400 when invalid
+func quarantineID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		c.JSON(400, gin.H{"error": "Invalid quarantine ID " + c.Param("id")})
+		return 0, false
+	}
+	return id, true
+}
+
+// promotePrice add an approved price to storage, caller holds priceMutex. It becomes the latest
+// price unless a newer one was accepted since, then it only joins history. A price older than the
+// record stored for its trading date is refused with storage.ErrNewerRecord.
+func promotePrice(priceInfo types.PriceInfo) (bool, error) {
+	current := priceStorage.GetLatest()
+	if current != nil && priceInfo.LastUpdated.Before(current.LastUpdated) {
+		if priceInfo.TradingDay().Equal(current.TradingDay()) {
+			return false, storage.ErrNewerRecord
+		}
+		corrector, ok := priceStorage.(storage.Corrector)
+		if !ok {
+			return false, errors.New("Storage backend does not support history corrections")
+		}
+		return false, corrector.Backfill(priceInfo)
+	}
+	if err := priceStorage.Save(priceInfo); err != nil {
+		return false, err
+	}
+	lastUpdate = time.Now()
+	return true, nil
+}
+
+// reviewQuarantined approve or reject a quarantined price with the operator's comment
+func reviewQuarantined(status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		quarantiner, ok := quarantineStore(c)
+		if !ok {
+			return
+		}
+		id, ok := quarantineID(c)
+		if !ok {
+			return
+		}
+		var body struct {
+			Comment string `json:"comment"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+		auditDetail(c, "quarantineId", id)
+		auditDetail(c, "decision", status)
+		if body.Comment = strings.TrimSpace(body.Comment); body.Comment == "" {
+			c.JSON(400, gin.H{"error": "A comment explaining the decision is required"})
+			return
+		}
+		auditDetail(c, "comment", body.Comment)
+
+		// The decision is recorded first so a pending entry is never already live, and undone when
+		// the price cannot be promoted. Held across both so two approvals cannot both save the price.
+		priceMutex.Lock()
+		entry, err := quarantiner.Review(id, status, actor(c), body.Comment)
+		var latest bool
+		if err == nil && status == types.QuarantineApproved {
+			if latest, err = promotePrice(entry.PriceInfo); err != nil {
+				logger.ErrorLogger.Printf("Failed to promote quarantined price %d: %v", id, err)
+				if reopenErr := quarantiner.Reopen(id); reopenErr != nil {
+					logger.ErrorLogger.Printf("Failed to reopen quarantined price %d: %v", id, reopenErr)
+				}
+			}
+		}
+		priceMutex.Unlock()
+
+		switch {
+		case errors.Is(err, storage.ErrRecordNotFound):
+			c.JSON(404, gin.H{"error": "Unknown quarantine entry"})
+			return
+		case errors.Is(err, storage.ErrAlreadyReviewed):
+			c.JSON(409, gin.H{"error": err.Error(), "entry": entry})
+			return
+		case errors.Is(err, storage.ErrNewerRecord):
+			c.JSON(409, gin.H{"error": err.Error() + ", the entry stays pending"})
+			return
+		case err != nil:
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		auditDetail(c, "price", entry.PriceInfo.Price)
+		auditDetail(c, "date", entry.PriceInfo.Date)
+		logger.InfoLogger.Printf("Quarantined price %d %s by %s: %s", id, status, entry.ReviewedBy, body.Comment)
+
+		if latest {
+			priceMutex.RLock()
+			saved := priceStorage.GetLatest()
+			priceMutex.RUnlock()
+			if saved != nil {
+				priceBroker.Publish(*saved)
+			}
+		}
+		c.JSON(200, gin.H{"entry": entry, "becameLatest": latest})
+	}
+}
+
+// registerQuarantineRoutes review of prices rejected by validation
+func registerQuarantineRoutes(admin *gin.RouterGroup) {
+	admin.GET("/quarantine", func(c *gin.Context) {
+		quarantiner, ok := quarantineStore(c)
+		if !ok {
+			return
+		}
+		status := c.DefaultQuery("status", types.QuarantinePending)
+		if status == "all" {
+			status = ""
+		}
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		entries, err := quarantiner.Quarantined(status, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, entries)
+	})
+
+	admin.GET("/quarantine/:id", func(c *gin.Context) {
+		quarantiner, ok := quarantineStore(c)
+		if !ok {
+			return
+		}
+		id, ok := quarantineID(c)
+		if !ok {
+			return
+		}
+		entry, err := quarantiner.GetQuarantined(id)
+		if errors.Is(err, storage.ErrRecordNotFound) {
+			c.JSON(404, gin.H{"error": "Unknown quarantine entry"})
+			return
+		} else if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		// The accepted price it would replace, for comparison
+		priceMutex.RLock()
+		latest := priceStorage.GetLatest()
+		priceMutex.RUnlock()
+		c.JSON(200, gin.H{"entry": entry, "latest": latest})
+	})
+
+	admin.POST("/quarantine/:id/approve", reviewQuarantined(types.QuarantineApproved))
+	admin.POST("/quarantine/:id/reject", reviewQuarantined(types.QuarantineRejected))
+}
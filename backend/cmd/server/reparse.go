@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"backend/pkg/archive"
+	"backend/pkg/consensus"
+	"backend/pkg/crawler"
+	"backend/pkg/logger"
+	"backend/pkg/storage"
+	"backend/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageArchive raw responses of every fetch, nil when archiving is disabled
+var pageArchive *archive.Archive
+
+// archiveMaxAge age after which archived pages are pruned, 0 keeps them forever
+var archiveMaxAge time.Duration
+
+// Reparse outcomes of an archived page
+const (
+	reparseBackfill   = "backfill"   // No record for the trading date, one is added
+	reparseCorrect    = "correct"    // Stored price from the same source differs and is overwritten
+	reparseUnchanged  = "unchanged"  // Stored price matches the reparsed page
+	reparseSuperseded = "superseded" // A later page of the same source and trading date is used instead
+	reparseSkipped    = "skipped"    // Stored record comes from another source or the consensus
+	reparseFailed     = "failed"     // Page could not be parsed or the record could not be written
+)
+
+// reparseResult outcome for one archived page
+type reparseResult struct {
+	PageID      string    `json:"pageId"`
+	Source      string    `json:"source"`
+	URL         string    `json:"url"`
+	FetchedAt   time.Time `json:"fetchedAt"`
+	Date        string    `json:"date,omitempty"`
+	Price       float64   `json:"price,omitempty"`
+	StoredPrice float64   `json:"storedPrice,omitempty"`
+	Action      string    `json:"action"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// newPageArchive open ARCHIVE_DIR, "off" disables archiving
+func newPageArchive() *archive.Archive {
+	dir := getEnv("ARCHIVE_DIR", "data/archive")
+	if dir == "off" {
+		return nil
+	}
+	archiveMaxAge = time.Duration(getEnvInt("ARCHIVE_MAX_AGE_DAYS", 365)) * 24 * time.Hour
+	pages, err := archive.New(dir)
+	if err != nil {
+		logger.ErrorLogger.Printf("Page archive disabled: %v", err)
+		return nil
+	}
+	return pages
+}
+
+// pruneArchive delete archived pages older than archiveMaxAge
+func pruneArchive() {
+	if pageArchive == nil || archiveMaxAge <= 0 {
+		return
+	}
+	pruned, err := pageArchive.Prune(time.Now().Add(-archiveMaxAge))
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to prune page archive: %v", err)
+	}
+	if pruned > 0 {
+		logger.InfoLogger.Printf("Pruned %d archived pages older than %s", pruned, archiveMaxAge)
+	}
+}
+
+// runReparse parse the archived pages matching filter with the current parsers and compare them
+// with the stored history. The latest page per source and trading date counts; successful
+// pages only, error responses are archived for debugging but carry no price. Nothing is
+// written unless apply is set.
+func runReparse(pages *archive.Archive, registry *crawler.Registry, store storage.Storage, filter archive.Filter, apply bool) ([]reparseResult, error) {
+	corrector, canCorrect := store.(storage.Corrector)
+	if apply && !canCorrect {
+		return nil, errors.New("Storage backend does not support history corrections")
+	}
+	list, err := pages.List(filter)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot list archived pages: %w", err)
+	}
+
+	results := make([]reparseResult, 0, len(list))
+	parsed := make(map[int]types.PriceInfo)
+	latest := make(map[string]int) // source/trading date -> index in results
+	for _, page := range list {
+		if page.Status < 200 || page.Status >= 300 {
+			continue
+		}
+		result := reparseResult{PageID: page.ID, Source: page.Source, URL: page.URL, FetchedAt: page.FetchedAt}
+		priceInfo, err := reparsePage(pages, registry, page)
+		if err != nil {
+			result.Action, result.Error = reparseFailed, err.Error()
+			results = append(results, result)
+			continue
+		}
+		result.Date, result.Price = priceInfo.Date, priceInfo.Price.Float64()
+
+		key := page.Source + "/" + priceInfo.TradingDay().Format("2006-01-02")
+		if i, ok := latest[key]; ok {
+			results[i].Action = reparseSuperseded
+			delete(parsed, i)
+		}
+		latest[key] = len(results)
+		parsed[len(results)] = priceInfo
+		results = append(results, result)
+	}
+
+	for i, priceInfo := range parsed {
+		result := &results[i]
+		day := priceInfo.TradingDay()
+		page, err := store.Query(storage.HistoryQuery{From: day, To: day, Limit: storage.MaxQueryLimit})
+		if err != nil {
+			result.Action, result.Error = reparseFailed, err.Error()
+			continue
+		}
+
+		if len(page.Items) == 0 {
+			result.Action = reparseBackfill
+			if apply {
+				err = corrector.Backfill(priceInfo)
+			}
+		} else {
+			stored := page.Items[len(page.Items)-1]
+			result.StoredPrice = stored.Price.Float64()
+			switch {
+			case stored.Source != priceInfo.Source:
+				result.Action = reparseSkipped
+				result.Error = "Stored record comes from " + stored.Source
+			case sameFigures(stored, priceInfo):
+				result.Action = reparseUnchanged
+			default:
+				var corrected types.PriceInfo
+				if corrected, err = correctedRecord(stored, priceInfo); err == nil {
+					result.Action = reparseCorrect
+					if apply {
+						err = corrector.Replace(stored, corrected)
+					}
+				}
+			}
+		}
+		if err != nil {
+			result.Action, result.Error = reparseFailed, err.Error()
+		}
+	}
+	return results, nil
+}
+
+// correctedRecord stored record with the reparsed figures of its source. The quotes of the other
+// sources, failed when the record was stored, are kept and the consensus figures recomputed over
+// them; a record aggregating several prices is refused, one page cannot correct it.
+func correctedRecord(stored, reparsed types.PriceInfo) (types.PriceInfo, error) {
+	quote := reparsed.Quotes[0]
+	quote.FetchedAt = stored.LastUpdated
+
+	corrected := reparsed
+	corrected.LastUpdated = stored.LastUpdated
+	corrected.Quotes = make([]types.SourceQuote, 0, len(stored.Quotes)+1)
+	found := false
+	for _, q := range stored.Quotes {
+		if q.Source == reparsed.Source {
+			q, found = quote, true
+		}
+		corrected.Quotes = append(corrected.Quotes, q)
+	}
+	if !found {
+		corrected.Quotes = append(corrected.Quotes, quote)
+	}
+
+	agreed, err := consensus.Aggregate(corrected.Quotes, consensusConfig)
+	if err != nil {
+		return types.PriceInfo{}, err
+	}
+	if agreed.Used > 1 {
+		return types.PriceInfo{}, fmt.Errorf("Stored record aggregates %d quotes", agreed.Used)
+	}
+	corrected.Price = agreed.Price
+	corrected.Spread = agreed.Spread
+	corrected.SpreadPercent = agreed.SpreadPercent
+	corrected.Disagreement = agreed.Disagreement
+	for _, q := range corrected.Quotes {
+		if q.Synthetic && q.Error == "" {
+			corrected.Synthetic = true
+		}
+	}
+	return corrected, nil
+}
+
+// reparsePage load an archived page and run the current parser of its source over it
+func reparsePage(pages *archive.Archive, registry *crawler.Registry, page archive.Page) (types.PriceInfo, error) {
+	reparser, ok := registry.Get(page.Source).(crawler.Reparser)
+	if !ok {
+		return types.PriceInfo{}, fmt.Errorf("Source %s is not configured or cannot reparse pages", page.Source)
+	}
+	_, body, err := pages.Load(page.ID)
+	if err != nil {
+		return types.PriceInfo{}, err
+	}
+	priceInfo, err := reparser.Reparse(body)
+	if err != nil {
+		return types.PriceInfo{}, err
+	}
+	priceInfo.LastUpdated = page.FetchedAt
+	priceInfo.Source = page.Source
+	priceInfo.Quotes = []types.SourceQuote{{
+		Source:     page.Source,
+		Price:      priceInfo.Price,
+		Date:       priceInfo.Date,
+		FetchedAt:  page.FetchedAt,
+		Extractor:  priceInfo.Extractor,
+		Confidence: priceInfo.Confidence,
+		Synthetic:  priceInfo.Synthetic,
+	}}
+	return priceInfo.Normalized(), nil
+}
+
+// sameFigures whether a stored record already holds the reparsed figures
+func sameFigures(stored, reparsed types.PriceInfo) bool {
+	return stored.Price == reparsed.Price && stored.Date == reparsed.Date &&
+		stored.DailyChange == reparsed.DailyChange && stored.MonthlyChange == reparsed.MonthlyChange &&
+		stored.YearlyChange == reparsed.YearlyChange
+}
+
+// registerReparseRoutes reparse archived pages, a dry run unless the body sets "apply"
+func registerReparseRoutes(admin *gin.RouterGroup) {
+	// Body {"source": "eex", "from": "2024-01-01", "to": "2024-01-31", "apply": true}, all optional
+	admin.POST("/reparse", func(c *gin.Context) {
+		if pageArchive == nil {
+			c.JSON(503, gin.H{"error": "Page archive is disabled"})
+			return
+		}
+		var body struct {
+			Source string `json:"source"`
+			From   string `json:"from"`
+			To     string `json:"to"`
+			Apply  bool   `json:"apply"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(400, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+		filter := archive.Filter{Source: body.Source}
+		var err error
+		if filter.From, err = parseDateParam(body.From); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid from: " + err.Error()})
+			return
+		}
+		if filter.To, err = parseDateParam(body.To); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid to: " + err.Error()})
+			return
+		}
+		auditDetail(c, "source", body.Source)
+		auditDetail(c, "apply", body.Apply)
+
+		priceMutex.Lock()
+		results, err := runReparse(pageArchive, priceSources, priceStorage, filter, body.Apply)
+		priceMutex.Unlock()
+		if err != nil {
+			c.JSON(500, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"applied": body.Apply, "summary": summarizeReparse(results), "results": results})
+	})
+}
+
+// summarizeReparse count results per action
+func summarizeReparse(results []reparseResult) map[string]int {
+	summary := make(map[string]int)
+	for _, result := range results {
+		summary[result.Action]++
+	}
+	return summary
+}
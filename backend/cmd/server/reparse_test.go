@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"backend/pkg/consensus"
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// TestCorrectedRecord test a correction keeps the failed quotes of other sources and refuses consensus records
+func TestCorrectedRecord(t *testing.T) {
+	consensusConfig = consensus.DefaultConfig()
+	stored := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	fetched := stored.Add(time.Minute)
+	reparsed := types.PriceInfo{
+		Price:       decimal.MustParse("70.12"),
+		Date:        "January 15, 2024",
+		Source:      "eex",
+		LastUpdated: fetched,
+		Quotes:      []types.SourceQuote{{Source: "eex", Price: decimal.MustParse("70.12"), FetchedAt: fetched}},
+	}
+
+	tests := []struct {
+		name    string
+		quotes  []types.SourceQuote
+		wantErr bool
+		want    int
+	}{
+		{name: "Legacy", quotes: nil, want: 1},
+		{
+			name: "Failed quotes kept",
+			quotes: []types.SourceQuote{
+				{Source: "eex", Price: decimal.MustParse("70.21"), FetchedAt: stored},
+				{Source: "ice", Error: "Request timed out", FetchedAt: stored},
+			},
+			want: 2,
+		},
+		{
+			name: "Consensus",
+			quotes: []types.SourceQuote{
+				{Source: "eex", Price: decimal.MustParse("70.21"), FetchedAt: stored},
+				{Source: "ice", Price: decimal.MustParse("70.30"), FetchedAt: stored},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := types.PriceInfo{Price: decimal.MustParse("70.21"), Source: "eex", LastUpdated: stored, Quotes: tt.quotes}
+			corrected, err := correctedRecord(record, reparsed)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected consensus record refused, got %+v", corrected)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("correctedRecord failed: %v", err)
+			}
+			if len(corrected.Quotes) != tt.want || corrected.Quotes[0].Source != "eex" || corrected.Quotes[0].Price.String() != "70.12" {
+				t.Fatalf("Expected %d quotes led by the reparsed one, got %+v", tt.want, corrected.Quotes)
+			}
+			if tt.want > 1 && corrected.Quotes[1].Error == "" {
+				t.Errorf("Failed quote lost: %+v", corrected.Quotes[1])
+			}
+			if corrected.Price.String() != "70.12" || !corrected.LastUpdated.Equal(stored) || !corrected.Quotes[0].FetchedAt.Equal(stored) {
+				t.Errorf("Unexpected record: price %s, updated %v", corrected.Price, corrected.LastUpdated)
+			}
+			if corrected.Spread.Sign() != 0 || corrected.Disagreement {
+				t.Errorf("Consensus figures not recomputed: spread %s, disagreement %v", corrected.Spread, corrected.Disagreement)
+			}
+		})
+	}
+}
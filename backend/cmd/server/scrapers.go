@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/pkg/archive"
+	"backend/pkg/crawler"
+	"backend/pkg/logger"
+	"backend/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scrapers declarative scrapers of SCRAPERS_DIR, set by newPriceSources
+var scrapers *scraperSet
+
+// scraperSet definitions loaded into a registry. A definition named like a configured source
+// replaces it, and the configured source comes back when the definition is removed.
+type scraperSet struct {
+	mu         sync.Mutex
+	dir        string
+	registry   *crawler.Registry
+	builtin    map[string]crawler.PriceSource // Sources from PRICE_SOURCES
+	active     map[string]string              // Registered definition name -> checksum
+	lastGood   map[string]crawler.DefinitionFile
+	files      []crawler.DefinitionFile // Last load, for the admin listing
+	signature  string
+	reloadedAt time.Time
+}
+
+// newScraperSet definitions of dir on top of the sources already in registry
+func newScraperSet(dir string, registry *crawler.Registry) *scraperSet {
+	builtin := make(map[string]crawler.PriceSource)
+	for _, source := range registry.Sources() {
+		builtin[source.Name()] = source
+	}
+	return &scraperSet{
+		dir:      dir,
+		registry: registry,
+		builtin:  builtin,
+		active:   make(map[string]string),
+		lastGood: make(map[string]crawler.DefinitionFile),
+	}
+}
+
+// dirSignature file names, sizes and modification times of the definitions directory
+func (s *scraperSet) dirSignature() string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err.Error()
+	}
+	var b strings.Builder
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return b.String()
+}
+
+// reload load the directory and bring the registry in line with it. A file that turns invalid
+// keeps its last valid version registered, so a half-saved edit does not drop the source.
+func (s *scraperSet) reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	signature := s.dirSignature()
+	files, err := crawler.LoadDefinitions(s.dir)
+	if err != nil {
+		return err
+	}
+	s.signature = signature
+	s.reloadedAt = time.Now()
+	s.files = files
+
+	// Enabled definitions by name in file order, invalid files fall back to their last valid version
+	wanted := make(map[string]crawler.DefinitionFile)
+	var order []string
+	for _, file := range files {
+		if file.Definition == nil {
+			logger.ErrorLogger.Printf("Scraper definition %s: %s", file.Path, file.Error)
+			good, ok := s.lastGood[file.Path]
+			if !ok {
+				continue
+			}
+			file = good
+		}
+		s.lastGood[file.Path] = file
+		if _, taken := wanted[file.Definition.Name]; !taken && !file.Definition.Disabled {
+			wanted[file.Definition.Name] = file
+			order = append(order, file.Definition.Name)
+		}
+	}
+	for path := range s.lastGood {
+		if _, err := os.Stat(path); err != nil {
+			delete(s.lastGood, path)
+		}
+	}
+
+	for _, name := range order {
+		file := wanted[name]
+		if s.active[name] == file.Checksum {
+			continue
+		}
+		s.activate(file.Definition)
+		s.active[name] = file.Checksum
+		logger.InfoLogger.Printf("Scraper definition %s loaded from %s", name, file.Path)
+	}
+	for name := range s.active {
+		if _, ok := wanted[name]; ok {
+			continue
+		}
+		delete(s.active, name)
+		if source, ok := s.builtin[name]; ok {
+			s.registry.Register(source)
+		} else {
+			s.registry.Unregister(name)
+		}
+		logger.InfoLogger.Printf("Scraper definition %s removed", name)
+	}
+	return nil
+}
+
+// activate register a definition, a new source name gets its retry policy and breaker
+func (s *scraperSet) activate(definition *crawler.Definition) {
+	source := crawler.NewDeclarativeSource(definition)
+	if pageArchive != nil {
+		source.SetArchiver(pageArchive)
+	}
+	isNew := s.registry.Get(definition.Name) == nil
+	s.registry.Register(source)
+	if isNew {
+		configureSource(s.registry, definition.Name)
+	}
+}
+
+// watch reload whenever the directory changes, until ctx is done
+func (s *scraperSet) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.mu.Lock()
+		changed := s.dirSignature() != s.signature
+		s.mu.Unlock()
+		if !changed {
+			continue
+		}
+		if err := s.reload(); err != nil {
+			logger.ErrorLogger.Printf("Failed to reload scraper definitions: %v", err)
+		}
+	}
+}
+
+// save write a validated definition into the directory and load it
+func (s *scraperSet) save(definition *crawler.Definition, data []byte) (string, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", fmt.Errorf("Cannot create definitions directory: %w", err)
+	}
+	path := filepath.Join(s.dir, definition.Name+".yaml")
+	s.mu.Lock()
+	for _, file := range s.files {
+		if file.Definition != nil && file.Definition.Name == definition.Name {
+			path = file.Path
+		}
+	}
+	s.mu.Unlock()
+
+	// Write then rename, the watcher never sees a partial file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", fmt.Errorf("Cannot write definition: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("Cannot write definition: %w", err)
+	}
+	return path, s.reload()
+}
+
+// status loaded files and the names currently registered from them
+func (s *scraperSet) status() gin.H {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active := make([]string, 0, len(s.active))
+	for _, name := range s.registry.Names() {
+		if _, ok := s.active[name]; ok {
+			active = append(active, name)
+		}
+	}
+	return gin.H{"dir": s.dir, "reloadedAt": s.reloadedAt, "active": active, "files": redactedFiles(s.files)}
+}
+
+// redactedFiles definition files with archive.SensitiveHeaders removed from their request headers
+func redactedFiles(files []crawler.DefinitionFile) []crawler.DefinitionFile {
+	redacted := make([]crawler.DefinitionFile, len(files))
+	for i, file := range files {
+		if file.Definition != nil && len(file.Definition.Headers) > 0 {
+			headers := make(http.Header)
+			for name, value := range file.Definition.Headers {
+				headers.Set(name, value)
+			}
+			kept := archive.RedactHeaders(headers)
+			definition := *file.Definition
+			definition.Headers = make(map[string]string)
+			for name, value := range file.Definition.Headers {
+				if _, ok := kept[http.CanonicalHeaderKey(name)]; ok {
+					definition.Headers[name] = value
+				}
+			}
+			file.Definition = &definition
+		}
+		redacted[i] = file
+	}
+	return redacted
+}
+
+// registerScraperRoutes list scraper definitions and validate new ones before activating them
+func registerScraperRoutes(admin *gin.RouterGroup) {
+	admin.GET("/scrapers", func(c *gin.Context) {
+		c.JSON(200, scrapers.status())
+	})
+
+	// Body {"definition": "<yaml>", "pageId": "<archived page>", "activate": true}. Without pageId
+	// the definition's URL is fetched live; activate saves a definition that extracted a price.
+	admin.POST("/scrapers/validate", func(c *gin.Context) {
+		var body struct {
+			Definition string `json:"definition"`
+			PageID     string `json:"pageId"`
+			Activate   bool   `json:"activate"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+		definition, err := crawler.ParseDefinition([]byte(body.Definition))
+		if err != nil {
+			c.JSON(400, gin.H{"valid": false, "error": err.Error()})
+			return
+		}
+		auditDetail(c, "name", definition.Name)
+		auditDetail(c, "pageId", body.PageID)
+		auditDetail(c, "activate", body.Activate)
+
+		source := crawler.NewDeclarativeSource(definition)
+		var priceInfo *types.PriceInfo
+		if body.PageID != "" {
+			if pageArchive == nil {
+				c.JSON(503, gin.H{"error": "Page archive is disabled"})
+				return
+			}
+			_, page, loadErr := pageArchive.Load(body.PageID)
+			if errors.Is(loadErr, archive.ErrNotFound) {
+				c.JSON(404, gin.H{"error": loadErr.Error()})
+				return
+			}
+			if loadErr != nil {
+				c.JSON(500, gin.H{"error": loadErr.Error()})
+				return
+			}
+			priceInfo, err = source.Reparse(page)
+		} else {
+			priceInfo, err = crawler.FetchAttempt(c.Request.Context(), source, priceSources.Policy(definition.Name).Timeout)
+		}
+		if err != nil {
+			c.JSON(422, gin.H{"valid": false, "error": err.Error(), "kind": crawler.Classify(err)})
+			return
+		}
+
+		response := gin.H{"valid": true, "price": priceInfo, "activated": false}
+		if body.Activate {
+			path, err := scrapers.save(definition, []byte(body.Definition))
+			if err != nil {
+				c.JSON(500, gin.H{"valid": true, "price": priceInfo, "error": err.Error()})
+				return
+			}
+			logger.InfoLogger.Printf("Scraper definition %s activated at %s", definition.Name, path)
+			response["activated"], response["path"] = true, path
+		}
+		c.JSON(200, response)
+	})
+}
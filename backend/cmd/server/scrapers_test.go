@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"backend/pkg/crawler"
+)
+
+// TestRedactedFiles test that the scraper listing hides credentials configured as request headers
+func TestRedactedFiles(t *testing.T) {
+	definition := &crawler.Definition{Name: "eex", Headers: map[string]string{
+		"authorization": "Bearer secret",
+		"Cookie":        "session=secret",
+		"User-Agent":    "GreenTrace",
+	}}
+	files := []crawler.DefinitionFile{{Path: "eex.yaml", Definition: definition}, {Path: "broken.yaml", Error: "Invalid"}}
+
+	redacted := redactedFiles(files)
+	if headers := redacted[0].Definition.Headers; len(headers) != 1 || headers["User-Agent"] != "GreenTrace" {
+		t.Errorf("Expected only User-Agent, got %v", headers)
+	}
+	if len(definition.Headers) != 3 {
+		t.Errorf("Loaded definition modified: %v", definition.Headers)
+	}
+	if redacted[1].Definition != nil || redacted[1].Error != "Invalid" {
+		t.Errorf("Invalid file changed: %+v", redacted[1])
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"backend/pkg/logger"
+	"backend/pkg/stream"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Stream settings
+var (
+	priceBroker     *stream.Broker
+	streamHeartbeat = 15 * time.Second // Heartbeat / ping interval
+	streamWriteWait = 10 * time.Second // Deadline for a single write to a client
+)
+
+// eventSnapshot event type of the current price sent on connect when no resume is possible
+const eventSnapshot = "snapshot"
+
+// wsUpgrader WebSocket upgrader, origins are open like the CORS policy
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// lastEventID resume point from the Last-Event-ID header or lastEventId query parameter
+func lastEventID(c *gin.Context) uint64 {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("lastEventId")
+	}
+	id, _ := strconv.ParseUint(value, 10, 64)
+	return id
+}
+
+// snapshotEvent current stored price as an event without ID, nil when no price yet
+func snapshotEvent() *stream.Event {
+	priceMutex.RLock()
+	latest := priceStorage.GetLatest()
+	priceMutex.RUnlock()
+	if latest == nil {
+		return nil
+	}
+	return &stream.Event{Type: eventSnapshot, Time: time.Now(), Data: *latest}
+}
+
+// streamSSE GET /api/carbon-price/stream, Server-Sent Events feed of price updates
+func streamSSE(c *gin.Context) {
+	atomic.AddInt64(&apiCalls, 1)
+	version, ok := negotiateVersion(c)
+	if !ok {
+		return
+	}
+	sub, replay, resumed := priceBroker.Subscribe(lastEventID(c))
+	defer sub.Close()
+	logger.InfoLogger.Printf("SSE client connected: %s, resumed=%v, replay=%d", c.ClientIP(), resumed, len(replay))
+
+	rc := http.NewResponseController(c.Writer)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	write := func(chunk string) bool {
+		// Extend the server WriteTimeout for every chunk of this long-lived response
+		rc.SetWriteDeadline(time.Now().Add(streamWriteWait))
+		if _, err := c.Writer.WriteString(chunk); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	writeEvent := func(event stream.Event) bool {
+		data, err := json.Marshal(renderPrice(event.Data, version))
+		if err != nil {
+			logger.ErrorLogger.Printf("Failed to encode stream event: %v", err)
+			return true
+		}
+		chunk := fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, data)
+		if event.ID != 0 {
+			chunk = fmt.Sprintf("id: %d\n", event.ID) + chunk
+		}
+		return write(chunk)
+	}
+
+	if !write(fmt.Sprintf("retry: %d\n\n", (3 * time.Second).Milliseconds())) {
+		return
+	}
+	if !resumed {
+		if snapshot := snapshotEvent(); snapshot != nil && !writeEvent(*snapshot) {
+			return
+		}
+	}
+	for _, event := range replay {
+		if !writeEvent(event) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					// The client reconnects with Last-Event-ID and gets the missed events replayed
+					write("event: lagged\ndata: {}\n\n")
+					logger.InfoLogger.Printf("SSE client %s dropped for falling behind", c.ClientIP())
+				}
+				return
+			}
+			if !writeEvent(event) {
+				return
+			}
+		}
+	}
+}
+
+// streamWebSocket GET /api/carbon-price/ws, WebSocket feed of price updates
+func streamWebSocket(c *gin.Context) {
+	atomic.AddInt64(&apiCalls, 1)
+	version, ok := negotiateVersion(c)
+	if !ok {
+		return
+	}
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.ErrorLogger.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	sub, replay, resumed := priceBroker.Subscribe(lastEventID(c))
+	defer sub.Close()
+	logger.InfoLogger.Printf("WebSocket client connected: %s, resumed=%v, replay=%d", c.ClientIP(), resumed, len(replay))
+
+	// Read pump: handles pongs and detects the client going away
+	closed := make(chan struct{})
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * streamHeartbeat))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	writeEvent := func(event stream.Event) bool {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+		return conn.WriteJSON(renderEvent(event, version)) == nil
+	}
+	if !resumed {
+		if snapshot := snapshotEvent(); snapshot != nil && !writeEvent(*snapshot) {
+			return
+		}
+	}
+	for _, event := range replay {
+		if !writeEvent(event) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				reason := "server closing"
+				if sub.Lagged() {
+					reason = "lagged, reconnect with lastEventId"
+				}
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, reason),
+					time.Now().Add(streamWriteWait))
+				return
+			}
+			if !writeEvent(event) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"backend/pkg/crawler"
+	"backend/pkg/logger"
+	"backend/pkg/storage"
+	"backend/pkg/types"
+	"backend/pkg/validation"
+)
+
+// errQuarantined update produced a price that failed validation
+var errQuarantined = errors.New("Price rejected by validation and quarantined")
+
+// priceBaseline stored prices a new record is validated against, caller holds priceMutex
+func priceBaseline(priceInfo types.PriceInfo) validation.Baseline {
+	baseline := validation.Baseline{Latest: priceStorage.GetLatest()}
+	if validationConfig.MaxGapDays <= 0 {
+		return baseline
+	}
+	day := priceInfo.TradingDay()
+	page, err := priceStorage.Query(storage.HistoryQuery{
+		From: day.AddDate(0, 0, -validationConfig.MaxGapDays),
+		To:   day.AddDate(0, 0, -1),
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to read validation history: %v", err)
+		return baseline
+	}
+	if n := len(page.Items); n > 0 {
+		baseline.Previous = &page.Items[n-1]
+	}
+	return baseline
+}
+
+// quarantinePrice hold a rejected price for review, returns the error the update fails with. A sample
+// already pending with the same source, trading date and price is not queued again.
+func quarantinePrice(priceInfo types.PriceInfo, violations []types.Violation) error {
+	messages := make([]string, len(violations))
+	for i, v := range violations {
+		messages[i] = v.Message
+	}
+	err := fmt.Errorf("%w: %s", errQuarantined, strings.Join(messages, "; "))
+	logger.ErrorLogger.Printf("Rejected price %.2f from %s: %s", priceInfo.Price, priceInfo.Source, strings.Join(messages, "; "))
+	atomic.AddInt64(&errorCount, 1)
+	setLastError("validation", crawler.NewFetchError(crawler.KindValidation, priceInfo.Source, "", err), 0)
+
+	quarantiner, ok := priceStorage.(storage.Quarantiner)
+	if !ok {
+		logger.ErrorLogger.Println("Storage backend cannot quarantine, rejected price dropped")
+		return err
+	}
+	if entry, ok := pendingDuplicate(quarantiner, priceInfo); ok {
+		logger.InfoLogger.Printf("Price already quarantined as entry %d", entry.ID)
+		return err
+	}
+	entry, qerr := quarantiner.Quarantine(types.QuarantinedPrice{
+		PriceInfo:     priceInfo,
+		Violations:    violations,
+		QuarantinedAt: time.Now().UTC(),
+	})
+	if qerr != nil {
+		logger.ErrorLogger.Printf("Failed to quarantine price: %v", qerr)
+		return err
+	}
+	atomic.AddInt64(&quarantinedCount, 1)
+	logger.InfoLogger.Printf("Price quarantined as entry %d", entry.ID)
+	return err
+}
+
+// pendingDuplicate pending entry holding the same sample, the scheduler rejects it again on every tick
+func pendingDuplicate(quarantiner storage.Quarantiner, priceInfo types.PriceInfo) (types.QuarantinedPrice, bool) {
+	pending, err := quarantiner.Quarantined(types.QuarantinePending, 0)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to read pending quarantine entries: %v", err)
+		return types.QuarantinedPrice{}, false
+	}
+	for _, entry := range pending {
+		if entry.PriceInfo.Source == priceInfo.Source && entry.PriceInfo.Price == priceInfo.Price &&
+			entry.PriceInfo.TradingDay().Equal(priceInfo.TradingDay()) {
+			return entry, true
+		}
+	}
+	return types.QuarantinedPrice{}, false
+}
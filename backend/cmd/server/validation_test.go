@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/storage"
+	"backend/pkg/types"
+)
+
+// TestQuarantineDuplicates test that a sample rejected on every tick is queued once
+func TestQuarantineDuplicates(t *testing.T) {
+	priceStorage = storage.NewMemoryStorage()
+	quarantinedCount = 0
+	violations := []types.Violation{{Rule: "jump", Message: "Price moved too much"}}
+	sample := types.PriceInfo{Price: decimal.MustParse("7.13"), Date: "January 17, 2024", LastUpdated: time.Date(2024, 1, 17, 9, 0, 0, 0, time.UTC), Source: "tradingeconomics"}
+
+	later := sample
+	later.LastUpdated = later.LastUpdated.Add(time.Hour)
+	other := sample
+	other.Price = decimal.MustParse("7.14")
+	for _, p := range []types.PriceInfo{sample, later, other} {
+		if err := quarantinePrice(p, violations); !errors.Is(err, errQuarantined) {
+			t.Fatalf("Expected errQuarantined, got %v", err)
+		}
+	}
+
+	pending, _ := priceStorage.(storage.Quarantiner).Quarantined(types.QuarantinePending, 0)
+	if len(pending) != 2 || quarantinedCount != 2 {
+		t.Errorf("Expected 2 entries and count 2, got %d entries and count %d", len(pending), quarantinedCount)
+	}
+
+	// Once reviewed, the same sample is queued again
+	priceStorage.(storage.Quarantiner).Review(pending[1].ID, types.QuarantineRejected, "ops", "Misplaced decimal point")
+	quarantinePrice(sample, violations)
+	if pending, _ = priceStorage.(storage.Quarantiner).Quarantined(types.QuarantinePending, 0); len(pending) != 2 {
+		t.Errorf("Expected the reviewed sample queued again, got %d pending", len(pending))
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/pkg/attest"
+	"backend/pkg/decimal"
+	"backend/pkg/stream"
+	"backend/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Representation versions of price records served by the API
+const (
+	apiVersionLegacy = 1                      // Field set of frontend builds before the canonical record
+	apiVersionLatest = types.PriceInfoVersion // Canonical record with instrument, currency, unit and trading date
+)
+
+// priceMediaType vendor media type, e.g. "application/vnd.greentrace.price+json; version=2"
+const priceMediaType = "application/vnd.greentrace.price+json"
+
+// priceInfoV1 price record as served before versioning
+type priceInfoV1 struct {
+	Price         decimal.Decimal     `json:"price"`
+	Date          string              `json:"date"`
+	DailyChange   decimal.Decimal     `json:"dailyChange"`
+	MonthlyChange decimal.Decimal     `json:"monthlyChange"`
+	YearlyChange  decimal.Decimal     `json:"yearlyChange"`
+	LastUpdated   time.Time           `json:"lastUpdated"`
+	Status        string              `json:"status,omitempty"`
+	Source        string              `json:"source"`
+	Missing       []string            `json:"missing,omitempty"`
+	Synthetic     bool                `json:"synthetic,omitempty"`
+	Quotes        []types.SourceQuote `json:"quotes,omitempty"`
+	Spread        decimal.Decimal     `json:"spread"`
+	SpreadPercent decimal.Decimal     `json:"spreadPercent"`
+	Disagreement  bool                `json:"disagreement"`
+	priceEncodings
+}
+
+// toV1 legacy representation of a record
+func toV1(p types.PriceInfo) priceInfoV1 {
+	v1 := priceInfoV1{
+		Price:         p.Price,
+		Date:          p.Date,
+		DailyChange:   p.DailyChange,
+		MonthlyChange: p.MonthlyChange,
+		YearlyChange:  p.YearlyChange,
+		LastUpdated:   p.LastUpdated,
+		Status:        p.Status,
+		Source:        p.Source,
+		Missing:       p.Missing,
+		Synthetic:     p.Synthetic,
+		Quotes:        p.Quotes,
+		Spread:        p.Spread,
+		SpreadPercent: p.SpreadPercent,
+		Disagreement:  p.Disagreement,
+	}
+	v1.priceEncodings = encodePrice(p.Price)
+	return v1
+}
+
+// priceEncodings price as the integers contracts store, served in every version: priceE8 for
+// CarbonPriceOracle (8 decimals) and priceE18 for CarbonToken amounts (18 decimals). They are
+// strings because 18 decimal integers exceed the precision of JSON numbers in JavaScript.
+type priceEncodings struct {
+	PriceE8  string `json:"priceE8"`
+	PriceE18 string `json:"priceE18"`
+}
+
+// encodePrice integer encodings of a price
+func encodePrice(price decimal.Decimal) priceEncodings {
+	return priceEncodings{
+		PriceE8:  price.Scaled(decimal.OracleDecimals).String(),
+		PriceE18: price.Scaled(decimal.TokenDecimals).String(),
+	}
+}
+
+// priceInfoV2 canonical record plus the price encodings
+type priceInfoV2 struct {
+	types.PriceInfo
+	priceEncodings
+}
+
+// toV2 canonical representation of a record
+func toV2(p types.PriceInfo) priceInfoV2 {
+	p = p.Normalized()
+	return priceInfoV2{PriceInfo: p, priceEncodings: encodePrice(p.Price)}
+}
+
+// negotiateVersion representation requested with ?version=, the X-API-Version header or the version
+// parameter of an Accept media type, in that order. Without one the legacy version is served so
+// existing frontends keep working. Answers 406 for a version this server cannot produce.
+func negotiateVersion(c *gin.Context) (int, bool) {
+	requested := c.Query("version")
+	if requested == "" {
+		requested = c.GetHeader("X-API-Version")
+	}
+	if requested == "" {
+		for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+			if err == nil && mediaType == priceMediaType && params["version"] != "" {
+				requested = params["version"]
+				break
+			}
+		}
+	}
+	c.Header("Vary", "Accept, X-API-Version")
+
+	version := apiVersionLegacy
+	if requested != "" {
+		v, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(requested), "v"))
+		if err != nil || v < apiVersionLegacy || v > apiVersionLatest {
+			c.JSON(406, gin.H{
+				"error":     "Unsupported API version " + requested,
+				"supported": []int{apiVersionLegacy, apiVersionLatest},
+			})
+			return 0, false
+		}
+		version = v
+	}
+	c.Header("X-API-Version", strconv.Itoa(version))
+	return version, true
+}
+
+// renderPrice record in the representation version
+func renderPrice(p types.PriceInfo, version int) interface{} {
+	if version == apiVersionLegacy {
+		return toV1(p)
+	}
+	return toV2(p)
+}
+
+// renderPrices records in the representation version
+func renderPrices(records []types.PriceInfo, version int) []interface{} {
+	rendered := make([]interface{}, len(records))
+	for i, p := range records {
+		rendered[i] = renderPrice(p, version)
+	}
+	return rendered
+}
+
+// versionedEvent stream event carrying the price in the representation version
+type versionedEvent struct {
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// renderEvent stream event in the representation version
+func renderEvent(event stream.Event, version int) versionedEvent {
+	return versionedEvent{ID: event.ID, Type: event.Type, Time: event.Time, Data: renderPrice(event.Data, version)}
+}
+
+// signedPriceV1 legacy representation with its attestation
+type signedPriceV1 struct {
+	priceInfoV1
+	Attestation *attest.Attestation `json:"attestation,omitempty"`
+}
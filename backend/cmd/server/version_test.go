@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestPriceEncodings test every version of the latest price carries the integers contracts store
+func TestPriceEncodings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	priceInfo := types.PriceInfo{
+		Price:       decimal.MustParse("71.123456789"),
+		Date:        "January 17, 2024",
+		LastUpdated: time.Date(2024, 1, 17, 12, 0, 0, 0, time.UTC),
+		Source:      "consensus",
+	}
+	router := gin.New()
+	router.GET("/api/carbon-price", func(c *gin.Context) {
+		version, ok := negotiateVersion(c)
+		if !ok {
+			return
+		}
+		c.JSON(200, signPrice(&priceInfo, version))
+	})
+
+	tests := []struct {
+		name       string
+		query      string
+		version    string
+		instrument bool
+	}{
+		{name: "Legacy", query: "", version: "1"},
+		{name: "Canonical", query: "?version=2", version: "2", instrument: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/api/carbon-price"+tt.query, nil))
+			if w.Code != 200 || w.Header().Get("X-API-Version") != tt.version {
+				t.Fatalf("Expected 200 in version %s, got %d in version %q", tt.version, w.Code, w.Header().Get("X-API-Version"))
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Invalid JSON: %v", err)
+			}
+			if body["priceE8"] != "7112345679" || body["priceE18"] != "71123456789000000000" {
+				t.Errorf("Encodings mismatch: priceE8 %v, priceE18 %v", body["priceE8"], body["priceE18"])
+			}
+			if _, ok := body["instrument"]; ok != tt.instrument {
+				t.Errorf("Instrument present %v, expected %v", ok, tt.instrument)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package adapter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// DefaultMarket market served when the request does not name one
+const DefaultMarket = "EUA"
+
+// Request Chainlink External Adapter request body
+type Request struct {
+	ID   string                 `json:"id"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// Response Chainlink External Adapter response body
+type Response struct {
+	JobRunID   string                 `json:"jobRunID"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Result     interface{}            `json:"result,omitempty"`
+	StatusCode int                    `json:"statusCode"`
+	Status     string                 `json:"status,omitempty"`
+	Error      *Error                 `json:"error,omitempty"`
+}
+
+// Error adapter error payload
+type Error struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// Params validated request parameters
+type Params struct {
+	Currency string // Quote currency, EUR is native
+	Decimals int    // Result scaling, 0 returns the decimal price
+	Market   string // Instrument, only EUA is served
+}
+
+// Adapter builds adapter responses from the latest stored price.
+// Rates convert EUR to other currencies (1 EUR = rate units) and may be empty.
+type Adapter struct {
+	Rates  map[string]decimal.Decimal
+	MaxAge time.Duration // Reject prices older than this, 0 disables the check
+}
+
+// Handle answer an adapter request, the returned status is also the HTTP status
+func (a *Adapter) Handle(req Request, latest *types.PriceInfo) Response {
+	params, err := a.parseParams(req.Data)
+	if err != nil {
+		return errorResponse(req.ID, 400, "AdapterInputError", err.Error())
+	}
+	if latest == nil {
+		return errorResponse(req.ID, 503, "AdapterDataProviderError", "No price available")
+	}
+	if a.MaxAge > 0 && time.Since(latest.LastUpdated) > a.MaxAge {
+		return errorResponse(req.ID, 503, "AdapterDataProviderError",
+			fmt.Sprintf("Latest price from %s is older than %s", latest.LastUpdated.Format(time.RFC3339), a.MaxAge))
+	}
+
+	price := latest.Price
+	if params.Currency != "EUR" {
+		price = price.Mul(a.Rates[params.Currency])
+	}
+
+	var result interface{} = price
+	if params.Decimals > 0 {
+		// Integers beyond 2^53 lose precision in JSON numbers, return them as strings
+		result = price.Scaled(params.Decimals).String()
+	}
+
+	data := map[string]interface{}{
+		"result":      result,
+		"price":       latest.Price,
+		"currency":    params.Currency,
+		"decimals":    params.Decimals,
+		"market":      params.Market,
+		"date":        latest.Date,
+		"lastUpdated": latest.LastUpdated.Format(time.RFC3339),
+		"source":      latest.Source,
+	}
+	if latest.Synthetic {
+		data["synthetic"] = true
+	}
+	return Response{
+		JobRunID:   req.ID,
+		Data:       data,
+		Result:     result,
+		StatusCode: 200,
+		Status:     "success",
+	}
+}
+
+// parseParams read currency, decimals and market with their common aliases
+func (a *Adapter) parseParams(data map[string]interface{}) (Params, error) {
+	params := Params{Currency: "EUR", Market: DefaultMarket}
+
+	if value, ok := lookup(data, "currency", "quote", "to"); ok {
+		params.Currency = strings.ToUpper(fmt.Sprint(value))
+	}
+	if params.Currency != "EUR" {
+		if rate, ok := a.Rates[params.Currency]; !ok || rate.Sign() <= 0 {
+			return params, fmt.Errorf("Unsupported currency %q", params.Currency)
+		}
+	}
+
+	if value, ok := lookup(data, "decimals", "times"); ok {
+		decimals, err := toInt(value)
+		if err != nil || decimals < 0 || decimals > 18 {
+			return params, fmt.Errorf("Decimals must be an integer between 0 and 18")
+		}
+		params.Decimals = decimals
+	}
+
+	if value, ok := lookup(data, "market", "base", "from"); ok {
+		params.Market = strings.ToUpper(fmt.Sprint(value))
+	}
+	switch params.Market {
+	case "EUA", "EU-ETS", "CARBON":
+		params.Market = DefaultMarket
+	default:
+		return params, fmt.Errorf("Unsupported market %q", params.Market)
+	}
+	return params, nil
+}
+
+// lookup first present key
+func lookup(data map[string]interface{}, keys ...string) (interface{}, bool) {
+	for _, key := range keys {
+		if value, ok := data[key]; ok && value != nil && value != "" {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// toInt accept JSON numbers and numeric strings
+func toInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("not an integer")
+		}
+		return int(v), nil
+	case string:
+		return strconv.Atoi(v)
+	default:
+		return 0, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+// errorResponse adapter-conformant error
+func errorResponse(jobRunID string, status int, name, message string) Response {
+	return Response{
+		JobRunID:   jobRunID,
+		StatusCode: status,
+		Status:     "errored",
+		Error:      &Error{Name: name, Message: message},
+	}
+}
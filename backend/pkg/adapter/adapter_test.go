@@ -0,0 +1,56 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// TestHandle test adapter request/response contract
+func TestHandle(t *testing.T) {
+	a := &Adapter{Rates: map[string]decimal.Decimal{"USD": decimal.MustParse("1.1")}}
+	latest := &types.PriceInfo{Price: decimal.MustParse("85.23"), Date: "January 15, 2024", LastUpdated: time.Now(), Source: "tradingeconomics"}
+
+	tests := []struct {
+		name   string
+		data   map[string]interface{}
+		status int
+		result interface{}
+		error  string
+	}{
+		{name: "Defaults", data: nil, status: 200, result: decimal.MustParse("85.23")},
+		{name: "Oracle decimals", data: map[string]interface{}{"decimals": 8}, status: 200, result: "8523000000"},
+		{name: "Token decimals", data: map[string]interface{}{"decimals": "18", "market": "eua"}, status: 200, result: "85230000000000000000"},
+		{name: "Converted currency", data: map[string]interface{}{"currency": "usd", "decimals": 2}, status: 200, result: "9375"},
+		{name: "Unknown currency", data: map[string]interface{}{"currency": "GBP"}, status: 400, error: "AdapterInputError"},
+		{name: "Unknown market", data: map[string]interface{}{"market": "CCA"}, status: 400, error: "AdapterInputError"},
+		{name: "Bad decimals", data: map[string]interface{}{"decimals": 1.5}, status: 400, error: "AdapterInputError"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := a.Handle(Request{ID: "job-1", Data: tt.data}, latest)
+			if resp.JobRunID != "job-1" || resp.StatusCode != tt.status {
+				t.Fatalf("Unexpected response: %+v", resp)
+			}
+			if tt.error != "" {
+				if resp.Error == nil || resp.Error.Name != tt.error {
+					t.Errorf("Expected %s error, got %+v", tt.error, resp.Error)
+				}
+				return
+			}
+			if resp.Result != tt.result || resp.Data["result"] != tt.result {
+				t.Errorf("Result mismatch: expected %v, got %v / %v", tt.result, resp.Result, resp.Data["result"])
+			}
+		})
+	}
+
+	if resp := a.Handle(Request{ID: "job-2"}, nil); resp.StatusCode != 503 || resp.Error == nil {
+		t.Errorf("Expected data provider error without price, got %+v", resp)
+	}
+	stale := &Adapter{MaxAge: time.Hour}
+	if resp := stale.Handle(Request{ID: "job-3"}, &types.PriceInfo{Price: decimal.New(70, 0), LastUpdated: time.Now().Add(-2 * time.Hour)}); resp.StatusCode != 503 {
+		t.Errorf("Expected stale price error, got %+v", resp)
+	}
+}
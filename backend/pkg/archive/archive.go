@@ -0,0 +1,230 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound no archived page with the requested ID
+var ErrNotFound = errors.New("Archived page not found")
+
+// idLayout fetch time part of page IDs, sorts chronologically
+const idLayout = "20060102T150405.000000000Z"
+
+// Page metadata of an archived response
+type Page struct {
+	ID        string      `json:"id"`
+	Source    string      `json:"source"`
+	URL       string      `json:"url"`
+	Status    int         `json:"status"`
+	Headers   http.Header `json:"headers"`
+	SHA256    string      `json:"sha256"` // Hash of the uncompressed body
+	Size      int         `json:"size"`   // Uncompressed body size in bytes
+	FetchedAt time.Time   `json:"fetchedAt"`
+}
+
+// SensitiveHeaders credentials never written to disk: session cookies set by a login and
+// authorization sent with the request
+var SensitiveHeaders = []string{"Set-Cookie", "Cookie", "Authorization", "Proxy-Authorization"}
+
+// RedactHeaders copy of headers without SensitiveHeaders
+func RedactHeaders(headers http.Header) http.Header {
+	redacted := headers.Clone()
+	for _, name := range SensitiveHeaders {
+		redacted.Del(name)
+	}
+	return redacted
+}
+
+// Filter page selection, zero fields match everything
+type Filter struct {
+	Source string
+	From   time.Time // First fetch day, inclusive
+	To     time.Time // Last fetch day, inclusive
+}
+
+// Archive raw responses stored on disk, one directory per fetch day holding
+// <id>.json metadata and the gzip compressed body <id>.gz
+type Archive struct {
+	dir string
+}
+
+// New open or create the archive directory
+func New(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("Cannot create archive directory %s: %w", dir, err)
+	}
+	return &Archive{dir: dir}, nil
+}
+
+// Save store a response body, ID, hash and size are filled in and SensitiveHeaders removed
+func (a *Archive) Save(page Page, body []byte) (Page, error) {
+	page.Headers = RedactHeaders(page.Headers)
+	if page.FetchedAt.IsZero() {
+		page.FetchedAt = time.Now()
+	}
+	page.FetchedAt = page.FetchedAt.UTC()
+	page.ID = page.FetchedAt.Format(idLayout) + "-" + sanitize(page.Source)
+	sum := sha256.Sum256(body)
+	page.SHA256 = hex.EncodeToString(sum[:])
+	page.Size = len(body)
+
+	day := filepath.Join(a.dir, page.FetchedAt.Format("2006-01-02"))
+	if err := os.MkdirAll(day, 0o755); err != nil {
+		return page, err
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(body); err != nil {
+		return page, err
+	}
+	if err := zw.Close(); err != nil {
+		return page, err
+	}
+	if err := os.WriteFile(filepath.Join(day, page.ID+".gz"), compressed.Bytes(), 0o644); err != nil {
+		return page, err
+	}
+
+	meta, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		return page, err
+	}
+	// Metadata last, so a listed page always has its body
+	return page, os.WriteFile(filepath.Join(day, page.ID+".json"), meta, 0o644)
+}
+
+// Load read the metadata and uncompressed body of a page
+func (a *Archive) Load(id string) (Page, []byte, error) {
+	var page Page
+	if len(id) < 8 || strings.ContainsAny(id, `/\`) {
+		return page, nil, ErrNotFound
+	}
+	fetched, err := time.Parse(idLayout, strings.SplitN(id, "-", 2)[0])
+	if err != nil {
+		return page, nil, ErrNotFound
+	}
+	base := filepath.Join(a.dir, fetched.Format("2006-01-02"), id)
+
+	meta, err := os.ReadFile(base + ".json")
+	if os.IsNotExist(err) {
+		return page, nil, ErrNotFound
+	}
+	if err != nil {
+		return page, nil, err
+	}
+	if err := json.Unmarshal(meta, &page); err != nil {
+		return page, nil, fmt.Errorf("Invalid archive metadata %s: %w", id, err)
+	}
+
+	f, err := os.Open(base + ".gz")
+	if err != nil {
+		return page, nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return page, nil, err
+	}
+	defer zr.Close()
+	body, err := io.ReadAll(zr)
+	return page, body, err
+}
+
+// List pages matching the filter in fetch order
+func (a *Archive) List(filter Filter) ([]Page, error) {
+	days, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+	from, to := "", ""
+	if !filter.From.IsZero() {
+		from = filter.From.UTC().Format("2006-01-02")
+	}
+	if !filter.To.IsZero() {
+		to = filter.To.UTC().Format("2006-01-02")
+	}
+
+	pages := make([]Page, 0)
+	for _, day := range days {
+		if !day.IsDir() || (from != "" && day.Name() < from) || (to != "" && day.Name() > to) {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(a.dir, day.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry.Name(), ".json") {
+				continue
+			}
+			meta, err := os.ReadFile(filepath.Join(a.dir, day.Name(), entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			var page Page
+			if err := json.Unmarshal(meta, &page); err != nil {
+				return nil, fmt.Errorf("Invalid archive metadata %s: %w", entry.Name(), err)
+			}
+			if filter.Source == "" || page.Source == filter.Source {
+				pages = append(pages, page)
+			}
+		}
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].ID < pages[j].ID })
+	return pages, nil
+}
+
+// Prune delete the pages fetched on days before the day of before, returns the number deleted.
+// Whole day directories are removed, so a page is kept until its day is entirely older.
+func (a *Archive) Prune(before time.Time) (int, error) {
+	days, err := os.ReadDir(a.dir)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := before.UTC().Format("2006-01-02")
+	pruned := 0
+	for _, day := range days {
+		if !day.IsDir() || day.Name() >= cutoff {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", day.Name()); err != nil {
+			continue // Not an archive day, leave it
+		}
+		entries, err := os.ReadDir(filepath.Join(a.dir, day.Name()))
+		if err != nil {
+			return pruned, err
+		}
+		if err := os.RemoveAll(filepath.Join(a.dir, day.Name())); err != nil {
+			return pruned, err
+		}
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), ".json") {
+				pruned++
+			}
+		}
+	}
+	return pruned, nil
+}
+
+// sanitize keep source names safe for file names
+func sanitize(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
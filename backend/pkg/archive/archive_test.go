@@ -0,0 +1,115 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestArchive test save, load and filtered listing
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	pages, err := New(dir)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	base := time.Date(2024, 1, 15, 17, 30, 0, 0, time.UTC)
+	body := bytes.Repeat([]byte("<td>EUA Spot</td><td>70,12</td>"), 100)
+
+	saved, err := pages.Save(Page{
+		Source:    "eex",
+		URL:       "https://example.com/settlements",
+		Status:    200,
+		Headers:   http.Header{"Content-Type": []string{"text/html"}, "Set-Cookie": []string{"session=secret; HttpOnly"}},
+		FetchedAt: base,
+	}, body)
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if saved.ID == "" || saved.Size != len(body) || len(saved.SHA256) != 64 {
+		t.Fatalf("Metadata not filled in: %+v", saved)
+	}
+	pages.Save(Page{Source: "tradingeconomics", Status: 403, FetchedAt: base.Add(time.Minute)}, []byte("denied"))
+	pages.Save(Page{Source: "eex", Status: 200, FetchedAt: base.Add(24 * time.Hour)}, []byte("next day"))
+
+	page, loaded, err := pages.Load(saved.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !bytes.Equal(loaded, body) || page.Headers.Get("Content-Type") != "text/html" || !page.FetchedAt.Equal(base) {
+		t.Errorf("Round trip mismatch: %+v", page)
+	}
+	if page.Headers.Get("Set-Cookie") != "" || saved.Headers.Get("Set-Cookie") != "" {
+		t.Errorf("Session cookie archived: %v", page.Headers)
+	}
+	if meta, _ := os.ReadFile(filepath.Join(dir, "2024-01-15", saved.ID+".json")); bytes.Contains(meta, []byte("secret")) {
+		t.Errorf("Session cookie written to %s.json", saved.ID)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{name: "All", filter: Filter{}, want: 3},
+		{name: "Source", filter: Filter{Source: "eex"}, want: 2},
+		{name: "From", filter: Filter{From: base.Add(24 * time.Hour)}, want: 1},
+		{name: "To", filter: Filter{To: base}, want: 2},
+		{name: "Source and day", filter: Filter{Source: "tradingeconomics", From: base, To: base}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := pages.List(tt.filter)
+			if err != nil || len(list) != tt.want {
+				t.Fatalf("Expected %d pages, got %d (%v)", tt.want, len(list), err)
+			}
+			for i := 1; i < len(list); i++ {
+				if list[i].FetchedAt.Before(list[i-1].FetchedAt) {
+					t.Errorf("Pages not in fetch order: %+v", list)
+				}
+			}
+		})
+	}
+
+	for _, id := range []string{"missing", "20240115T173000.000000000Z-none", "../../etc/passwd"} {
+		if _, _, err := pages.Load(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Load(%q): expected ErrNotFound, got %v", id, err)
+		}
+	}
+}
+
+// TestPrune test pages of days before the cutoff day are deleted and later ones kept
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	pages, err := New(dir)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	base := time.Date(2024, 1, 15, 17, 30, 0, 0, time.UTC)
+	for _, fetched := range []time.Time{base.Add(-48 * time.Hour), base.Add(-24 * time.Hour), base.Add(-23 * time.Hour), base, base.Add(time.Hour)} {
+		if _, err := pages.Save(Page{Source: "eex", Status: 200, FetchedAt: fetched}, []byte("page")); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "notes"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := pages.Prune(base.Add(-time.Hour))
+	if err != nil || pruned != 3 {
+		t.Fatalf("Expected 3 pages pruned, got %d (%v)", pruned, err)
+	}
+	if list, _ := pages.List(Filter{}); len(list) != 2 || !list[0].FetchedAt.Equal(base) {
+		t.Errorf("Expected the 2 pages of the cutoff day kept, got %+v", list)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes")); err != nil {
+		t.Errorf("Directory not named after a day removed: %v", err)
+	}
+	if pruned, err := pages.Prune(base.Add(-time.Hour)); err != nil || pruned != 0 {
+		t.Errorf("Second prune: expected 0, got %d (%v)", pruned, err)
+	}
+}
@@ -0,0 +1,108 @@
+package attest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"backend/pkg/decimal"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// PriceDecimals fixed-point decimals of the signed price, matches CarbonPriceOracle
+const PriceDecimals = decimal.OracleDecimals
+
+// PriceType EIP-712 type of the signed struct
+const PriceType = "CarbonPrice(uint256 price,uint256 tradingDate,uint256 timestamp)"
+
+// Domain EIP-712 domain. VerifyingContract is optional and left out of the domain type when empty.
+type Domain struct {
+	Name              string `json:"name"`
+	Version           string `json:"version"`
+	ChainID           int64  `json:"chainId"`
+	VerifyingContract string `json:"verifyingContract,omitempty"`
+}
+
+// domainType EIP-712 type string of the domain
+func (d Domain) domainType() string {
+	if d.VerifyingContract == "" {
+		return "EIP712Domain(string name,string version,uint256 chainId)"
+	}
+	return "EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"
+}
+
+// Separator EIP-712 domain separator
+func (d Domain) Separator() ([]byte, error) {
+	parts := [][]byte{
+		keccak256([]byte(d.domainType())),
+		keccak256([]byte(d.Name)),
+		keccak256([]byte(d.Version)),
+		uint256(big.NewInt(d.ChainID)),
+	}
+	if d.VerifyingContract != "" {
+		address, err := hex.DecodeString(strings.TrimPrefix(d.VerifyingContract, "0x"))
+		if err != nil || len(address) != 20 {
+			return nil, fmt.Errorf("Invalid verifying contract address %q", d.VerifyingContract)
+		}
+		parts = append(parts, leftPad(address))
+	}
+	return keccak256(parts...), nil
+}
+
+// TypedPrice values of the signed struct
+type TypedPrice struct {
+	Price       *big.Int // Price with PriceDecimals decimals
+	TradingDate int64    // Unix seconds of the trading date at midnight UTC
+	Timestamp   int64    // Unix seconds of the price update
+}
+
+// StructHash EIP-712 hashStruct of the price
+func (p TypedPrice) StructHash() []byte {
+	return keccak256(
+		keccak256([]byte(PriceType)),
+		uint256(p.Price),
+		uint256(big.NewInt(p.TradingDate)),
+		uint256(big.NewInt(p.Timestamp)),
+	)
+}
+
+// Digest EIP-712 digest: keccak256("\x19\x01" || domainSeparator || hashStruct(price))
+func Digest(domain Domain, price TypedPrice) ([]byte, error) {
+	separator, err := domain.Separator()
+	if err != nil {
+		return nil, err
+	}
+	return keccak256([]byte{0x19, 0x01}, separator, price.StructHash()), nil
+}
+
+// ScalePrice convert a price to the integer with PriceDecimals decimals that CarbonPriceOracle
+// stores, so 85.23 becomes exactly 8523000000
+func ScalePrice(price decimal.Decimal) (*big.Int, error) {
+	if price.Sign() < 0 {
+		return nil, fmt.Errorf("Cannot scale negative price %s", price)
+	}
+	return price.Scaled(PriceDecimals), nil
+}
+
+// keccak256 Ethereum legacy Keccak-256 of the concatenated inputs
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// uint256 big-endian 32 byte encoding
+func uint256(n *big.Int) []byte {
+	return leftPad(n.Bytes())
+}
+
+// leftPad pad to a 32 byte word
+func leftPad(b []byte) []byte {
+	word := make([]byte, 32)
+	copy(word[32-len(b):], b)
+	return word
+}
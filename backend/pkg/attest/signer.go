@@ -0,0 +1,230 @@
+package attest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/pkg/types"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+)
+
+// Key secp256k1 signing key
+type Key struct {
+	ID      string
+	Address string // EIP-55 checksummed Ethereum address
+	Active  bool   // Only the active key signs, retired keys are published for verification
+	private *secp256k1.PrivateKey
+}
+
+// PublicKey uncompressed public key as 0x hex
+func (k *Key) PublicKey() string {
+	return "0x" + hex.EncodeToString(k.private.PubKey().SerializeUncompressed())
+}
+
+// KeyConfig key entry of the keys file
+type KeyConfig struct {
+	ID         string `json:"id"`
+	PrivateKey string `json:"privateKey"` // 0x prefixed or bare hex
+	Active     bool   `json:"active"`
+}
+
+// Attestation signed price returned next to the price
+type Attestation struct {
+	Price       string `json:"price"`       // uint256 price with 8 decimals, decimal string
+	Decimals    int    `json:"decimals"`    // Always PriceDecimals
+	TradingDate int64  `json:"tradingDate"` // Unix seconds of the trading date
+	Timestamp   int64  `json:"timestamp"`   // Unix seconds of the price update
+	Digest      string `json:"digest"`      // EIP-712 digest that was signed
+	Signature   string `json:"signature"`   // 65 byte r || s || v, v is 27 or 28
+	Signer      string `json:"signer"`      // Signer address
+	KeyID       string `json:"keyId"`       // Signing key ID
+}
+
+// Signer signs prices with the active key of a key set that can be reloaded for rotation
+type Signer struct {
+	domain Domain
+
+	mu     sync.RWMutex
+	keys   []*Key
+	active *Key
+}
+
+// ErrNoActiveKey no key is marked active
+var ErrNoActiveKey = errors.New("No active signing key")
+
+// NewSigner create signer for the domain from key configs
+func NewSigner(domain Domain, configs []KeyConfig) (*Signer, error) {
+	s := &Signer{domain: domain}
+	if err := s.SetKeys(configs); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Domain EIP-712 domain of the signer
+func (s *Signer) Domain() Domain {
+	return s.domain
+}
+
+// SetKeys replace the key set. Exactly one key must be active; a single key is active implicitly.
+func (s *Signer) SetKeys(configs []KeyConfig) error {
+	if len(configs) == 1 {
+		configs[0].Active = true
+	}
+	keys := make([]*Key, 0, len(configs))
+	var active *Key
+	for i, config := range configs {
+		raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(config.PrivateKey), "0x"))
+		if err != nil || len(raw) != 32 {
+			return fmt.Errorf("Key %d: private key must be 32 bytes of hex", i)
+		}
+		key := &Key{
+			ID:      config.ID,
+			Active:  config.Active,
+			private: secp256k1.PrivKeyFromBytes(raw),
+		}
+		key.Address = PubKeyAddress(key.private.PubKey())
+		if key.ID == "" {
+			key.ID = key.Address
+		}
+		if key.Active {
+			if active != nil {
+				return fmt.Errorf("Keys %s and %s are both active", active.ID, key.ID)
+			}
+			active = key
+		}
+		keys = append(keys, key)
+	}
+	if active == nil {
+		return ErrNoActiveKey
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.active = active
+	s.mu.Unlock()
+	return nil
+}
+
+// Keys snapshot of the key set
+func (s *Signer) Keys() []*Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]*Key, len(s.keys))
+	copy(keys, s.keys)
+	return keys
+}
+
+// Sign attest a stored price with the active key
+func (s *Signer) Sign(priceInfo types.PriceInfo) (*Attestation, error) {
+	s.mu.RLock()
+	key := s.active
+	s.mu.RUnlock()
+	if key == nil {
+		return nil, ErrNoActiveKey
+	}
+
+	scaled, err := ScalePrice(priceInfo.Price)
+	if err != nil {
+		return nil, err
+	}
+	typed := TypedPrice{
+		Price:       scaled,
+		TradingDate: priceInfo.TradingDay().Unix(),
+		Timestamp:   priceInfo.LastUpdated.Unix(),
+	}
+	digest, err := Digest(s.domain, typed)
+	if err != nil {
+		return nil, err
+	}
+
+	// SignCompact returns v || r || s with v = 27 + recovery id, Ethereum expects r || s || v
+	compact := ecdsa.SignCompact(key.private, digest, false)
+	signature := append(compact[1:65:65], compact[0])
+
+	return &Attestation{
+		Price:       scaled.String(),
+		Decimals:    PriceDecimals,
+		TradingDate: typed.TradingDate,
+		Timestamp:   typed.Timestamp,
+		Digest:      "0x" + hex.EncodeToString(digest),
+		Signature:   "0x" + hex.EncodeToString(signature),
+		Signer:      key.Address,
+		KeyID:       key.ID,
+	}, nil
+}
+
+// Recover signer address of an r || s || v signature over digest
+func Recover(digest, signature []byte) (string, error) {
+	if len(signature) != 65 {
+		return "", errors.New("Signature must be 65 bytes")
+	}
+	compact := append([]byte{signature[64]}, signature[:64]...)
+	pub, _, err := ecdsa.RecoverCompact(compact, digest)
+	if err != nil {
+		return "", err
+	}
+	return PubKeyAddress(pub), nil
+}
+
+// PubKeyAddress EIP-55 checksummed Ethereum address of a public key
+func PubKeyAddress(pub *secp256k1.PublicKey) string {
+	address := hex.EncodeToString(keccak256(pub.SerializeUncompressed()[1:])[12:])
+	hash := hex.EncodeToString(keccak256([]byte(address)))
+	checksummed := []byte(address)
+	for i, c := range checksummed {
+		if c >= 'a' && c <= 'f' && hash[i] >= '8' {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(checksummed)
+}
+
+// LoadKeysFile read key configs from a JSON file: [{"id":..., "privateKey":..., "active":...}]
+func LoadKeysFile(path string) ([]KeyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs []KeyConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("Invalid keys file %s: %w", path, err)
+	}
+	return configs, nil
+}
+
+// WatchKeysFile reload the key set whenever the file modification time changes, for rotation
+// without restart. onReload is called with the reload error, if any. Stop by closing done.
+func (s *Signer) WatchKeysFile(path string, interval time.Duration, done <-chan struct{}, onReload func(error)) {
+	var lastMod time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastMod = info.ModTime()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil || !info.ModTime().After(lastMod) {
+				continue
+			}
+			lastMod = info.ModTime()
+			configs, err := LoadKeysFile(path)
+			if err == nil {
+				err = s.SetKeys(configs)
+			}
+			onReload(err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package attest
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+const testKey = "0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+// TestDomainSeparator test against the EIP-712 specification example
+func TestDomainSeparator(t *testing.T) {
+	separator, err := Domain{
+		Name:              "Ether Mail",
+		Version:           "1",
+		ChainID:           1,
+		VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+	}.Separator()
+	if err != nil {
+		t.Fatalf("Separator failed: %v", err)
+	}
+	if got := hex.EncodeToString(separator); got != "f2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f" {
+		t.Errorf("Domain separator mismatch: %s", got)
+	}
+}
+
+// TestSignAndRecover test attestation signature recovers to the signer address
+func TestSignAndRecover(t *testing.T) {
+	signer, err := NewSigner(Domain{Name: "GreenTrace Carbon Price", Version: "1", ChainID: 11155111},
+		[]KeyConfig{{ID: "k1", PrivateKey: testKey}})
+	if err != nil {
+		t.Fatalf("NewSigner failed: %v", err)
+	}
+	if address := signer.Keys()[0].Address; address != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Fatalf("Address mismatch: %s", address)
+	}
+
+	attestation, err := signer.Sign(types.PriceInfo{
+		Price:       decimal.MustParse("85.23"),
+		Date:        "January 15, 2024",
+		LastUpdated: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if attestation.Price != "8523000000" || attestation.TradingDate != 1705276800 || attestation.Timestamp != 1705320000 {
+		t.Errorf("Unexpected typed values: %+v", attestation)
+	}
+
+	digest, _ := hex.DecodeString(strings.TrimPrefix(attestation.Digest, "0x"))
+	signature, _ := hex.DecodeString(strings.TrimPrefix(attestation.Signature, "0x"))
+	if v := signature[64]; v != 27 && v != 28 {
+		t.Errorf("Signature v must be 27 or 28, got %d", v)
+	}
+	recovered, err := Recover(digest, signature)
+	if err != nil || recovered != attestation.Signer {
+		t.Errorf("Recovered %s (%v), expected %s", recovered, err, attestation.Signer)
+	}
+}
+
+// TestRotation test that only the active key signs and retired keys stay published
+func TestRotation(t *testing.T) {
+	domain := Domain{Name: "GreenTrace Carbon Price", Version: "1", ChainID: 1}
+	signer, err := NewSigner(domain, []KeyConfig{{ID: "old", PrivateKey: testKey}})
+	if err != nil {
+		t.Fatalf("NewSigner failed: %v", err)
+	}
+	err = signer.SetKeys([]KeyConfig{
+		{ID: "old", PrivateKey: testKey},
+		{ID: "new", PrivateKey: "0x" + strings.Repeat("11", 32), Active: true},
+	})
+	if err != nil {
+		t.Fatalf("SetKeys failed: %v", err)
+	}
+	attestation, err := signer.Sign(types.PriceInfo{Price: decimal.New(70, 0), Date: "January 15, 2024"})
+	if err != nil || attestation.KeyID != "new" {
+		t.Errorf("Expected new key to sign, got %+v (%v)", attestation, err)
+	}
+	if len(signer.Keys()) != 2 {
+		t.Error("Retired key should remain published")
+	}
+
+	if err := signer.SetKeys([]KeyConfig{{ID: "a", PrivateKey: testKey}, {ID: "b", PrivateKey: testKey}}); err != ErrNoActiveKey {
+		t.Errorf("Expected ErrNoActiveKey, got %v", err)
+	}
+}
+
+// TestScalePrice test exact 8 decimal scaling
+func TestScalePrice(t *testing.T) {
+	for price, expected := range map[string]string{"85.23": "8523000000", "0.1": "10000000", "70.12345678": "7012345678", "1.005": "100500000"} {
+		scaled, err := ScalePrice(decimal.MustParse(price))
+		if err != nil || scaled.String() != expected {
+			t.Errorf("ScalePrice(%s) = %v (%v), expected %s", price, scaled, err, expected)
+		}
+	}
+}
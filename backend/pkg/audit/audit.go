@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"backend/pkg/logger"
+)
+
+// Entry one audited action
+type Entry struct {
+	Time    time.Time              `json:"time"`
+	Actor   string                 `json:"actor"`             // Key name and ID of the caller
+	Action  string                 `json:"action"`            // e.g. "POST /admin/keys"
+	Status  int                    `json:"status"`            // HTTP status of the response
+	IP      string                 `json:"ip,omitempty"`      // Client IP
+	Details map[string]interface{} `json:"details,omitempty"` // Action specific context
+}
+
+// Log audit trail kept in memory for the admin API and appended as JSON lines to a file when configured
+type Log struct {
+	mu      sync.Mutex
+	recent  []Entry
+	maxSize int
+	file    *os.File
+}
+
+// NewLog create audit log keeping maxSize recent entries, path may be empty
+func NewLog(path string, maxSize int) (*Log, error) {
+	l := &Log{maxSize: maxSize}
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		l.file = f
+	}
+	return l, nil
+}
+
+// Record append an entry
+func (l *Log) Record(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	data, _ := json.Marshal(entry)
+	logger.InfoLogger.Printf("AUDIT %s", data)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.recent = append(l.recent, entry)
+	if len(l.recent) > l.maxSize {
+		l.recent = l.recent[len(l.recent)-l.maxSize:]
+	}
+	if l.file != nil {
+		if _, err := l.file.Write(append(data, '\n')); err != nil {
+			logger.ErrorLogger.Printf("Failed to write audit log: %v", err)
+		}
+	}
+}
+
+// Recent latest entries, newest first
+func (l *Log) Recent(limit int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if limit <= 0 || limit > len(l.recent) {
+		limit = len(l.recent)
+	}
+	entries := make([]Entry, 0, limit)
+	for i := len(l.recent) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, l.recent[i])
+	}
+	return entries
+}
+
+// Close close the audit file
+func (l *Log) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scopes, admin implies update and update implies read
+const (
+	ScopeRead   = "read"
+	ScopeUpdate = "update"
+	ScopeAdmin  = "admin"
+)
+
+// scopeRank ordering used for scope implication
+var scopeRank = map[string]int{ScopeRead: 1, ScopeUpdate: 2, ScopeAdmin: 3}
+
+// keyPrefix marks generated keys so they are recognizable in logs and secret scanners
+const keyPrefix = "gt_"
+
+// Key API key metadata, the secret itself is only kept as a SHA-256 hash
+type Key struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash,omitempty"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"createdAt"`
+	Revoked   bool      `json:"revoked"`
+	Static    bool      `json:"static"` // Loaded from configuration, cannot be revoked through the API
+}
+
+// Allows whether the key grants scope
+func (k *Key) Allows(scope string) bool {
+	if k.Revoked {
+		return false
+	}
+	for _, s := range k.Scopes {
+		if scopeRank[s] >= scopeRank[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors
+var (
+	ErrUnknownKey  = errors.New("Unknown API key")
+	ErrStaticKey   = errors.New("Key is defined in configuration and cannot be revoked here")
+	ErrInvalidName = errors.New("Key name is required")
+)
+
+// HashKey SHA-256 hex of a plaintext key
+func HashKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidateScopes check scope names
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return errors.New("At least one scope is required")
+	}
+	for _, s := range scopes {
+		if _, ok := scopeRank[s]; !ok {
+			return fmt.Errorf("Unknown scope %q, expected read, update or admin", s)
+		}
+	}
+	return nil
+}
+
+// KeyStore API keys from configuration plus keys managed at runtime, persisted to a JSON file when path is set
+type KeyStore struct {
+	mu     sync.RWMutex
+	keys   map[string]*Key // By ID
+	byHash map[string]*Key
+	path   string
+}
+
+// NewKeyStore create key store with static keys, loading managed keys from path if it exists
+func NewKeyStore(static []Key, path string) (*KeyStore, error) {
+	ks := &KeyStore{
+		keys:   make(map[string]*Key),
+		byHash: make(map[string]*Key),
+		path:   path,
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			var managed []Key
+			if err := json.Unmarshal(data, &managed); err != nil {
+				return nil, fmt.Errorf("Invalid API keys file %s: %w", path, err)
+			}
+			for i := range managed {
+				ks.add(&managed[i])
+			}
+		}
+	}
+	for i := range static {
+		key := static[i]
+		key.Static = true
+		if key.ID == "" {
+			key.ID = "static-" + key.Name
+		}
+		ks.add(&key)
+	}
+	return ks, nil
+}
+
+// ParseStaticKeys parse "name:scope|scope:sha256hex" entries separated by commas
+func ParseStaticKeys(value string) ([]Key, error) {
+	var keys []Key
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || len(parts[2]) != 64 {
+			return nil, fmt.Errorf("Invalid API key entry %q, expected name:scopes:sha256hex", entry)
+		}
+		scopes := strings.Split(parts[1], "|")
+		if err := ValidateScopes(scopes); err != nil {
+			return nil, err
+		}
+		keys = append(keys, Key{Name: parts[0], Scopes: scopes, Hash: strings.ToLower(parts[2])})
+	}
+	return keys, nil
+}
+
+// add index key, caller holds the lock or owns the store
+func (ks *KeyStore) add(key *Key) {
+	ks.keys[key.ID] = key
+	ks.byHash[key.Hash] = key
+}
+
+// Authenticate resolve a plaintext key, nil when unknown or revoked
+func (ks *KeyStore) Authenticate(plaintext string) *Key {
+	if plaintext == "" {
+		return nil
+	}
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.byHash[HashKey(plaintext)]
+	if !ok || key.Revoked {
+		return nil
+	}
+	copied := *key
+	return &copied
+}
+
+// Create generate a new key, the plaintext is returned once and never stored
+func (ks *KeyStore) Create(name string, scopes []string) (string, *Key, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", nil, ErrInvalidName
+	}
+	if err := ValidateScopes(scopes); err != nil {
+		return "", nil, err
+	}
+	plaintext, err := GenerateKey()
+	if err != nil {
+		return "", nil, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	key := &Key{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Hash:      HashKey(plaintext),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.add(key)
+	if err := ks.saveLocked(); err != nil {
+		delete(ks.keys, key.ID)
+		delete(ks.byHash, key.Hash)
+		return "", nil, err
+	}
+	copied := *key
+	copied.Hash = ""
+	return plaintext, &copied, nil
+}
+
+// Revoke disable a managed key
+func (ks *KeyStore) Revoke(id string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	key, ok := ks.keys[id]
+	if !ok {
+		return ErrUnknownKey
+	}
+	if key.Static {
+		return ErrStaticKey
+	}
+	key.Revoked = true
+	return ks.saveLocked()
+}
+
+// List all keys ordered by creation time, without hashes
+func (ks *KeyStore) List() []Key {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	keys := make([]Key, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		copied := *key
+		copied.Hash = ""
+		keys = append(keys, copied)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+// Empty whether no usable key exists
+func (ks *KeyStore) Empty() bool {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, key := range ks.keys {
+		if !key.Revoked {
+			return false
+		}
+	}
+	return true
+}
+
+// saveLocked persist managed keys, caller holds the write lock
+func (ks *KeyStore) saveLocked() error {
+	if ks.path == "" {
+		return nil
+	}
+	managed := make([]*Key, 0)
+	for _, key := range ks.keys {
+		if !key.Static {
+			managed = append(managed, key)
+		}
+	}
+	data, err := json.MarshalIndent(managed, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ks.path), 0o755); err != nil {
+		return err
+	}
+	tmp := ks.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ks.path)
+}
+
+// GenerateKey random plaintext key
+func GenerateKey() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return keyPrefix + hex.EncodeToString(secret), nil
+}
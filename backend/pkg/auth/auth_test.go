@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestKeyStore test static keys, managed keys, scopes and persistence
+func TestKeyStore(t *testing.T) {
+	static, err := ParseStaticKeys("ops:admin:" + HashKey("ops-secret") + ", reader:read:" + HashKey("read-secret"))
+	if err != nil {
+		t.Fatalf("ParseStaticKeys failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store, err := NewKeyStore(static, path)
+	if err != nil {
+		t.Fatalf("NewKeyStore failed: %v", err)
+	}
+
+	ops := store.Authenticate("ops-secret")
+	if ops == nil || !ops.Allows(ScopeUpdate) || !ops.Allows(ScopeRead) {
+		t.Fatalf("Admin key should allow every scope: %+v", ops)
+	}
+	reader := store.Authenticate("read-secret")
+	if reader == nil || reader.Allows(ScopeUpdate) {
+		t.Errorf("Read key must not allow update: %+v", reader)
+	}
+	if store.Authenticate("wrong") != nil || store.Authenticate("") != nil {
+		t.Error("Unknown keys must not authenticate")
+	}
+
+	plaintext, key, err := store.Create("cron", []string{ScopeUpdate})
+	if err != nil || !strings.HasPrefix(plaintext, "gt_") || key.Hash != "" {
+		t.Fatalf("Create failed: %v %+v", err, key)
+	}
+	if err := store.Revoke(reader.ID); err != ErrStaticKey {
+		t.Errorf("Static keys must not be revocable, got %v", err)
+	}
+
+	// Managed keys survive a restart, revocation too
+	reloaded, err := NewKeyStore(static, path)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if k := reloaded.Authenticate(plaintext); k == nil || !k.Allows(ScopeUpdate) {
+		t.Fatalf("Managed key lost after reload: %+v", k)
+	}
+	if err := reloaded.Revoke(key.ID); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if reloaded.Authenticate(plaintext) != nil {
+		t.Error("Revoked key must not authenticate")
+	}
+	if _, _, err := reloaded.Create("bad", []string{"write"}); err == nil {
+		t.Error("Unknown scope should be rejected")
+	}
+}
@@ -0,0 +1,135 @@
+package candle
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// Interval units
+const (
+	UnitDay   = "d"
+	UnitWeek  = "w"
+	UnitMonth = "M"
+)
+
+// Interval candle width, e.g. 1d, 2w, 1M
+type Interval struct {
+	Count int
+	Unit  string
+}
+
+// String format interval as it is parsed
+func (i Interval) String() string {
+	return strconv.Itoa(i.Count) + i.Unit
+}
+
+// ParseInterval parse "1d", "1w", "1M" (or "1mo"); a missing count means 1
+func ParseInterval(text string) (Interval, error) {
+	text = strings.TrimSpace(text)
+	digits := 0
+	for digits < len(text) && text[digits] >= '0' && text[digits] <= '9' {
+		digits++
+	}
+	interval := Interval{Count: 1, Unit: text[digits:]}
+	if digits > 0 {
+		interval.Count, _ = strconv.Atoi(text[:digits])
+	}
+	if interval.Unit == "mo" {
+		interval.Unit = UnitMonth
+	}
+	switch interval.Unit {
+	case UnitDay, UnitWeek, UnitMonth:
+	default:
+		return Interval{}, fmt.Errorf("Invalid interval %q, expected e.g. 1d, 1w or 1M", text)
+	}
+	if interval.Count < 1 || interval.Count > 366 {
+		return Interval{}, fmt.Errorf("Invalid interval count in %q", text)
+	}
+	return interval, nil
+}
+
+// epochMonday first Monday after the Unix epoch, anchors multi-day and multi-week buckets
+var epochMonday = time.Date(1970, 1, 5, 0, 0, 0, 0, time.UTC)
+
+// bucketStart first calendar day of the bucket containing day
+func (i Interval) bucketStart(day time.Time) time.Time {
+	switch i.Unit {
+	case UnitWeek:
+		weeks := int(day.Sub(epochMonday).Hours()) / (24 * 7)
+		return epochMonday.AddDate(0, 0, (weeks/i.Count)*i.Count*7)
+	case UnitMonth:
+		months := (day.Year()-1970)*12 + int(day.Month()) - 1
+		months = (months / i.Count) * i.Count
+		return time.Date(1970+months/12, time.Month(months%12+1), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		days := int(day.Sub(epochMonday).Hours()) / 24
+		return epochMonday.AddDate(0, 0, (days/i.Count)*i.Count)
+	}
+}
+
+// Candle OHLC summary of the samples within one interval
+type Candle struct {
+	Start time.Time       `json:"start"` // First calendar day of the interval
+	End   time.Time       `json:"end"`   // Last trading date with a sample
+	Open  decimal.Decimal `json:"open"`  // First sample price
+	High  decimal.Decimal `json:"high"`  // Highest sample price
+	Low   decimal.Decimal `json:"low"`   // Lowest sample price
+	Close decimal.Decimal `json:"close"` // Last sample price
+	Count int             `json:"count"` // Number of samples
+
+	Synthetic bool `json:"synthetic,omitempty"` // A sample comes from a synthetic source
+}
+
+// Aggregate build candles from price records. Intervals without samples, such as
+// weekends for daily candles, produce no candle rather than an empty or flat one.
+func Aggregate(records []types.PriceInfo, interval Interval) []Candle {
+	sorted := make([]types.PriceInfo, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		da, db := sorted[a].TradingDay(), sorted[b].TradingDay()
+		if !da.Equal(db) {
+			return da.Before(db)
+		}
+		return sorted[a].LastUpdated.Before(sorted[b].LastUpdated)
+	})
+
+	candles := make([]Candle, 0)
+	for _, p := range sorted {
+		if p.Price.Sign() <= 0 {
+			continue
+		}
+		day := p.TradingDay()
+		start := interval.bucketStart(day)
+		if n := len(candles); n > 0 && candles[n-1].Start.Equal(start) {
+			c := &candles[n-1]
+			c.End = day
+			c.Close = p.Price
+			if p.Price.Cmp(c.High) > 0 {
+				c.High = p.Price
+			}
+			if p.Price.Cmp(c.Low) < 0 {
+				c.Low = p.Price
+			}
+			c.Count++
+			c.Synthetic = c.Synthetic || p.Synthetic
+			continue
+		}
+		candles = append(candles, Candle{
+			Start:     start,
+			End:       day,
+			Open:      p.Price,
+			High:      p.Price,
+			Low:       p.Price,
+			Close:     p.Price,
+			Count:     1,
+			Synthetic: p.Synthetic,
+		})
+	}
+	return candles
+}
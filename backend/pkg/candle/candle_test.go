@@ -0,0 +1,73 @@
+package candle
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// TestParseInterval test interval notation
+func TestParseInterval(t *testing.T) {
+	for text, expected := range map[string]string{"1d": "1d", "w": "1w", "2w": "2w", "1M": "1M", "3mo": "3M"} {
+		interval, err := ParseInterval(text)
+		if err != nil || interval.String() != expected {
+			t.Errorf("ParseInterval(%q) = %v, %v; expected %s", text, interval, err, expected)
+		}
+	}
+	for _, text := range []string{"", "0d", "1y", "1h"} {
+		if _, err := ParseInterval(text); err == nil {
+			t.Errorf("ParseInterval(%q) should fail", text)
+		}
+	}
+}
+
+// TestAggregate test OHLC values and weekend gaps
+func TestAggregate(t *testing.T) {
+	// Thursday Jan 11 to Tuesday Jan 16, 2024, nothing on the weekend
+	day := func(d int, hour int, price float64) types.PriceInfo {
+		date := time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+		return types.PriceInfo{Price: decimal.FromFloat(price), Date: date.Format(types.DateLayout), LastUpdated: date.Add(time.Duration(hour) * time.Hour)}
+	}
+	ohlc := func(c Candle) string {
+		return fmt.Sprintf("%s %s %s %s %d", c.Open, c.High, c.Low, c.Close, c.Count)
+	}
+	records := []types.PriceInfo{
+		day(16, 12, 66.0),
+		day(11, 0, 70.0),
+		day(11, 12, 72.5),
+		day(12, 0, 68.0),
+		day(15, 0, 67.0),
+		day(16, 0, 65.5),
+	}
+
+	daily := Aggregate(records, Interval{Count: 1, Unit: UnitDay})
+	if len(daily) != 4 {
+		t.Fatalf("Expected 4 daily candles without weekend gaps, got %d: %+v", len(daily), daily)
+	}
+	if c := daily[0]; ohlc(c) != "70 72.5 70 72.5 2" {
+		t.Errorf("Unexpected Jan 11 candle: %+v", c)
+	}
+
+	weekly := Aggregate(records, Interval{Count: 1, Unit: UnitWeek})
+	if len(weekly) != 2 {
+		t.Fatalf("Expected 2 weekly candles, got %d: %+v", len(weekly), weekly)
+	}
+	first, second := weekly[0], weekly[1]
+	if !first.Start.Equal(time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)) || !first.End.Equal(time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Weekly candle should start Monday and end on the last trading day: %+v", first)
+	}
+	if ohlc(first) != "70 72.5 68 68 3" {
+		t.Errorf("Unexpected first week candle: %+v", first)
+	}
+	if ohlc(second) != "67 67 65.5 66 3" {
+		t.Errorf("Unexpected second week candle: %+v", second)
+	}
+
+	monthly := Aggregate(records, Interval{Count: 1, Unit: UnitMonth})
+	if len(monthly) != 1 || monthly[0].Count != 6 || !monthly[0].Start.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected monthly candle: %+v", monthly)
+	}
+}
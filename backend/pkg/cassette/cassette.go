@@ -0,0 +1,228 @@
+package cassette
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"backend/pkg/archive"
+)
+
+// Mode what the transport does with requests
+type Mode string
+
+// Transport modes
+const (
+	ModeOff    Mode = "off"    // Pass requests through untouched
+	ModeRecord Mode = "record" // Pass requests through and save every response
+	ModeReplay Mode = "replay" // Serve saved responses, never touch the network
+)
+
+// ErrNotRecorded replay of a request without a cassette
+var ErrNotRecorded = errors.New("No recorded response")
+
+// ParseMode parse a mode name, empty means off
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "":
+		return ModeOff, nil
+	case ModeOff, ModeRecord, ModeReplay:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("Unknown cassette mode %q, use off, record or replay", name)
+	}
+}
+
+// Interaction one recorded request and its response, stored as a JSON cassette file.
+// Text bodies are kept readable so recorded pages can be inspected and edited by hand.
+type Interaction struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	RequestSHA256 string      `json:"requestSha256,omitempty"` // Hash of the request body, e.g. a login form
+	Status        int         `json:"status"`
+	Header        http.Header `json:"header"` // Response headers without archive.SensitiveHeaders
+	Body          string      `json:"body,omitempty"`
+	BodyBase64    []byte      `json:"bodyBase64,omitempty"` // Binary bodies, e.g. compressed responses
+	RecordedAt    time.Time   `json:"recordedAt"`           // Zero for a fixture written by hand
+	Note          string      `json:"note,omitempty"`       // Says so for a fixture written by hand, recording drops it
+}
+
+// Transport http.RoundTripper recording responses to or replaying them from a directory.
+// Requests are matched on method, URL and request body; headers such as cookies are ignored,
+// so a replay does not depend on session state. Recording a request again overwrites it.
+type Transport struct {
+	dir  string
+	mode Mode
+	next http.RoundTripper
+}
+
+// New create a transport over dir, next (http.DefaultTransport when nil) serves off and record mode
+func New(dir string, mode Mode, next http.RoundTripper) (*Transport, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	switch mode {
+	case ModeRecord:
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("Cannot create cassette directory %s: %w", dir, err)
+		}
+	case ModeReplay:
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("Cassette directory %s not found", dir)
+		}
+	}
+	return &Transport{dir: dir, mode: mode, next: next}, nil
+}
+
+// Mode mode of the transport
+func (t *Transport) Mode() Mode {
+	return t.mode
+}
+
+// RoundTrip serve, record or pass through a request
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.mode == ModeOff {
+		return t.next.RoundTrip(req)
+	}
+
+	bodyHash, err := requestHash(req)
+	if err != nil {
+		return nil, err
+	}
+	path := t.path(req, bodyHash)
+	if t.mode == ModeReplay {
+		interaction, err := load(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w for %s %s", ErrNotRecorded, req.Method, req.URL)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return interaction.response(req), nil
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	interaction := Interaction{
+		Method:        req.Method,
+		URL:           req.URL.String(),
+		RequestSHA256: bodyHash,
+		Status:        resp.StatusCode,
+		Header:        archive.RedactHeaders(resp.Header),
+		RecordedAt:    time.Now().UTC(),
+	}
+	if utf8.Valid(body) {
+		interaction.Body = string(body)
+	} else {
+		interaction.BodyBase64 = body
+	}
+	if err := save(path, interaction); err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// requestHash hash of the request body, empty without one. The body is restored for the next transport.
+func requestHash(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) == 0 {
+		return "", nil
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// unsafeChars characters replaced in cassette file names
+var unsafeChars = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// path cassette file of a request: readable host and path followed by a hash of the full request
+func (t *Transport) path(req *http.Request, bodyHash string) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.String() + "\n" + bodyHash))
+	name := strings.Trim(unsafeChars.ReplaceAllString(req.URL.Host+req.URL.Path, "_"), "_")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	return filepath.Join(t.dir, fmt.Sprintf("%s_%s_%s.json", strings.ToLower(req.Method), name, hex.EncodeToString(sum[:6])))
+}
+
+// load read a cassette file
+func load(path string) (Interaction, error) {
+	var interaction Interaction
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return interaction, err
+	}
+	if err := json.Unmarshal(data, &interaction); err != nil {
+		return interaction, fmt.Errorf("Invalid cassette %s: %w", path, err)
+	}
+	return interaction, nil
+}
+
+// save write a cassette file, through a temporary file so a replay never reads a partial one
+func save(path string, interaction Interaction) error {
+	data, err := json.MarshalIndent(interaction, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("Cannot write cassette: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("Cannot write cassette: %w", err)
+	}
+	return nil
+}
+
+// response rebuild the recorded response for req
+func (i Interaction) response(req *http.Request) *http.Response {
+	body := []byte(i.Body)
+	if i.BodyBase64 != nil {
+		body = i.BodyBase64
+	}
+	header := i.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.Status, http.StatusText(i.Status)),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,119 @@
+package cassette
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRecordReplay test that recorded responses replay without the server
+func TestRecordReplay(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/binary":
+			w.Write([]byte{0x1f, 0x8b, 0xff, 0x00})
+		case "/login":
+			w.Header().Set("Set-Cookie", "session=1")
+			fmt.Fprintf(w, "login %s", body)
+		default:
+			w.WriteHeader(http.StatusTeapot)
+			fmt.Fprint(w, "<p>price 71.35</p>")
+		}
+	}))
+	dir := t.TempDir()
+
+	recorder, err := New(dir, ModeRecord, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := []struct {
+		method, path, body string
+	}{
+		{"GET", "/page?q=1", ""},
+		{"GET", "/binary", ""},
+		{"POST", "/login", "user=a"},
+		{"POST", "/login", "user=b"},
+	}
+	client := &http.Client{Transport: recorder}
+	recorded := make([]string, len(requests))
+	for i, r := range requests {
+		recorded[i] = send(t, client, r.method, server.URL+r.path, r.body)
+	}
+	server.Close()
+	if hits != len(requests) {
+		t.Fatalf("Expected %d requests to reach the server, got %d", len(requests), hits)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "session=1") || strings.Contains(string(data), "Set-Cookie") {
+			t.Errorf("Session cookie saved in %s", filepath.Base(file))
+		}
+	}
+
+	replayer, err := New(dir, ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: replayer}
+	for i, r := range requests {
+		// The live response still carries the cookie, the replay does not
+		want := strings.Replace(recorded[i], " session=1 ", "  ", 1)
+		if got := send(t, client, r.method, server.URL+r.path, r.body); got != want {
+			t.Errorf("%s %s replayed %q, expected %q", r.method, r.path, got, want)
+		}
+	}
+
+	_, err = client.Get(server.URL + "/other")
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Expected ErrNotRecorded for an unknown request, got %v", err)
+	}
+	if _, err := New(dir+"/missing", ModeReplay, nil); err == nil {
+		t.Error("Expected error for a missing cassette directory")
+	}
+}
+
+// send issue a request and summarize the response
+func send(t *testing.T, client *http.Client, method, url, body string) string {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("%d %s %q", resp.StatusCode, resp.Header.Get("Set-Cookie"), data)
+}
+
+// TestParseMode test mode names
+func TestParseMode(t *testing.T) {
+	tests := map[string]Mode{"": ModeOff, "off": ModeOff, "Record": ModeRecord, " replay ": ModeReplay}
+	for name, want := range tests {
+		if mode, err := ParseMode(name); err != nil || mode != want {
+			t.Errorf("ParseMode(%q) = %q, %v, expected %q", name, mode, err, want)
+		}
+	}
+	if _, err := ParseMode("rewind"); err == nil {
+		t.Error("Expected error for an unknown mode")
+	}
+}
@@ -0,0 +1,126 @@
+package consensus
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// Aggregation methods
+const (
+	MethodMedian      = "median"
+	MethodTrimmedMean = "trimmed-mean"
+)
+
+// Config consensus configuration
+type Config struct {
+	Method       string  // MethodMedian or MethodTrimmedMean
+	Tolerance    float64 // Max deviation from consensus in percent before sources are flagged as disagreeing
+	TrimFraction float64 // Fraction of quotes dropped at each end for the trimmed mean, e.g. 0.2
+}
+
+// DefaultConfig median with a 2% tolerance
+func DefaultConfig() Config {
+	return Config{
+		Method:       MethodMedian,
+		Tolerance:    2.0,
+		TrimFraction: 0.2,
+	}
+}
+
+// percentScale fractional digits of SpreadPercent
+const percentScale = 8
+
+// hundred converts ratios to percentages
+var hundred = decimal.New(100, 0)
+
+// Result consensus over a set of quotes
+type Result struct {
+	Price         decimal.Decimal // Consensus price, exact for the median, OracleDecimals digits for the trimmed mean
+	Spread        decimal.Decimal // Max minus min quoted price
+	SpreadPercent decimal.Decimal // Spread relative to the consensus price
+	MaxDeviation  float64         // Largest deviation of a quote from the consensus, in percent
+	Disagreement  bool            // MaxDeviation exceeds the tolerance
+	Primary       int             // Index of the valid quote closest to the consensus price
+	Used          int             // Number of quotes used
+}
+
+// ErrNoQuotes returned when no quote without error is available
+var ErrNoQuotes = errors.New("No valid quotes to aggregate")
+
+// Aggregate compute consensus price of quotes, quotes with an error or non-positive price are ignored
+func Aggregate(quotes []types.SourceQuote, config Config) (*Result, error) {
+	var prices []decimal.Decimal
+	for _, q := range quotes {
+		if q.Error == "" && q.Price.Sign() > 0 {
+			prices = append(prices, q.Price)
+		}
+	}
+	if len(prices) == 0 {
+		return nil, ErrNoQuotes
+	}
+	sort.Slice(prices, func(a, b int) bool { return prices[a].Cmp(prices[b]) < 0 })
+
+	var price decimal.Decimal
+	switch config.Method {
+	case MethodMedian, "":
+		price = median(prices)
+	case MethodTrimmedMean:
+		price = trimmedMean(prices, config.TrimFraction)
+	default:
+		return nil, fmt.Errorf("Unknown consensus method %q", config.Method)
+	}
+
+	result := &Result{
+		Price:   price,
+		Spread:  prices[len(prices)-1].Sub(prices[0]),
+		Primary: -1,
+		Used:    len(prices),
+	}
+	result.SpreadPercent = result.Spread.Mul(hundred).Div(price, percentScale)
+
+	closest := math.Inf(1)
+	for i, q := range quotes {
+		if q.Error != "" || q.Price.Sign() <= 0 {
+			continue
+		}
+		deviation := q.Price.Sub(price).Abs().Mul(hundred).Div(price, percentScale).Float64()
+		if deviation > result.MaxDeviation {
+			result.MaxDeviation = deviation
+		}
+		if deviation < closest {
+			closest = deviation
+			result.Primary = i
+		}
+	}
+	result.Disagreement = result.MaxDeviation > config.Tolerance
+
+	return result, nil
+}
+
+// median of sorted values, halving adds at most one digit so it is exact
+func median(sorted []decimal.Decimal) decimal.Decimal {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return sorted[n/2-1].Add(sorted[n/2]).Div(decimal.New(2, 0), decimal.MaxScale)
+}
+
+// trimmedMean mean of sorted values after dropping fraction of values at each end
+func trimmedMean(sorted []decimal.Decimal, fraction float64) decimal.Decimal {
+	trim := int(float64(len(sorted)) * fraction)
+	if 2*trim >= len(sorted) {
+		return median(sorted)
+	}
+	kept := sorted[trim : len(sorted)-trim]
+	var sum decimal.Decimal
+	for _, v := range kept {
+		sum = sum.Add(v)
+	}
+	return sum.Div(decimal.New(int64(len(kept)), 0), decimal.OracleDecimals)
+}
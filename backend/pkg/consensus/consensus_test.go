@@ -0,0 +1,107 @@
+package consensus
+
+import (
+	"testing"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// TestAggregate test median, trimmed mean and disagreement detection
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       Config
+		quotes       []types.SourceQuote
+		price        string
+		spread       string
+		disagreement bool
+		primary      int
+	}{
+		{
+			name:   "Single source",
+			config: DefaultConfig(),
+			quotes: []types.SourceQuote{{Source: "a", Price: decimal.MustParse("70")}},
+			price:  "70",
+			spread: "0",
+		},
+		{
+			name:   "Median ignores failed source",
+			config: DefaultConfig(),
+			quotes: []types.SourceQuote{
+				{Source: "a", Price: decimal.MustParse("70.0")},
+				{Source: "b", Error: "timeout"},
+				{Source: "c", Price: decimal.MustParse("70.4")},
+				{Source: "d", Price: decimal.MustParse("70.2")},
+			},
+			price:   "70.2",
+			spread:  "0.4",
+			primary: 3,
+		},
+		{
+			name:   "Median of two is exact",
+			config: DefaultConfig(),
+			quotes: []types.SourceQuote{
+				{Source: "a", Price: decimal.MustParse("85.23")},
+				{Source: "b", Price: decimal.MustParse("85.24")},
+			},
+			price:  "85.235",
+			spread: "0.01",
+		},
+		{
+			name:   "Bad scrape is outvoted but flagged",
+			config: DefaultConfig(),
+			quotes: []types.SourceQuote{
+				{Source: "a", Price: decimal.MustParse("8.52")},
+				{Source: "b", Price: decimal.MustParse("85.20")},
+				{Source: "c", Price: decimal.MustParse("85.30")},
+			},
+			price:        "85.2",
+			spread:       "76.78",
+			disagreement: true,
+			primary:      1,
+		},
+		{
+			name:   "Trimmed mean",
+			config: Config{Method: MethodTrimmedMean, Tolerance: 50, TrimFraction: 0.2},
+			quotes: []types.SourceQuote{
+				{Source: "a", Price: decimal.MustParse("60")},
+				{Source: "b", Price: decimal.MustParse("70")},
+				{Source: "c", Price: decimal.MustParse("71")},
+				{Source: "d", Price: decimal.MustParse("72")},
+				{Source: "e", Price: decimal.MustParse("90")},
+			},
+			price:   "71",
+			spread:  "30",
+			primary: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Aggregate(tt.quotes, tt.config)
+			if err != nil {
+				t.Fatalf("Aggregate failed: %v", err)
+			}
+			if result.Price.String() != tt.price {
+				t.Errorf("Price mismatch: expected %s, got %s", tt.price, result.Price)
+			}
+			if result.Spread.String() != tt.spread {
+				t.Errorf("Spread mismatch: expected %s, got %s", tt.spread, result.Spread)
+			}
+			if result.Disagreement != tt.disagreement {
+				t.Errorf("Disagreement mismatch: expected %v, got %v", tt.disagreement, result.Disagreement)
+			}
+			if result.Primary != tt.primary {
+				t.Errorf("Primary mismatch: expected %d, got %d", tt.primary, result.Primary)
+			}
+		})
+	}
+}
+
+// TestAggregateNoQuotes test error when every source failed
+func TestAggregateNoQuotes(t *testing.T) {
+	_, err := Aggregate([]types.SourceQuote{{Source: "a", Error: "blocked"}}, DefaultConfig())
+	if err != ErrNoQuotes {
+		t.Errorf("Expected ErrNoQuotes, got %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/pkg/archive"
+	"backend/pkg/types"
+)
+
+// PageArchiver stores raw responses, implemented by archive.Archive
+type PageArchiver interface {
+	Save(page archive.Page, body []byte) (archive.Page, error)
+}
+
+// Archivable sources whose responses can be archived
+type Archivable interface {
+	SetArchiver(archiver PageArchiver)
+}
+
+// Reparser sources that can parse an archived response body again.
+// The returned price info carries the source name; LastUpdated is left to the caller.
+type Reparser interface {
+	Reparse(body []byte) (*types.PriceInfo, error)
+}
+
+// pageRecorder archiver hook embedded by sources
+type pageRecorder struct {
+	archiver PageArchiver
+}
+
+// SetArchiver archive every response from now on, nil disables archiving
+func (p *pageRecorder) SetArchiver(archiver PageArchiver) {
+	p.archiver = archiver
+}
+
+// archivePage store a response, failures are logged and never fail the fetch.
+// The archive drops session cookies and credentials from the headers.
+func (p *pageRecorder) archivePage(source, url string, status int, headers http.Header, body []byte) {
+	if p.archiver == nil {
+		return
+	}
+	page := archive.Page{
+		Source:    source,
+		URL:       url,
+		Status:    status,
+		Headers:   headers.Clone(),
+		FetchedAt: time.Now(),
+	}
+	if _, err := p.archiver.Save(page, body); err != nil {
+		fmt.Printf("Failed to archive response from %s: %v\n", url, err) // Debug log
+	}
+}
@@ -0,0 +1,207 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Breaker states
+const (
+	BreakerClosed   = "closed"    // Source is fetched normally
+	BreakerOpen     = "open"      // Source is skipped until the open timeout has passed
+	BreakerHalfOpen = "half-open" // One probe fetch decides between closed and open
+)
+
+// ErrInvalidBreakerState unknown state passed to Force
+var ErrInvalidBreakerState = errors.New("Breaker state must be open, closed or auto")
+
+// maxTransitions transitions kept per breaker
+const maxTransitions = 20
+
+// BreakerConfig when a breaker opens and how long it stays open
+type BreakerConfig struct {
+	FailureThreshold int           // Consecutive failed fetches that open the breaker
+	OpenTimeout      time.Duration // Time before a probe fetch is allowed again
+}
+
+// DefaultBreakerConfig open after 3 failed updates, probe again after an hour
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{FailureThreshold: 3, OpenTimeout: time.Hour}
+}
+
+// Transition breaker state change
+type Transition struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason"`
+}
+
+// BreakerStats breaker snapshot for /metrics and the admin API
+type BreakerStats struct {
+	Source              string       `json:"source"`
+	State               string       `json:"state"`
+	Forced              bool         `json:"forced"` // Set by an operator, automatic transitions are suspended
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	OpenedAt            *time.Time   `json:"openedAt,omitempty"`
+	LastError           string       `json:"lastError,omitempty"`
+	Rejected            int64        `json:"rejected"` // Fetches skipped while open
+	Transitions         []Transition `json:"transitions"`
+}
+
+// Breaker per-source circuit breaker
+type Breaker struct {
+	mu          sync.Mutex
+	source      string
+	config      BreakerConfig
+	state       string
+	forced      bool
+	probing     bool // Half-open probe in flight
+	failures    int
+	openedAt    time.Time
+	lastError   string
+	rejected    int64
+	transitions []Transition
+	now         func() time.Time
+}
+
+// NewBreaker create a closed breaker
+func NewBreaker(source string, config BreakerConfig) *Breaker {
+	if config.FailureThreshold < 1 {
+		config.FailureThreshold = 1
+	}
+	return &Breaker{source: source, config: config, state: BreakerClosed, now: time.Now}
+}
+
+// Allow whether the source may be fetched now. An open breaker turns half-open once
+// the open timeout has passed and then lets a single probe through.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && !b.forced && b.now().Sub(b.openedAt) >= b.config.OpenTimeout {
+		b.transition(BreakerHalfOpen, "open timeout elapsed")
+	}
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			b.rejected++
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		b.rejected++
+		return false
+	}
+}
+
+// Record report the outcome of an allowed fetch. Cancelled fetches say nothing about the source.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbe := b.probing
+	b.probing = false
+	if err != nil && errors.Is(err, context.Canceled) {
+		return
+	}
+	if err == nil {
+		b.failures = 0
+		if b.state != BreakerClosed && !b.forced {
+			b.transition(BreakerClosed, "probe succeeded")
+		}
+		return
+	}
+
+	b.failures++
+	b.lastError = err.Error()
+	if b.forced {
+		return
+	}
+	switch {
+	case wasProbe && b.state == BreakerHalfOpen:
+		b.open("probe failed: " + b.lastError)
+	case b.state == BreakerClosed && b.failures >= b.config.FailureThreshold:
+		b.open(fmt.Sprintf("%d consecutive failures, last: %s", b.failures, b.lastError))
+	}
+}
+
+// Force pin the breaker open or closed, "auto" returns it to automatic operation in the closed state
+func (b *Breaker) Force(state string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch state {
+	case BreakerOpen:
+		b.forced = true
+		if b.state != BreakerOpen {
+			b.open("forced open by operator")
+		}
+	case BreakerClosed:
+		b.forced = true
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.transition(BreakerClosed, "forced closed by operator")
+		}
+	case "auto":
+		b.forced = false
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.transition(BreakerClosed, "released by operator")
+		} else {
+			b.record(BreakerClosed, "released by operator")
+		}
+	default:
+		return ErrInvalidBreakerState
+	}
+	b.probing = false
+	return nil
+}
+
+// Stats snapshot
+func (b *Breaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stats := BreakerStats{
+		Source:              b.source,
+		State:               b.state,
+		Forced:              b.forced,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+		Rejected:            b.rejected,
+		Transitions:         append([]Transition{}, b.transitions...),
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		stats.OpenedAt = &openedAt
+	}
+	return stats
+}
+
+// open move to open, caller holds the lock
+func (b *Breaker) open(reason string) {
+	b.openedAt = b.now()
+	b.transition(BreakerOpen, reason)
+}
+
+// transition change state, caller holds the lock
+func (b *Breaker) transition(to, reason string) {
+	fmt.Printf("Circuit breaker %s: %s -> %s (%s)\n", b.source, b.state, to, reason) // Debug log
+	b.record(to, reason)
+	b.state = to
+}
+
+// record append a transition, caller holds the lock
+func (b *Breaker) record(to, reason string) {
+	b.transitions = append(b.transitions, Transition{From: b.state, To: to, At: b.now().UTC(), Reason: reason})
+	if len(b.transitions) > maxTransitions {
+		b.transitions = b.transitions[len(b.transitions)-maxTransitions:]
+	}
+}
@@ -0,0 +1,157 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// TestBreakerTransitions test closed -> open -> half-open -> closed/open
+func TestBreakerTransitions(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	breaker := NewBreaker("test", BreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute})
+	breaker.now = func() time.Time { return now }
+	failure := errors.New("upstream down")
+
+	breaker.Record(failure)
+	if !breaker.Allow() {
+		t.Fatal("Breaker opened before reaching the threshold")
+	}
+	breaker.Record(failure)
+	if state := breaker.Stats().State; state != BreakerOpen {
+		t.Fatalf("Expected open after 2 failures, got %s", state)
+	}
+	if breaker.Allow() {
+		t.Fatal("Open breaker allowed a fetch")
+	}
+
+	// Cancelled fetches do not count
+	now = now.Add(time.Minute)
+	if !breaker.Allow() {
+		t.Fatal("Half-open breaker rejected the probe")
+	}
+	breaker.Record(context.Canceled)
+	if !breaker.Allow() {
+		t.Fatal("Probe slot not released after cancellation")
+	}
+	if breaker.Allow() {
+		t.Fatal("Half-open breaker allowed a second concurrent probe")
+	}
+
+	breaker.Record(failure)
+	if state := breaker.Stats().State; state != BreakerOpen {
+		t.Fatalf("Expected open after failed probe, got %s", state)
+	}
+
+	now = now.Add(time.Minute)
+	breaker.Allow()
+	breaker.Record(nil)
+	stats := breaker.Stats()
+	if stats.State != BreakerClosed || stats.ConsecutiveFailures != 0 {
+		t.Fatalf("Expected closed after successful probe, got %+v", stats)
+	}
+
+	var path []string
+	for _, tr := range stats.Transitions {
+		path = append(path, tr.To)
+	}
+	want := []string{BreakerOpen, BreakerHalfOpen, BreakerOpen, BreakerHalfOpen, BreakerClosed}
+	if len(path) != len(want) {
+		t.Fatalf("Transitions mismatch: expected %v, got %v", want, path)
+	}
+	for i := range want {
+		if path[i] != want[i] {
+			t.Fatalf("Transitions mismatch: expected %v, got %v", want, path)
+		}
+	}
+	if stats.Rejected != 2 {
+		t.Errorf("Rejected mismatch: expected 2, got %d", stats.Rejected)
+	}
+}
+
+// TestBreakerForce test operator overrides
+func TestBreakerForce(t *testing.T) {
+	breaker := NewBreaker("test", BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Nanosecond})
+
+	if err := breaker.Force(BreakerOpen); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+	if breaker.Allow() {
+		t.Fatal("Forced open breaker went half-open")
+	}
+
+	breaker.Force(BreakerClosed)
+	breaker.Record(errors.New("upstream down"))
+	if !breaker.Allow() || breaker.Stats().State != BreakerClosed {
+		t.Fatal("Forced closed breaker opened on failure")
+	}
+
+	breaker.Force("auto")
+	breaker.Record(errors.New("upstream down"))
+	if stats := breaker.Stats(); stats.State != BreakerOpen || stats.Forced {
+		t.Fatalf("Released breaker should open on failure, got %+v", stats)
+	}
+
+	if err := breaker.Force("half"); !errors.Is(err, ErrInvalidBreakerState) {
+		t.Errorf("Expected ErrInvalidBreakerState, got %v", err)
+	}
+}
+
+// staticSource returns a fixed price or error
+type staticSource struct {
+	name  string
+	price float64
+	err   error
+	calls int
+}
+
+func (s *staticSource) Name() string { return s.name }
+
+func (s *staticSource) FetchPrice(ctx context.Context) (*types.PriceInfo, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &types.PriceInfo{Price: decimal.FromFloat(s.price), Source: s.name}, nil
+}
+
+// TestFetchAllFailover test fallback to the next healthy source in priority order
+func TestFetchAllFailover(t *testing.T) {
+	primary := &staticSource{name: "primary", err: errors.New("banned")}
+	secondary := &staticSource{name: "secondary", price: 70}
+	tertiary := &staticSource{name: "tertiary", price: 71}
+	registry := NewRegistry(primary, secondary, tertiary)
+	for _, name := range registry.Names() {
+		registry.SetPolicy(name, RetryPolicy{MaxAttempts: 1})
+		registry.SetBreakerConfig(name, BreakerConfig{FailureThreshold: 1, OpenTimeout: time.Hour})
+	}
+
+	results := registry.FetchAll(context.Background(), 1)
+	if len(results) != 2 || results[0].Source != "primary" || results[1].Source != "secondary" || results[1].Err != nil {
+		t.Fatalf("Expected primary failure then secondary, got %+v", results)
+	}
+	if tertiary.calls != 0 {
+		t.Error("Tertiary fetched although secondary succeeded")
+	}
+
+	// Primary breaker is open now and is skipped
+	results = registry.FetchAll(context.Background(), 2)
+	if len(results) != 2 || results[0].Source != "secondary" || results[1].Source != "tertiary" {
+		t.Fatalf("Expected secondary and tertiary, got %+v", results)
+	}
+	if primary.calls != 1 {
+		t.Errorf("Open breaker did not stop fetches, primary called %d times", primary.calls)
+	}
+
+	for _, name := range registry.Names() {
+		registry.Breaker(name).Force(BreakerOpen)
+	}
+	if results := registry.FetchAll(context.Background(), 0); len(results) != 0 {
+		t.Errorf("Expected no results with every breaker open, got %+v", results)
+	}
+}
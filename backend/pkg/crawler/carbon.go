@@ -1,138 +1,205 @@
 package crawler
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"regexp"
-	"strconv"
-	"strings"
-	"time"
 
-	"backend/pkg/models"
+	"backend/pkg/types"
 
 	"github.com/gocolly/colly/v2"
 )
 
-// CarbonCrawler carbon price crawler struct
+// TradingEconomicsURL carbon commodity page on TradingEconomics
+const TradingEconomicsURL = "https://tradingeconomics.com/commodity/carbon"
+
+// TradingEconomicsLoginURL login form used to restore an expired session
+const TradingEconomicsLoginURL = "https://tradingeconomics.com/user/login.aspx"
+
+// userAgent browser user agent sent by the scrapers
+const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:139.0) Gecko/20100101 Firefox/139.0"
+
+// CarbonCrawler carbon price crawler struct, scrapes the TradingEconomics commodity page
 type CarbonCrawler struct {
-	collector *colly.Collector
+	pageRecorder
+	url        string
+	session    *Session
+	extractors []Extractor // Extraction chain, nil for carbonExtractors
+}
+
+// carbonMetaSelector meta tag whose description carries price and changes
+const carbonMetaSelector = "meta[content*='EU Carbon Permits']"
+
+// carbonExtractors chart data embedded in the page first, then the quote table row,
+// the description sentence as a last resort
+func carbonExtractors() []Extractor {
+	return []Extractor{
+		ScriptExtractor("carbon", "TEChartsMeta", "TESymbolData"),
+		TableExtractor("EU Carbon Permits"),
+		MetaExtractor(carbonMetaSelector, parsePriceInfo),
+	}
 }
 
-// NewCarbonCrawler create new carbon price crawler instance
+// NewCarbonCrawler create new carbon price crawler instance, anonymous until WithSession is called
 func NewCarbonCrawler() *CarbonCrawler {
 	fmt.Println("Creating new crawler instance...") // Debug log
-	c := colly.NewCollector(
-		colly.UserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:139.0) Gecko/20100101 Firefox/139.0"),
+	return &CarbonCrawler{
+		url:     TradingEconomicsURL,
+		session: NewSession("", nil),
+	}
+}
+
+// WithSession use an authenticated upstream session
+func (c *CarbonCrawler) WithSession(session *Session) *CarbonCrawler {
+	c.session = session
+	return c
+}
+
+// SessionStatus upstream session health
+func (c *CarbonCrawler) SessionStatus() SessionStatus {
+	return c.session.Status()
+}
+
+// Name source name
+func (c *CarbonCrawler) Name() string {
+	return "tradingeconomics"
+}
+
+// newCollector create a collector with browser-like request headers.
+// A fresh collector is needed per fetch because colly refuses to revisit a URL.
+func (c *CarbonCrawler) newCollector(ctx context.Context) *colly.Collector {
+	collector := colly.NewCollector(
+		colly.UserAgent(userAgent),
+		colly.StdlibContext(ctx),
 	)
+	collector.WithTransport(sharedTransport{})
+	collector.SetCookieJar(c.session.Jar())
 
 	// Set request headers
-	c.OnRequest(func(r *colly.Request) {
+	collector.OnRequest(func(r *colly.Request) {
 		fmt.Printf("Requesting URL: %s\n", r.URL) // Debug log
 		r.Headers.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
 		r.Headers.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
 		r.Headers.Set("Connection", "keep-alive")
-		r.Headers.Set("Cookie", "ASP.NET_SessionId=qvlmyeaya40rjqapintmgmmz; .ASPXAUTH=F835FBEBFC672871B65CB1206EC5A47C6A873F8A9D826E277FD919D95F377320E6D7CF615B49EBB7B9E116DF51DA5241F50E8EDEF0D8C691D4E6DF765F4CC6A7E68A2CB32FD7D1A73882EFC7347D54F7B897EE58B7D8BEE5DC46AA5DD6E30D3954E9A876; TEUsername=YJGxU4jwoxhMVeBKw+XSdAfnWs9YEFjwpBHyuBcG9fE=; TENickName=ciaranJames; TEUserInfo=9c7a3ae9-0bbe-4aea-9218-e2ad1f8fa9ae; TEName=ciaran James; TEUserEmail=ciaran.aicheng@gmail.com; TEServer=TEIIS")
 	})
 
 	// Add response handler
-	c.OnResponse(func(r *colly.Response) {
+	collector.OnResponse(func(r *colly.Response) {
 		fmt.Printf("Received response, status code: %d\n", r.StatusCode) // Debug log
 	})
 
 	// Add error handler
-	c.OnError(func(r *colly.Response, err error) {
+	collector.OnError(func(r *colly.Response, err error) {
 		fmt.Printf("Request failed: %v\n", err) // Debug log
 	})
 
-	return &CarbonCrawler{
-		collector: c,
-	}
+	return collector
 }
 
-// FetchPrice get carbon price information
-func (c *CarbonCrawler) FetchPrice() (*models.PriceInfo, error) {
+// FetchPrice get carbon price information, logging in again once if the session has expired
+func (c *CarbonCrawler) FetchPrice(ctx context.Context) (*types.PriceInfo, error) {
 	fmt.Println("Starting to fetch price info...") // Debug log
-	var priceInfo *models.PriceInfo
-	var err error
-
-	c.collector.OnHTML("meta[content*='EU Carbon Permits']", func(e *colly.HTMLElement) {
-		fmt.Println("Found price info meta tag") // Debug log
-		content := e.Attr("content")
-		fmt.Printf("Meta content: %s\n", content) // Debug log
-		priceInfo, err = parsePriceInfo(content)
-	})
-
-	err = c.collector.Visit("https://tradingeconomics.com/commodity/carbon")
-	if err != nil {
-		fmt.Printf("Failed to visit webpage: %v\n", err) // Debug log
+	if err := c.session.Prepare(c.url); err != nil {
 		return nil, err
 	}
 
-	if priceInfo == nil {
-		fmt.Println("No price info found") // Debug log
-		return nil, errors.New("No price info found")
+	priceInfo, err := c.fetch(ctx)
+	if errors.Is(err, ErrSessionExpired) {
+		fmt.Printf("Session expired, refreshing: %v\n", err) // Debug log
+		if refreshErr := c.session.Refresh(ctx, c.url, err.Error()); refreshErr != nil {
+			return nil, refreshErr
+		}
+		priceInfo, err = c.fetch(ctx)
+		if errors.Is(err, ErrSessionExpired) {
+			// Refreshed but still sent to the login page
+			c.session.MarkExpired(err)
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
 	}
+	c.session.MarkValid()
+	priceInfo.Source = c.Name()
 
-	fmt.Printf("Successfully parsed price info: %+v\n", priceInfo) // Debug log
+	fmt.Printf("Successfully parsed price info with %s extractor (%s confidence): %+v\n", priceInfo.Extractor, priceInfo.Confidence, priceInfo) // Debug log
 	return priceInfo, nil
 }
 
-// parsePriceInfo parse price information
-func parsePriceInfo(text string) (*models.PriceInfo, error) {
-	fmt.Println("Starting to parse price info...") // Debug log
-	// Price and date matching
-	pricePattern := regexp.MustCompile(`(\d+\.\d+)\s+EUR\s+on\s+([A-Za-z]+\s+\d+,\s+\d{4})`)
-	// Daily change matching (support up and down)
-	dailyPattern := regexp.MustCompile(`(?:up|down)\s+(\d+\.\d+)%`)
-	// Monthly change matching (support up and down)
-	monthlyPattern := regexp.MustCompile(`(?:risen|fallen)\s+(\d+\.\d+)%`)
-	// Yearly change matching (support up and down)
-	yearlyPattern := regexp.MustCompile(`(?:up|down)\s+(\d+\.\d+)%\s+compared\s+to\s+the\s+same\s+time\s+last\s+year`)
-
-	priceMatch := pricePattern.FindStringSubmatch(text)
-	if len(priceMatch) < 3 {
-		fmt.Println("Cannot match price and date") // Debug log
-		return nil, errors.New("Cannot parse price info")
-	}
-
-	price, _ := strconv.ParseFloat(priceMatch[1], 64)
-	fmt.Printf("Parsed price: %f, date: %s\n", price, priceMatch[2]) // Debug log
+// fetch visit the page once, a login redirect or login form is reported as ErrSessionExpired
+func (c *CarbonCrawler) fetch(ctx context.Context) (*types.PriceInfo, error) {
+	var priceInfo *types.PriceInfo
+	var err error
+	var loginPage, blocked bool
+	var status int
 
-	info := &models.PriceInfo{
-		Price:       price,
-		Date:        priceMatch[2],
-		LastUpdated: time.Now().Format(time.RFC3339),
-	}
+	collector := c.newCollector(ctx)
+	collector.OnHTML("input[type=password]", func(e *colly.HTMLElement) {
+		loginPage = true
+	})
 
-	// Parse daily change
-	if dailyMatch := dailyPattern.FindStringSubmatch(text); len(dailyMatch) > 1 {
-		info.DailyChange, _ = strconv.ParseFloat(dailyMatch[1], 64)
-		// Convert to negative if down
-		if strings.Contains(text, "down") {
-			info.DailyChange = -info.DailyChange
+	var finalURL string
+	collector.OnResponse(func(r *colly.Response) {
+		finalURL = r.Request.URL.String()
+		if isLoginURL(r.Request.URL) {
+			loginPage = true
+		}
+		blocked = isBlockedPage(r.Body)
+		c.archivePage(c.Name(), finalURL, r.StatusCode, *r.Headers, r.Body)
+		priceInfo, err = c.Reparse(r.Body)
+	})
+	collector.OnError(func(r *colly.Response, _ error) {
+		status = r.StatusCode
+		if r.StatusCode > 0 {
+			c.archivePage(c.Name(), r.Request.URL.String(), r.StatusCode, *r.Headers, r.Body)
 		}
-		fmt.Printf("Parsed daily change: %f%%\n", info.DailyChange) // Debug log
+	})
+
+	if visitErr := collector.Visit(c.url); visitErr != nil {
+		fmt.Printf("Failed to visit webpage: %v\n", visitErr) // Debug log
+		return nil, visitError(c.Name(), c.url, status, visitErr)
 	}
 
-	// Parse monthly change
-	if monthlyMatch := monthlyPattern.FindStringSubmatch(text); len(monthlyMatch) > 1 {
-		info.MonthlyChange, _ = strconv.ParseFloat(monthlyMatch[1], 64)
-		// Convert to negative if fallen
-		if strings.Contains(text, "fallen") {
-			info.MonthlyChange = -info.MonthlyChange
+	if priceInfo == nil {
+		switch {
+		case loginPage:
+			fmt.Printf("Login page served at %s\n", finalURL) // Debug log
+			return nil, NewFetchError(KindAuthExpired, c.Name(), c.url,
+				fmt.Errorf("%w: %s served a login page (%s)", ErrSessionExpired, c.url, finalURL))
+		case Classify(err) == KindParseFailed:
+			return nil, withURL(err, c.url)
+		case blocked:
+			fmt.Println("Bot check page served") // Debug log
+			return nil, NewFetchError(KindBlocked, c.Name(), c.url, errors.New("Blocked by upstream: captcha or bot check page served"))
+		case err != nil:
+			fmt.Printf("No price info found: %v\n", err) // Debug log
+			return nil, withURL(err, c.url)
+		default:
+			return nil, NewFetchError(KindSelectorNotFound, c.Name(), c.url, errors.New("No price info found"))
 		}
-		fmt.Printf("Parsed monthly change: %f%%\n", info.MonthlyChange) // Debug log
 	}
+	return priceInfo, nil
+}
 
-	// Parse yearly change
-	if yearlyMatch := yearlyPattern.FindStringSubmatch(text); len(yearlyMatch) > 1 {
-		info.YearlyChange, _ = strconv.ParseFloat(yearlyMatch[1], 64)
-		// Convert to negative if down
-		if strings.Contains(text, "down") {
-			info.YearlyChange = -info.YearlyChange
-		}
-		fmt.Printf("Parsed yearly change: %f%%\n", info.YearlyChange) // Debug log
+// Reparse extract price info from a fetched or archived page with the extractor chain
+func (c *CarbonCrawler) Reparse(body []byte) (*types.PriceInfo, error) {
+	extractors := c.extractors
+	if extractors == nil {
+		extractors = carbonExtractors()
 	}
+	return extractPrice(c.Name(), extractors, body)
+}
 
+// parsePriceInfo parse price information from the page description, see parseSentence
+func parsePriceInfo(text string) (*types.PriceInfo, error) {
+	fmt.Println("Starting to parse price info...") // Debug log
+	info, err := parseSentence(text)
+	if err != nil {
+		fmt.Println("Cannot match price") // Debug log
+		return nil, err
+	}
+	info.LastUpdated = fetchTime()
+	fmt.Printf("Parsed price: %f, date: %s, changes: %.2f%%/%.2f%%/%.2f%%, missing: %v\n",
+		info.Price, info.Date, info.DailyChange, info.MonthlyChange, info.YearlyChange, info.Missing) // Debug log
 	return info, nil
 }
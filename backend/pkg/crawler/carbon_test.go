@@ -1,9 +1,13 @@
 package crawler
 
 import (
+	"context"
+	"os"
 	"testing"
 
-	"backend/pkg/models"
+	"backend/pkg/cassette"
+	"backend/pkg/decimal"
+	"backend/pkg/types"
 )
 
 // TestParsePriceInfo test price info parsing functionality
@@ -12,30 +16,30 @@ func TestParsePriceInfo(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
-		expected *models.PriceInfo
+		expected *types.PriceInfo
 	}{
 		{
 			name: "Price increase test",
 			input: "EU Carbon Permits increased to 85.23 EUR on January 15, 2024, up 2.5% from yesterday. " +
 				"The price has risen 5.2% this month and is up 15.3% compared to the same time last year.",
-			expected: &models.PriceInfo{
-				Price:         85.23,
+			expected: &types.PriceInfo{
+				Price:         decimal.MustParse("85.23"),
 				Date:          "January 15, 2024",
-				DailyChange:   2.5,
-				MonthlyChange: 5.2,
-				YearlyChange:  15.3,
+				DailyChange:   decimal.MustParse("2.5"),
+				MonthlyChange: decimal.MustParse("5.2"),
+				YearlyChange:  decimal.MustParse("15.3"),
 			},
 		},
 		{
 			name: "Price decrease test",
 			input: "EU Carbon Permits decreased to 82.15 EUR on January 15, 2024, down 3.2% from yesterday. " +
 				"The price has fallen 4.1% this month and is down 8.5% compared to the same time last year.",
-			expected: &models.PriceInfo{
-				Price:         82.15,
+			expected: &types.PriceInfo{
+				Price:         decimal.MustParse("82.15"),
 				Date:          "January 15, 2024",
-				DailyChange:   -3.2,
-				MonthlyChange: -4.1,
-				YearlyChange:  -8.5,
+				DailyChange:   decimal.MustParse("-3.2"),
+				MonthlyChange: decimal.MustParse("-4.1"),
+				YearlyChange:  decimal.MustParse("-8.5"),
 			},
 		},
 	}
@@ -76,13 +80,29 @@ func TestParsePriceInfo(t *testing.T) {
 	}
 }
 
-// TestFetchPrice test actual crawling functionality
+// TestFetchPrice test actual crawling functionality against the cassette in testdata/cassettes.
+// The committed cassette is a synthetic page, see its note, so replay checks the fetch path and
+// extract_test.go covers the extractors. Run with CASSETTE_MODE=record to fetch the live site.
 func TestFetchPrice(t *testing.T) {
+	mode, err := cassette.ParseMode(os.Getenv("CASSETTE_MODE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode == cassette.ModeOff {
+		mode = cassette.ModeReplay
+	}
+	recorder, err := cassette.New("testdata/cassettes", mode, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetTransport(recorder)
+	defer SetTransport(nil)
+
 	// Create crawler instance
 	crawler := NewCarbonCrawler()
 
 	// Get price info
-	priceInfo, err := crawler.FetchPrice()
+	priceInfo, err := crawler.FetchPrice(context.Background())
 	if err != nil {
 		t.Errorf("Failed to get price info: %v", err)
 		return
@@ -95,15 +115,20 @@ func TestFetchPrice(t *testing.T) {
 	}
 
 	// Verify price is greater than 0
-	if priceInfo.Price <= 0 {
+	if priceInfo.Price.Sign() <= 0 {
 		t.Errorf("Invalid price: %.2f", priceInfo.Price)
 	}
 
 	// Verify last update time
-	if priceInfo.LastUpdated == "" {
+	if priceInfo.LastUpdated.IsZero() {
 		t.Error("Last update time is empty")
 	}
 
+	// The replayed page is deterministic
+	if mode == cassette.ModeReplay && (priceInfo.Price != decimal.MustParse("71.35") || priceInfo.Date != "January 15, 2024") {
+		t.Errorf("Replayed price mismatch: %+v", priceInfo)
+	}
+
 	// Print price info for debugging
 	t.Logf("Retrieved price info: %+v", priceInfo)
 }
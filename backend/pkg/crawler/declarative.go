@@ -0,0 +1,460 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/gocolly/colly/v2"
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+)
+
+// Definition declarative scraper loaded from YAML. Rules read text from the page into named
+// values, fields map those values onto the price info:
+//
+//	name: ice
+//	url: https://www.ice.com/products/197/EUA-Futures/data
+//	headers: {Accept-Language: en}
+//	rules:
+//	  - selector: "table.settlements tr:contains('Dec24')"
+//	    regex: '(?P<date>\d{2}/\d{2}/\d{4})\s+(?P<settle>[\d.,]+)\s+(?P<change>[-+]?[\d.,]+)'
+//	fields:
+//	  price: {from: settle, unit: EUR/t}
+//	  date: {from: date, format: 01/02/2006}
+//	  dailyChange: {from: change, unit: "%"}
+type Definition struct {
+	Name       string                  `yaml:"name" json:"name"`                                 // Source name, lower case letters, digits and dashes
+	URL        string                  `yaml:"url" json:"url"`                                   // Page to fetch
+	Headers    map[string]string       `yaml:"headers,omitempty" json:"headers,omitempty"`       // Extra request headers
+	Confidence Confidence              `yaml:"confidence,omitempty" json:"confidence,omitempty"` // Confidence of a complete extraction, defaults to medium
+	Disabled   bool                    `yaml:"disabled,omitempty" json:"disabled,omitempty"`     // Keep the file but do not register the source
+	Rules      []Rule                  `yaml:"rules" json:"rules"`                               // Evaluated in order, a later rule overwrites a value
+	Fields     map[string]FieldMapping `yaml:"fields,omitempty" json:"fields,omitempty"`         // Keyed by price field, a value of the same name is used without one
+
+	selectors []cascadia.Selector
+	xpaths    []*xpath.Expr
+	regexes   []*regexp.Regexp
+}
+
+// Rule read the first node matching a CSS selector or an XPath expression. Without a regex the
+// whole text becomes the value called name, otherwise every named group becomes a value and an
+// unnamed first group or the whole match is called name.
+type Rule struct {
+	Name     string `yaml:"name,omitempty" json:"name,omitempty"`
+	Selector string `yaml:"selector,omitempty" json:"selector,omitempty"`
+	XPath    string `yaml:"xpath,omitempty" json:"xpath,omitempty"`
+	Attr     string `yaml:"attr,omitempty" json:"attr,omitempty"` // Read this attribute instead of the text
+	Regex    string `yaml:"regex,omitempty" json:"regex,omitempty"`
+}
+
+// FieldMapping where a price field comes from
+type FieldMapping struct {
+	From   string `yaml:"from,omitempty" json:"from,omitempty"`     // Value name, defaults to the field name
+	Unit   string `yaml:"unit,omitempty" json:"unit,omitempty"`     // See priceUnits and changeUnits
+	Format string `yaml:"format,omitempty" json:"format,omitempty"` // Go time layout of the date, any accepted format when empty
+}
+
+// priceUnits factors converting a price to EUR per tonne
+var priceUnits = map[string]decimal.Decimal{
+	"":       decimal.New(1, 0),
+	"EUR":    decimal.New(1, 0),
+	"EUR/t":  decimal.New(1, 0),
+	"EUR/kt": decimal.New(1, 3),
+	"cent":   decimal.New(1, 2),
+	"EURc/t": decimal.New(1, 2),
+}
+
+// changeUnits factors converting a change to percent
+var changeUnits = map[string]decimal.Decimal{
+	"":         decimal.New(1, 0),
+	"%":        decimal.New(1, 0),
+	"fraction": decimal.New(100, 0),
+	"bp":       decimal.New(1, 2),
+}
+
+// definitionName allowed source names, they become environment variable prefixes and file names
+var definitionName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ParseDefinition decode and validate a YAML definition, unknown keys are rejected
+func ParseDefinition(data []byte) (*Definition, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var definition Definition
+	if err := decoder.Decode(&definition); err != nil {
+		return nil, fmt.Errorf("Invalid definition: %w", err)
+	}
+	if err := definition.compile(); err != nil {
+		return nil, err
+	}
+	return &definition, nil
+}
+
+// compile check the definition and prepare its selectors and regexes
+func (d *Definition) compile() error {
+	if !definitionName.MatchString(d.Name) {
+		return fmt.Errorf("Invalid definition name %q: use lower case letters, digits and dashes", d.Name)
+	}
+	if !strings.HasPrefix(d.URL, "http://") && !strings.HasPrefix(d.URL, "https://") {
+		return fmt.Errorf("Definition %s: url must be an http or https address", d.Name)
+	}
+	switch d.Confidence {
+	case "":
+		d.Confidence = ConfidenceMedium
+	case ConfidenceHigh, ConfidenceMedium, ConfidenceLow:
+	default:
+		return fmt.Errorf("Definition %s: unknown confidence %q", d.Name, d.Confidence)
+	}
+	if len(d.Rules) == 0 {
+		return fmt.Errorf("Definition %s has no rules", d.Name)
+	}
+
+	d.selectors = make([]cascadia.Selector, len(d.Rules))
+	d.xpaths = make([]*xpath.Expr, len(d.Rules))
+	d.regexes = make([]*regexp.Regexp, len(d.Rules))
+	values := make(map[string]bool)
+	for i, rule := range d.Rules {
+		var err error
+		switch {
+		case (rule.Selector == "") == (rule.XPath == ""):
+			return fmt.Errorf("Definition %s rule %d: set exactly one of selector and xpath", d.Name, i+1)
+		case rule.Selector != "":
+			if d.selectors[i], err = cascadia.Compile(rule.Selector); err != nil {
+				return fmt.Errorf("Definition %s rule %d: invalid selector: %w", d.Name, i+1, err)
+			}
+		default:
+			if d.xpaths[i], err = xpath.Compile(rule.XPath); err != nil {
+				return fmt.Errorf("Definition %s rule %d: invalid xpath: %w", d.Name, i+1, err)
+			}
+		}
+
+		named := false
+		if rule.Regex != "" {
+			if d.regexes[i], err = regexp.Compile(rule.Regex); err != nil {
+				return fmt.Errorf("Definition %s rule %d: invalid regex: %w", d.Name, i+1, err)
+			}
+			for _, group := range d.regexes[i].SubexpNames() {
+				if group != "" {
+					values[group], named = true, true
+				}
+			}
+		}
+		if rule.Name != "" {
+			values[rule.Name] = true
+		} else if !named {
+			return fmt.Errorf("Definition %s rule %d: name the value or use named regex groups", d.Name, i+1)
+		}
+	}
+
+	for field, mapping := range d.Fields {
+		var units map[string]decimal.Decimal
+		switch field {
+		case FieldPrice:
+			units = priceUnits
+		case FieldDailyChange, FieldMonthlyChange, FieldYearlyChange:
+			units = changeUnits
+		case FieldDate:
+			if mapping.Unit != "" {
+				return fmt.Errorf("Definition %s: the date has no unit", d.Name)
+			}
+		default:
+			return fmt.Errorf("Definition %s: unknown field %q", d.Name, field)
+		}
+		if _, ok := units[mapping.Unit]; units != nil && !ok {
+			return fmt.Errorf("Definition %s: unknown %s unit %q", d.Name, field, mapping.Unit)
+		}
+		if mapping.Format != "" && field != FieldDate {
+			return fmt.Errorf("Definition %s: only the date has a format", d.Name)
+		}
+		if !values[d.valueName(field)] {
+			return fmt.Errorf("Definition %s: no rule produces %q for %s", d.Name, d.valueName(field), field)
+		}
+	}
+	if !values[d.valueName(FieldPrice)] {
+		return fmt.Errorf("Definition %s: no rule produces the price", d.Name)
+	}
+	return nil
+}
+
+// valueName value a field is read from
+func (d *Definition) valueName(field string) string {
+	if from := d.Fields[field].From; from != "" {
+		return from
+	}
+	return field
+}
+
+// capture evaluate the rules, rules whose node or regex does not match produce nothing
+func (d *Definition) capture(doc *goquery.Document) map[string]string {
+	values := make(map[string]string)
+	for i, rule := range d.Rules {
+		text, ok := d.ruleText(i, doc)
+		if !ok {
+			continue
+		}
+		if d.regexes[i] == nil {
+			values[rule.Name] = text
+			continue
+		}
+		match := d.regexes[i].FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		for g, group := range d.regexes[i].SubexpNames() {
+			if group != "" && match[g] != "" {
+				values[group] = match[g]
+			}
+		}
+		if rule.Name != "" {
+			if len(match) > 1 && d.regexes[i].SubexpNames()[1] == "" {
+				values[rule.Name] = match[1]
+			} else {
+				values[rule.Name] = match[0]
+			}
+		}
+	}
+	return values
+}
+
+// ruleText text or attribute of the first node matched by rule i, whitespace collapsed
+func (d *Definition) ruleText(i int, doc *goquery.Document) (string, bool) {
+	rule := d.Rules[i]
+	var text string
+	if d.xpaths[i] != nil {
+		if len(doc.Nodes) == 0 {
+			return "", false
+		}
+		node := htmlquery.QuerySelector(doc.Nodes[0], d.xpaths[i])
+		if node == nil {
+			return "", false
+		}
+		if rule.Attr != "" {
+			text = htmlquery.SelectAttr(node, rule.Attr)
+		} else {
+			text = nodeText(node)
+		}
+	} else {
+		selection := doc.FindMatcher(d.selectors[i]).First()
+		if selection.Length() == 0 {
+			return "", false
+		}
+		if rule.Attr != "" {
+			text = selection.AttrOr(rule.Attr, "")
+		} else {
+			text = nodeText(selection.Nodes[0])
+		}
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	return text, text != ""
+}
+
+// nodeText text nodes below node separated by spaces, so the cells of a row stay apart
+func nodeText(node *html.Node) string {
+	var parts []string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			parts = append(parts, n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(node)
+	return strings.Join(parts, " ")
+}
+
+// extractor definition as the single extractor of its source
+func (d *Definition) extractor() Extractor {
+	return Extractor{
+		Name:       "definition",
+		Confidence: d.Confidence,
+		Extract: func(doc *goquery.Document) (*types.PriceInfo, error) {
+			values := d.capture(doc)
+			priceInfo := &types.PriceInfo{Provenance: make(map[string]types.Span)}
+			for _, field := range priceFields {
+				name := d.valueName(field)
+				raw, ok := values[name]
+				if !ok {
+					if field == FieldPrice {
+						return nil, errNotFound("No value %q found for the price", name)
+					}
+					continue
+				}
+				if err := d.setField(priceInfo, field, raw); err != nil {
+					return nil, errUnreadable("Cannot read %s from %q: %v", field, raw, err)
+				}
+				priceInfo.Provenance[field] = types.Span{Text: name + ": " + raw}
+			}
+			return priceInfo, nil
+		},
+	}
+}
+
+// setField convert a captured value into its unit and store it
+func (d *Definition) setField(priceInfo *types.PriceInfo, field, raw string) error {
+	mapping := d.Fields[field]
+	if field == FieldDate {
+		if mapping.Format == "" {
+			date, err := normalizeDate(raw, time.Now())
+			priceInfo.Date = date
+			return err
+		}
+		date, err := time.Parse(mapping.Format, raw)
+		if err != nil {
+			return err
+		}
+		priceInfo.Date = date.Format(DateLayout)
+		return nil
+	}
+
+	value, err := parseNumber(strings.ReplaceAll(raw, "−", "-"))
+	if err != nil {
+		return err
+	}
+	switch field {
+	case FieldPrice:
+		priceInfo.Price = value.Mul(priceUnits[mapping.Unit])
+	case FieldDailyChange:
+		priceInfo.DailyChange = value.Mul(changeUnits[mapping.Unit])
+	case FieldMonthlyChange:
+		priceInfo.MonthlyChange = value.Mul(changeUnits[mapping.Unit])
+	case FieldYearlyChange:
+		priceInfo.YearlyChange = value.Mul(changeUnits[mapping.Unit])
+	}
+	return nil
+}
+
+// DeclarativeSource price source executing a Definition
+type DeclarativeSource struct {
+	pageRecorder
+	definition *Definition
+}
+
+// NewDeclarativeSource create new source from a parsed definition
+func NewDeclarativeSource(definition *Definition) *DeclarativeSource {
+	return &DeclarativeSource{definition: definition}
+}
+
+// Name source name
+func (s *DeclarativeSource) Name() string {
+	return s.definition.Name
+}
+
+// Definition definition the source executes
+func (s *DeclarativeSource) Definition() *Definition {
+	return s.definition
+}
+
+// FetchPrice fetch the page and apply the definition
+func (s *DeclarativeSource) FetchPrice(ctx context.Context) (*types.PriceInfo, error) {
+	fmt.Printf("Fetching %s with its definition...\n", s.definition.URL) // Debug log
+	var priceInfo *types.PriceInfo
+	var err error
+
+	collector := colly.NewCollector(
+		colly.UserAgent(userAgent),
+		colly.StdlibContext(ctx),
+	)
+	collector.WithTransport(sharedTransport{})
+	collector.OnRequest(func(r *colly.Request) {
+		for k, v := range s.definition.Headers {
+			r.Headers.Set(k, v)
+		}
+	})
+	collector.OnResponse(func(r *colly.Response) {
+		s.archivePage(s.Name(), r.Request.URL.String(), r.StatusCode, *r.Headers, r.Body)
+		priceInfo, err = s.Reparse(r.Body)
+	})
+	var status int
+	collector.OnError(func(r *colly.Response, _ error) {
+		status = r.StatusCode
+		if r.StatusCode > 0 {
+			s.archivePage(s.Name(), r.Request.URL.String(), r.StatusCode, *r.Headers, r.Body)
+		}
+	})
+
+	if visitErr := collector.Visit(s.definition.URL); visitErr != nil {
+		return nil, visitError(s.Name(), s.definition.URL, status, visitErr)
+	}
+	if err != nil {
+		return nil, withURL(err, s.definition.URL)
+	}
+	return priceInfo, nil
+}
+
+// Reparse apply the definition to a fetched or archived page
+func (s *DeclarativeSource) Reparse(body []byte) (*types.PriceInfo, error) {
+	return extractPrice(s.Name(), []Extractor{s.definition.extractor()}, body)
+}
+
+// DefinitionFile one YAML file of a definitions directory
+type DefinitionFile struct {
+	Path       string      `json:"path"`
+	Checksum   string      `json:"checksum"` // SHA-256 of the file content
+	ModTime    time.Time   `json:"modTime"`
+	Definition *Definition `json:"definition,omitempty"` // Nil when the file is invalid
+	Error      string      `json:"error,omitempty"`
+}
+
+// LoadDefinitions read every *.yaml and *.yml file of dir in name order. Invalid files and
+// files reusing an earlier name are returned with Error set, a missing dir has no definitions.
+func LoadDefinitions(dir string) ([]DefinitionFile, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read definitions directory: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var files []DefinitionFile
+	names := make(map[string]string)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		file := DefinitionFile{Path: filepath.Join(dir, entry.Name())}
+		if info, err := entry.Info(); err == nil {
+			file.ModTime = info.ModTime()
+		}
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			file.Error = err.Error()
+			files = append(files, file)
+			continue
+		}
+		sum := sha256.Sum256(data)
+		file.Checksum = hex.EncodeToString(sum[:])
+		definition, err := ParseDefinition(data)
+		switch {
+		case err != nil:
+			file.Error = err.Error()
+		case names[definition.Name] != "":
+			file.Error = fmt.Sprintf("Definition %s is already defined in %s", definition.Name, names[definition.Name])
+		default:
+			names[definition.Name] = file.Path
+			file.Definition = definition
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
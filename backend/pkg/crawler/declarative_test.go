@@ -0,0 +1,172 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"backend/pkg/decimal"
+)
+
+// settlementDefinition ICE-like settlement table read with a CSS selector and named groups,
+// the yearly change from a meta tag through XPath
+const settlementDefinition = `
+name: ice-eua
+url: %s
+headers:
+  X-Api-Key: secret
+rules:
+  - selector: "table.settlements tr:contains('Dec24')"
+    regex: '(?P<day>\d{2}/\d{2}/\d{4}) (?P<settle>[\d.,]+) (?P<change>[-+−]?[\d.,]+)'
+  - xpath: "//meta[@name='yoy']"
+    attr: content
+    name: yearlyChange
+fields:
+  price: {from: settle, unit: EUR/t}
+  date: {from: day, format: 01/02/2006}
+  dailyChange: {from: change, unit: fraction}
+`
+
+// settlementPage page matching settlementDefinition
+const settlementPage = `<html><head><meta name="yoy" content="-15.3"></head><body>
+<table class="settlements">
+  <tr><th>Contract</th><th>Date</th><th>Settle</th><th>Change</th></tr>
+  <tr><td>Dec23</td><td>01/15/2024</td><td>69.10</td><td>0.010</td></tr>
+  <tr><td>Dec24</td><td>01/15/2024</td><td>71.35</td><td>−0.012</td></tr>
+</table></body></html>`
+
+// TestParseDefinition test definition validation
+func TestParseDefinition(t *testing.T) {
+	rule := "rules:\n  - selector: td\n    name: price\n"
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{"valid", "name: eex\nurl: https://example.com\n" + rule, ""},
+		{"bad name", "name: EEX Spot\nurl: https://example.com\n" + rule, "Invalid definition name"},
+		{"bad url", "name: eex\nurl: example.com\n" + rule, "http or https"},
+		{"unknown key", "name: eex\nurl: https://example.com\nselector: td\n" + rule, "not found in type"},
+		{"no rules", "name: eex\nurl: https://example.com\n", "has no rules"},
+		{"selector and xpath", "name: eex\nurl: https://example.com\nrules:\n  - selector: td\n    xpath: //td\n    name: price\n", "exactly one"},
+		{"bad selector", "name: eex\nurl: https://example.com\nrules:\n  - selector: 'td:nth('\n    name: price\n", "invalid selector"},
+		{"bad xpath", "name: eex\nurl: https://example.com\nrules:\n  - xpath: '//td['\n    name: price\n", "invalid xpath"},
+		{"bad regex", "name: eex\nurl: https://example.com\nrules:\n  - selector: td\n    regex: '(?P<price>'\n", "invalid regex"},
+		{"unnamed value", "name: eex\nurl: https://example.com\nrules:\n  - selector: td\n    regex: '[0-9.]+'\n", "name the value"},
+		{"no price", "name: eex\nurl: https://example.com\nrules:\n  - selector: td\n    name: close\n", "no rule produces the price"},
+		{"unknown source value", "name: eex\nurl: https://example.com\n" + rule + "fields:\n  date: {from: day}\n", `no rule produces "day"`},
+		{"unknown field", "name: eex\nurl: https://example.com\n" + rule + "fields:\n  volume: {from: price}\n", "unknown field"},
+		{"unknown unit", "name: eex\nurl: https://example.com\n" + rule + "fields:\n  price: {unit: USD/t}\n", "unknown price unit"},
+		{"format on a number", "name: eex\nurl: https://example.com\n" + rule + "fields:\n  price: {format: 2006}\n", "only the date"},
+		{"bad confidence", "name: eex\nurl: https://example.com\nconfidence: certain\n" + rule, "unknown confidence"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			definition, err := ParseDefinition([]byte(tt.yaml))
+			if tt.err == "" {
+				if err != nil || definition.Confidence != ConfidenceMedium {
+					t.Errorf("Expected valid definition with medium confidence, got %+v, %v", definition, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// TestDeclarativeSource test fetching and reparsing with a definition
+func TestDeclarativeSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, settlementPage)
+	}))
+	defer server.Close()
+
+	definition, err := ParseDefinition([]byte(fmt.Sprintf(settlementDefinition, server.URL)))
+	if err != nil {
+		t.Fatalf("ParseDefinition failed: %v", err)
+	}
+	source := NewDeclarativeSource(definition)
+	priceInfo, err := source.FetchPrice(context.Background())
+	if err != nil {
+		t.Fatalf("FetchPrice failed: %v", err)
+	}
+	if priceInfo.Source != "ice-eua" || priceInfo.Price != decimal.MustParse("71.35") || priceInfo.Date != "January 15, 2024" {
+		t.Errorf("Price mismatch: %+v", priceInfo)
+	}
+	if priceInfo.DailyChange != decimal.MustParse("-1.2") || priceInfo.YearlyChange != decimal.MustParse("-15.3") {
+		t.Errorf("Changes mismatch: %.2f / %.2f", priceInfo.DailyChange, priceInfo.YearlyChange)
+	}
+	if !reflect.DeepEqual(priceInfo.Missing, []string{FieldMonthlyChange}) {
+		t.Errorf("Expected monthlyChange missing, got %v", priceInfo.Missing)
+	}
+	if priceInfo.Extractor != "definition" || priceInfo.Confidence != string(ConfidenceMedium) {
+		t.Errorf("Extractor mismatch: %s/%s", priceInfo.Extractor, priceInfo.Confidence)
+	}
+	if got := priceInfo.Provenance[FieldPrice].Text; got != "settle: 71.35" {
+		t.Errorf("Price provenance mismatch: %q", got)
+	}
+
+	// Layout change: the row is gone
+	if _, err := source.Reparse([]byte(`<table class="settlements"></table>`)); Classify(err) != KindSelectorNotFound {
+		t.Errorf("Expected selector_not_found, got %v", err)
+	}
+	// The row is there but the date cannot be read
+	broken := strings.Replace(settlementPage, "01/15/2024</td><td>71.35", "15/01/2024</td><td>71.35", 1)
+	if _, err := source.Reparse([]byte(broken)); Classify(err) != KindParseFailed {
+		t.Errorf("Expected parse_failed, got %v", err)
+	}
+}
+
+// TestLoadDefinitions test loading a definitions directory
+func TestLoadDefinitions(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a-eex.yaml":  "name: eex\nurl: https://example.com\nrules:\n  - selector: td\n    name: price\n",
+		"b-copy.yml":  "name: eex\nurl: https://example.org\nrules:\n  - selector: td\n    name: price\n",
+		"c-bad.yaml":  "name: bad\nurl: https://example.com\n",
+		"notes.txt":   "not a definition",
+		"d-ice.yaml":  "name: ice\nurl: https://example.com\ndisabled: true\nrules:\n  - xpath: //td\n    name: price\n",
+		"e-tmp.yaml~": "name: tmp",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := LoadDefinitions(dir)
+	if err != nil {
+		t.Fatalf("LoadDefinitions failed: %v", err)
+	}
+	if len(loaded) != 4 {
+		t.Fatalf("Expected 4 definition files, got %d", len(loaded))
+	}
+	if loaded[0].Definition == nil || loaded[0].Definition.Name != "eex" || loaded[0].Checksum == "" {
+		t.Errorf("First file mismatch: %+v", loaded[0])
+	}
+	if loaded[1].Definition != nil || !strings.Contains(loaded[1].Error, "already defined") {
+		t.Errorf("Expected duplicate name error, got %+v", loaded[1])
+	}
+	if loaded[2].Definition != nil || !strings.Contains(loaded[2].Error, "no rules") {
+		t.Errorf("Expected invalid file error, got %+v", loaded[2])
+	}
+	if loaded[3].Definition == nil || !loaded[3].Definition.Disabled {
+		t.Errorf("Expected disabled definition, got %+v", loaded[3])
+	}
+
+	if loaded, err := LoadDefinitions(filepath.Join(dir, "missing")); err != nil || loaded != nil {
+		t.Errorf("Expected no definitions for a missing dir, got %v, %v", loaded, err)
+	}
+}
@@ -0,0 +1,238 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrorKind category of a failed fetch
+type ErrorKind string
+
+// Error kinds
+const (
+	KindNetwork          ErrorKind = "network"             // DNS, connection or timeout
+	KindHTTPStatus       ErrorKind = "http_status"         // Unexpected HTTP status
+	KindBlocked          ErrorKind = "blocked"             // Rate limited, banned or served a captcha
+	KindAuthExpired      ErrorKind = "auth_expired"        // Session expired or login failed
+	KindSelectorNotFound ErrorKind = "selector_not_found"  // Page or document lacks the expected element
+	KindParseFailed      ErrorKind = "parse_failed"        // Element found but its content could not be parsed
+	KindValidation       ErrorKind = "validation_rejected" // Parsed value failed a sanity check
+	KindCancelled        ErrorKind = "cancelled"           // Fetch abandoned by the caller
+	KindUnknown          ErrorKind = "unknown"
+)
+
+// FetchError typed crawler error
+type FetchError struct {
+	Kind       ErrorKind
+	Source     string
+	URL        string
+	StatusCode int
+	Err        error
+}
+
+// Error message of the wrapped error
+func (e *FetchError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap wrapped error
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// NewFetchError wrap err with its kind
+func NewFetchError(kind ErrorKind, source, target string, err error) *FetchError {
+	return &FetchError{Kind: kind, Source: source, URL: target, Err: err}
+}
+
+// withURL set the URL of a typed error produced without one, e.g. by Reparse
+func withURL(err error, target string) error {
+	var fe *FetchError
+	if errors.As(err, &fe) && fe.URL == "" {
+		fe.URL = target
+	}
+	return err
+}
+
+// statusError error for a non-success HTTP status: 401 means the session expired,
+// 403 and 429 that the source blocks or rate limits us
+func statusError(source, target string, status int) *FetchError {
+	text := fmt.Sprintf("%s answered %d %s", target, status, http.StatusText(status))
+	var fe *FetchError
+	switch status {
+	case http.StatusUnauthorized:
+		fe = NewFetchError(KindAuthExpired, source, target, fmt.Errorf("%w: %s", ErrSessionExpired, text))
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		fe = NewFetchError(KindBlocked, source, target, errors.New("Blocked by upstream: "+text))
+	default:
+		fe = NewFetchError(KindHTTPStatus, source, target, errors.New("Unexpected status: "+text))
+	}
+	fe.StatusCode = status
+	return fe
+}
+
+// visitError classify a failed page visit, status is 0 when no response was received
+func visitError(source, target string, status int, err error) error {
+	if status >= 300 {
+		return statusError(source, target, status)
+	}
+	return NewFetchError(Classify(err), source, target, err)
+}
+
+// captchaMarkers page fragments served instead of content when a bot check blocks us
+var captchaMarkers = []string{"captcha", "cf-challenge", "challenge-form", "are you a robot", "access denied", "unusual traffic"}
+
+// isBlockedPage whether an HTML body is a bot check or ban page
+func isBlockedPage(body []byte) bool {
+	text := strings.ToLower(string(body))
+	for _, marker := range captchaMarkers {
+		if strings.Contains(text, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classify kind of any error returned by a source
+func Classify(err error) ErrorKind {
+	var fe *FetchError
+	var netErr net.Error
+	var urlErr *url.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &fe):
+		return fe.Kind
+	case errors.Is(err, context.Canceled):
+		return KindCancelled
+	case errors.Is(err, ErrSessionExpired), errors.Is(err, ErrLoginFailed):
+		return KindAuthExpired
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr), errors.As(err, &urlErr):
+		return KindNetwork
+	default:
+		return KindUnknown
+	}
+}
+
+// ErrorRecord failed fetch kept in the error history
+type ErrorRecord struct {
+	Time       time.Time `json:"time"`
+	Source     string    `json:"source"`
+	Kind       ErrorKind `json:"kind"`
+	Message    string    `json:"message"`
+	URL        string    `json:"url,omitempty"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Attempts   int       `json:"attempts,omitempty"`
+}
+
+// NewErrorRecord describe err for source
+func NewErrorRecord(source string, err error, attempts int) ErrorRecord {
+	record := ErrorRecord{
+		Time:     time.Now().UTC(),
+		Source:   source,
+		Kind:     Classify(err),
+		Message:  err.Error(),
+		Attempts: attempts,
+	}
+	var fe *FetchError
+	if errors.As(err, &fe) {
+		record.URL = fe.URL
+		record.StatusCode = fe.StatusCode
+	}
+	return record
+}
+
+// ErrorStats error counts for /metrics
+type ErrorStats struct {
+	Total    int64                          `json:"total"`
+	ByKind   map[ErrorKind]int64            `json:"byKind"`
+	BySource map[string]map[ErrorKind]int64 `json:"bySource"`
+}
+
+// ErrorLog error counters plus a ring buffer of the latest errors
+type ErrorLog struct {
+	mu       sync.Mutex
+	stats    ErrorStats
+	ring     []ErrorRecord
+	next     int
+	size     int
+	recorded int
+}
+
+// NewErrorLog create error log keeping the last size records
+func NewErrorLog(size int) *ErrorLog {
+	if size < 1 {
+		size = 1
+	}
+	return &ErrorLog{
+		stats: ErrorStats{ByKind: make(map[ErrorKind]int64), BySource: make(map[string]map[ErrorKind]int64)},
+		ring:  make([]ErrorRecord, size),
+		size:  size,
+	}
+}
+
+// Record count and keep an error
+func (l *ErrorLog) Record(record ErrorRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.Total++
+	l.stats.ByKind[record.Kind]++
+	if l.stats.BySource[record.Source] == nil {
+		l.stats.BySource[record.Source] = make(map[ErrorKind]int64)
+	}
+	l.stats.BySource[record.Source][record.Kind]++
+
+	l.ring[l.next] = record
+	l.next = (l.next + 1) % l.size
+	if l.recorded < l.size {
+		l.recorded++
+	}
+}
+
+// Stats copy of the counters
+func (l *ErrorLog) Stats() ErrorStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := ErrorStats{
+		Total:    l.stats.Total,
+		ByKind:   make(map[ErrorKind]int64, len(l.stats.ByKind)),
+		BySource: make(map[string]map[ErrorKind]int64, len(l.stats.BySource)),
+	}
+	for kind, n := range l.stats.ByKind {
+		stats.ByKind[kind] = n
+	}
+	for source, kinds := range l.stats.BySource {
+		stats.BySource[source] = make(map[ErrorKind]int64, len(kinds))
+		for kind, n := range kinds {
+			stats.BySource[source][kind] = n
+		}
+	}
+	return stats
+}
+
+// Recent latest records newest first, optionally filtered by source and kind
+func (l *ErrorLog) Recent(limit int, source string, kind ErrorKind) []ErrorRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	records := make([]ErrorRecord, 0, l.recorded)
+	for i := 1; i <= l.recorded; i++ {
+		record := l.ring[(l.next-i+l.size)%l.size]
+		if (source == "" || record.Source == source) && (kind == "" || record.Kind == kind) {
+			records = append(records, record)
+		}
+	}
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+	return records
+}
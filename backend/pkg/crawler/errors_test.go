@@ -0,0 +1,137 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestClassify test error kinds of plain and wrapped errors
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{name: "Nil", err: nil, want: ""},
+		{name: "Typed", err: NewFetchError(KindParseFailed, "te", "", errors.New("bad")), want: KindParseFailed},
+		{name: "Wrapped typed", err: fmt.Errorf("update: %w", NewFetchError(KindBlocked, "te", "", errors.New("ban"))), want: KindBlocked},
+		{name: "Cancelled", err: context.Canceled, want: KindCancelled},
+		{name: "Deadline", err: context.DeadlineExceeded, want: KindNetwork},
+		{name: "Session", err: fmt.Errorf("%w: login page", ErrSessionExpired), want: KindAuthExpired},
+		{name: "Login", err: ErrLoginFailed, want: KindAuthExpired},
+		{name: "Plain", err: errors.New("something"), want: KindUnknown},
+		{name: "401", err: statusError("te", "u", 401), want: KindAuthExpired},
+		{name: "403", err: statusError("te", "u", 403), want: KindBlocked},
+		{name: "429", err: statusError("te", "u", 429), want: KindBlocked},
+		{name: "502", err: statusError("te", "u", 502), want: KindHTTPStatus},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.err); got != tt.want {
+				t.Errorf("Kind mismatch: expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if !errors.Is(statusError("te", "u", 401), ErrSessionExpired) {
+		t.Error("401 should match ErrSessionExpired")
+	}
+	if Retryable(statusError("te", "u", 404)) || !Retryable(statusError("te", "u", 503)) {
+		t.Error("Only server side statuses should be retried")
+	}
+}
+
+// TestSourceErrorKinds test the kinds reported by the JSON and HTML sources
+func TestSourceErrorKinds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/missing":
+			fmt.Fprint(w, `{"data":{}}`)
+		case "/invalid":
+			fmt.Fprint(w, `{"close":"n/a"}`)
+		case "/captcha":
+			fmt.Fprint(w, `<html><body><div class="g-recaptcha">Please verify you are human</div></body></html>`)
+		case "/empty":
+			fmt.Fprint(w, `<html><head><meta name="description" content="Commodities"></head></html>`)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		source PriceSource
+		want   ErrorKind
+		status int
+	}{
+		{name: "Forbidden", source: NewJSONSource(JSONConfig{URL: server.URL + "/forbidden", PriceField: "close"}), want: KindBlocked, status: 403},
+		{name: "Server error", source: NewJSONSource(JSONConfig{URL: server.URL + "/broken", PriceField: "close"}), want: KindHTTPStatus, status: 500},
+		{name: "Missing field", source: NewJSONSource(JSONConfig{URL: server.URL + "/missing", PriceField: "close"}), want: KindSelectorNotFound},
+		{name: "Invalid number", source: NewJSONSource(JSONConfig{URL: server.URL + "/invalid", PriceField: "close"}), want: KindParseFailed},
+		{name: "Captcha", source: &CarbonCrawler{url: server.URL + "/captcha", session: NewSession("", nil)}, want: KindBlocked},
+		{name: "No meta", source: &CarbonCrawler{url: server.URL + "/empty", session: NewSession("", nil)}, want: KindSelectorNotFound},
+		{name: "Settlement status", source: NewSettlementSource(SettlementConfig{URL: server.URL + "/forbidden"}), want: KindBlocked, status: 403},
+		{name: "Unreachable", source: NewJSONSource(JSONConfig{URL: "http://127.0.0.1:1/", PriceField: "close"}), want: KindNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.source.FetchPrice(context.Background())
+			if got := Classify(err); got != tt.want {
+				t.Fatalf("Kind mismatch: expected %q, got %q (%v)", tt.want, got, err)
+			}
+			record := NewErrorRecord(tt.source.Name(), err, 1)
+			if record.StatusCode != tt.status || record.Message == "" {
+				t.Errorf("Record mismatch: %+v", record)
+			}
+		})
+	}
+}
+
+// TestErrorLog test counters, ring buffer order and filters
+func TestErrorLog(t *testing.T) {
+	log := NewErrorLog(3)
+	for i, kind := range []ErrorKind{KindNetwork, KindBlocked, KindNetwork, KindParseFailed} {
+		source := "te"
+		if i%2 == 1 {
+			source = "eex"
+		}
+		log.Record(ErrorRecord{Source: source, Kind: kind, Message: fmt.Sprint(i)})
+	}
+
+	stats := log.Stats()
+	if stats.Total != 4 || stats.ByKind[KindNetwork] != 2 || stats.BySource["eex"][KindParseFailed] != 1 {
+		t.Errorf("Stats mismatch: %+v", stats)
+	}
+
+	recent := log.Recent(0, "", "")
+	if len(recent) != 3 || recent[0].Message != "3" || recent[2].Message != "1" {
+		t.Fatalf("Ring buffer mismatch: %+v", recent)
+	}
+	if recent := log.Recent(0, "te", ""); len(recent) != 1 || recent[0].Message != "2" {
+		t.Errorf("Source filter mismatch: %+v", recent)
+	}
+	if recent := log.Recent(1, "", KindNetwork); len(recent) != 1 || recent[0].Message != "2" {
+		t.Errorf("Kind filter mismatch: %+v", recent)
+	}
+}
+
+// TestFetchAllValidation test that implausible prices are rejected and counted
+func TestFetchAllValidation(t *testing.T) {
+	registry := NewRegistry(&staticSource{name: "zero", price: 0})
+	registry.SetPolicy("zero", RetryPolicy{MaxAttempts: 1})
+
+	results := registry.FetchAll(context.Background(), 0)
+	if len(results) != 1 || Classify(results[0].Err) != KindValidation || results[0].PriceInfo != nil {
+		t.Fatalf("Expected validation error, got %+v", results)
+	}
+	if stats := registry.Errors().Stats(); stats.BySource["zero"][KindValidation] != 1 {
+		t.Errorf("Validation error not counted: %+v", stats)
+	}
+}
@@ -0,0 +1,435 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Confidence how much an extracted price can be trusted
+type Confidence string
+
+// Confidence levels, from structured data down to free text
+const (
+	ConfidenceHigh   Confidence = "high"
+	ConfidenceMedium Confidence = "medium"
+	ConfidenceLow    Confidence = "low"
+)
+
+// lower one level down, low stays low
+func (c Confidence) lower() Confidence {
+	if c == ConfidenceHigh {
+		return ConfidenceMedium
+	}
+	return ConfidenceLow
+}
+
+// Extractor one way of finding the price on a page. Extract returns a KindSelectorNotFound
+// error when its element is absent and KindParseFailed when it is present but unreadable.
+type Extractor struct {
+	Name       string
+	Confidence Confidence // Confidence of a complete extraction unless Extract sets a lower one, lowered one level without a date
+	Extract    func(doc *goquery.Document) (*types.PriceInfo, error)
+}
+
+// extractPrice run the extractors in order and return the first success with the extractor
+// name and confidence set. A broken extractor falls through to the next one, so a layout change
+// degrades the confidence instead of losing the price.
+func extractPrice(source string, extractors []Extractor, body []byte) (*types.PriceInfo, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, NewFetchError(KindParseFailed, source, "", err)
+	}
+
+	kind := KindSelectorNotFound
+	var failures []string
+	for _, extractor := range extractors {
+		priceInfo, err := extractor.Extract(doc)
+		if err == nil {
+			priceInfo.Source = source
+			priceInfo.Extractor = extractor.Name
+			confidence := extractor.Confidence
+			if priceInfo.Confidence != "" {
+				confidence = Confidence(priceInfo.Confidence)
+			}
+			priceInfo.Confidence = string(confidence)
+			priceInfo.Missing = missingFields(priceInfo.Provenance)
+			if priceInfo.Date == "" {
+				priceInfo.Confidence = string(confidence.lower())
+			}
+			if priceInfo.LastUpdated.IsZero() {
+				priceInfo.LastUpdated = fetchTime()
+			}
+			if len(failures) > 0 {
+				fmt.Printf("Extracted with %s after: %s\n", extractor.Name, strings.Join(failures, "; ")) // Debug log
+			}
+			return priceInfo, nil
+		}
+		if Classify(err) == KindParseFailed {
+			kind = KindParseFailed
+		}
+		failures = append(failures, extractor.Name+": "+err.Error())
+	}
+
+	message := "No price info found"
+	if kind == KindParseFailed {
+		message = "Cannot parse price info"
+	}
+	return nil, NewFetchError(kind, source, "", fmt.Errorf("%s (%s)", message, strings.Join(failures, "; ")))
+}
+
+// errNotFound extractor element absent
+func errNotFound(format string, args ...interface{}) error {
+	return NewFetchError(KindSelectorNotFound, "", "", fmt.Errorf(format, args...))
+}
+
+// errUnreadable extractor element present but unreadable
+func errUnreadable(format string, args ...interface{}) error {
+	return NewFetchError(KindParseFailed, "", "", fmt.Errorf(format, args...))
+}
+
+// Field name candidates of embedded data, compared lower case without separators
+var (
+	priceKeys   = []string{"last", "price", "close"}
+	dateKeys    = []string{"date", "lastupdate", "datetime", "pricedate"}
+	dailyKeys   = []string{"dailypercentualchange", "dailychange", "changepercent", "pch"}
+	monthlyKeys = []string{"monthlypercentualchange", "monthlychange"}
+	yearlyKeys  = []string{"yearlypercentualchange", "yearlychange"}
+	labelKeys   = []string{"name", "symbol", "ticker", "title"}
+)
+
+// ScriptExtractor price from data embedded in the page: JSON-LD blocks or the JSON literals assigned
+// to one of variables in an inline script. The first object with a price field is used, objects
+// named without label are skipped. A JSON-LD object not named with label is only medium confidence,
+// generic data such as a product offer also has a price.
+func ScriptExtractor(label string, variables ...string) Extractor {
+	// embedded one block of data, named when assigned to one of variables
+	type embedded struct {
+		value interface{}
+		named bool
+	}
+	return Extractor{
+		Name:       "script",
+		Confidence: ConfidenceHigh,
+		Extract: func(doc *goquery.Document) (*types.PriceInfo, error) {
+			var values []embedded
+			var failures []string
+			doc.Find("script").Each(func(_ int, script *goquery.Selection) {
+				text := script.Text()
+				if script.AttrOr("type", "") == "application/ld+json" {
+					var value interface{}
+					if err := json.Unmarshal([]byte(text), &value); err != nil {
+						failures = append(failures, "JSON-LD: "+err.Error())
+						return
+					}
+					values = append(values, embedded{value: value})
+					return
+				}
+				for _, variable := range variables {
+					assigned, err := assignedJSON(text, variable)
+					if err != nil {
+						failures = append(failures, variable+": "+err.Error())
+					}
+					for _, value := range assigned {
+						values = append(values, embedded{value: value, named: true})
+					}
+				}
+			})
+
+			for _, data := range values {
+				object, labelled := findPriceObject(data.value, strings.ToLower(label))
+				if object == nil {
+					continue
+				}
+				priceInfo, err := priceFromObject(object)
+				if err == nil && !data.named && !labelled {
+					priceInfo.Confidence = string(ConfidenceMedium)
+				}
+				return priceInfo, err
+			}
+			if len(failures) > 0 {
+				return nil, errUnreadable("Invalid embedded data: %s", strings.Join(failures, "; "))
+			}
+			return nil, errNotFound("No embedded price data")
+		},
+	}
+}
+
+// assignedJSON decode the JSON literal following every "variable =" in a script, e.g. a page that
+// first declares the variable empty and fills it later. Undecodable assignments are skipped, the
+// first of their errors is returned with the values that could be decoded.
+func assignedJSON(script, variable string) ([]interface{}, error) {
+	var values []interface{}
+	var firstErr error
+	for rest := script; ; {
+		i := strings.Index(rest, variable)
+		if i < 0 {
+			return values, firstErr
+		}
+		rest = rest[i+len(variable):]
+		assignment := strings.TrimSpace(rest)
+		if !strings.HasPrefix(assignment, "=") || strings.HasPrefix(assignment, "==") {
+			continue
+		}
+		var value interface{}
+		if err := json.NewDecoder(strings.NewReader(strings.TrimSpace(assignment[1:]))).Decode(&value); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		values = append(values, value)
+	}
+}
+
+// findPriceObject depth-first search for an object carrying a price field. Objects named
+// after something else are skipped with their children, e.g. the offers of another product.
+// labelled reports whether the object or one of its parents is named after label.
+func findPriceObject(value interface{}, label string) (object map[string]interface{}, labelled bool) {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		if name, ok := lookupKey(node, labelKeys); ok && label != "" {
+			text, _ := name.(string)
+			if !strings.Contains(strings.ToLower(text), label) {
+				return nil, false
+			}
+			// Children belong to the labelled object
+			object, _ := findPriceObject(node, "")
+			return object, object != nil
+		}
+		if _, ok := lookupKey(node, priceKeys); ok {
+			return node, false
+		}
+		keys := make([]string, 0, len(node))
+		for key := range node {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if object, labelled := findPriceObject(node[key], label); object != nil {
+				return object, labelled
+			}
+		}
+	case []interface{}:
+		for _, child := range node {
+			if object, labelled := findPriceObject(child, label); object != nil {
+				return object, labelled
+			}
+		}
+	}
+	return nil, false
+}
+
+// lookupKey first present candidate key, ignoring case, "_" and "-"
+func lookupKey(object map[string]interface{}, candidates []string) (interface{}, bool) {
+	normalized := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		normalized[strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))] = value
+	}
+	for _, key := range candidates {
+		if value, ok := normalized[key]; ok && value != nil {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// priceFromObject build price info from an embedded data object
+func priceFromObject(object map[string]interface{}) (*types.PriceInfo, error) {
+	raw, _ := lookupKey(object, priceKeys)
+	price, err := jsonNumber(raw)
+	if err != nil {
+		return nil, errUnreadable("Embedded price %v: %v", raw, err)
+	}
+	priceInfo := &types.PriceInfo{Price: price, Provenance: map[string]types.Span{FieldPrice: {Text: fmt.Sprint(raw)}}}
+	if raw, ok := lookupKey(object, dateKeys); ok {
+		text, _ := raw.(string)
+		if date, err := normalizeDate(text, time.Now()); err == nil {
+			priceInfo.Date = date
+			priceInfo.Provenance[FieldDate] = types.Span{Text: text}
+		}
+	}
+	for _, change := range changeFields(priceInfo) {
+		raw, ok := lookupKey(object, change.keys)
+		if !ok {
+			continue
+		}
+		if value, err := jsonNumber(raw); err == nil {
+			*change.value = value
+			priceInfo.Provenance[change.field] = types.Span{Text: fmt.Sprint(raw)}
+		}
+	}
+	return priceInfo, nil
+}
+
+// changeField a change field with the embedded keys and table headers it may be found under
+type changeField struct {
+	field   string
+	keys    []string
+	headers []string
+	value   *decimal.Decimal
+}
+
+// changeFields change fields of priceInfo
+func changeFields(priceInfo *types.PriceInfo) []changeField {
+	return []changeField{
+		{FieldDailyChange, dailyKeys, dailyHeaders, &priceInfo.DailyChange},
+		{FieldMonthlyChange, monthlyKeys, monthlyHeaders, &priceInfo.MonthlyChange},
+		{FieldYearlyChange, yearlyKeys, yearlyHeaders, &priceInfo.YearlyChange},
+	}
+}
+
+// Table header names of each field, compared lower case
+var (
+	priceHeaders   = []string{"price", "last", "close", "settlement"}
+	dailyHeaders   = []string{"%", "day %", "chg %", "change %", "daily"}
+	monthlyHeaders = []string{"monthly", "month", "1m"}
+	yearlyHeaders  = []string{"yoy", "yearly", "1y"}
+	dateHeaders    = []string{"date", "time"}
+)
+
+// TableExtractor price from the table row whose first cell contains label, columns are
+// located by their header names so reordered or added columns are tolerated
+func TableExtractor(label string) Extractor {
+	return Extractor{
+		Name:       "table",
+		Confidence: ConfidenceMedium,
+		Extract: func(doc *goquery.Document) (*types.PriceInfo, error) {
+			var headers, cells []string
+			doc.Find("table").EachWithBreak(func(_ int, table *goquery.Selection) bool {
+				var tableHeaders []string
+				table.Find("tr").EachWithBreak(func(_ int, row *goquery.Selection) bool {
+					if th := row.Find("th"); th.Length() > 0 {
+						tableHeaders = cellTexts(th)
+						return true
+					}
+					td := row.Find("td")
+					if td.Length() > 1 && strings.Contains(strings.ToLower(td.First().Text()), strings.ToLower(label)) {
+						headers, cells = tableHeaders, cellTexts(td)
+						return false
+					}
+					return true
+				})
+				return cells == nil
+			})
+			if cells == nil {
+				return nil, errNotFound("No table row for %s", label)
+			}
+
+			// column cell under one of names, with its header for provenance
+			column := func(names []string) (string, string, bool) {
+				for i, header := range headers {
+					for _, name := range names {
+						if strings.ToLower(header) == name && i < len(cells) {
+							return cells[i], header, true
+						}
+					}
+				}
+				return "", "", false
+			}
+
+			text, header, ok := column(priceHeaders)
+			if !ok {
+				// Without headers the price is the first number after the label
+				for i, cell := range cells[1:] {
+					if _, err := parseNumber(cell); err == nil {
+						text, header, ok = cell, fmt.Sprintf("column %d", i+2), true
+						break
+					}
+				}
+			}
+			if !ok {
+				return nil, errUnreadable("Table row for %s has no price column", label)
+			}
+			price, err := parseNumber(text)
+			if err != nil {
+				return nil, errUnreadable("Cannot parse table price %q: %v", text, err)
+			}
+
+			priceInfo := &types.PriceInfo{Price: price, Provenance: map[string]types.Span{FieldPrice: {Text: header + ": " + text}}}
+			if text, header, ok := column(dateHeaders); ok {
+				if date, err := normalizeDate(text, time.Now()); err == nil {
+					priceInfo.Date = date
+					priceInfo.Provenance[FieldDate] = types.Span{Text: header + ": " + text}
+				}
+			}
+			for _, change := range changeFields(priceInfo) {
+				text, header, ok := column(change.headers)
+				if !ok {
+					continue
+				}
+				if value, err := parseNumber(text); err == nil {
+					*change.value = value
+					priceInfo.Provenance[change.field] = types.Span{Text: header + ": " + text}
+				}
+			}
+			return priceInfo, nil
+		},
+	}
+}
+
+// cellTexts trimmed text of each cell
+func cellTexts(cells *goquery.Selection) []string {
+	texts := make([]string, 0, cells.Length())
+	cells.Each(func(_ int, cell *goquery.Selection) {
+		texts = append(texts, strings.TrimSpace(cell.Text()))
+	})
+	return texts
+}
+
+// MetaExtractor price from the sentence in a meta tag, e.g. the page description
+func MetaExtractor(selector string, parse func(text string) (*types.PriceInfo, error)) Extractor {
+	return Extractor{
+		Name:       "meta",
+		Confidence: ConfidenceLow,
+		Extract: func(doc *goquery.Document) (*types.PriceInfo, error) {
+			content, ok := doc.Find(selector).First().Attr("content")
+			if !ok {
+				return nil, errNotFound("No meta tag %s", selector)
+			}
+			priceInfo, err := parse(content)
+			if err != nil {
+				return nil, errUnreadable("%v", err)
+			}
+			return priceInfo, nil
+		},
+	}
+}
+
+// dateLayouts accepted date formats, yearless ones take the year of now
+var dateLayouts = []string{
+	time.RFC3339, "2006-01-02T15:04:05", "2006-01-02", DateLayout, "Jan 2, 2006", "Jan/02/2006", "2 January 2006",
+}
+
+// yearlessLayouts short dates of quote tables, e.g. "Jan/15"
+var yearlessLayouts = []string{"Jan/02", "Jan 2"}
+
+// normalizeDate convert a date in any accepted format to DateLayout
+func normalizeDate(text string, now time.Time) (string, error) {
+	text = strings.TrimSpace(text)
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, text); err == nil {
+			return date.Format(DateLayout), nil
+		}
+	}
+	for _, layout := range yearlessLayouts {
+		if date, err := time.Parse(layout, text); err == nil {
+			date = date.AddDate(now.Year(), 0, 0)
+			// A date ahead of now belongs to last year, e.g. "Dec/31" read on January 2
+			if date.After(now.AddDate(0, 0, 1)) {
+				date = date.AddDate(-1, 0, 0)
+			}
+			return date.Format(DateLayout), nil
+		}
+	}
+	return "", errors.New("Unknown date format " + text)
+}
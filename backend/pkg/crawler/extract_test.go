@@ -0,0 +1,107 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+
+	"backend/pkg/decimal"
+)
+
+// carbonPage TradingEconomics-like page assembled from optional parts
+func carbonPage(parts ...string) []byte {
+	page := "<html><head>"
+	for _, part := range parts {
+		page += part
+	}
+	return []byte(page + "</head><body></body></html>")
+}
+
+const (
+	chartScript = `<script>var TEChartsMeta = [{"name":"EU Carbon Permits","symbol":"EECXM:IND","last":71.35,` +
+		`"date":"2024-01-15T00:00:00","daily_percentual_change":1.2,"yearly_percentual_change":-15.3}];</script>`
+	brokenScript = `<script>var TEChartsMeta = [{"name":"EU Carbon Permits","last":71.35,;</script>`
+	otherScript  = `<script type="application/ld+json">{"@type":"Product","name":"Brent","offers":{"price":"80.1"}}</script>`
+	offerScript  = `<script type="application/ld+json">{"@type":"Offer","price":"71.10","date":"2024-01-15"}</script>`
+	valueScript  = `<script type="application/ld+json">{"event":"pageview","value":12}</script>`
+	laterScript  = `<script>var TEChartsMeta = []; TEChartsMeta = [{"name":"EU Carbon Permits","last":71.30,"date":"2024-01-15"}];</script>`
+	quoteTable   = `<table><tr><th>Commodity</th><th>Price</th><th>Day</th><th>%</th><th>Weekly</th><th>Monthly</th>` +
+		`<th>YoY</th><th>Date</th></tr><tr><td>EU Carbon Permits</td><td>71.40</td><td>0.85</td><td>1.20%</td>` +
+		`<td>2.1%</td><td>-4.10%</td><td>-15.30%</td><td>Jan/15/2024</td></tr></table>`
+	undatedTable = `<table><tr><td>EU Carbon Permits</td><td>71.40</td><td>1.20%</td></tr></table>`
+	brokenTable  = `<table><tr><th>Commodity</th><th>Price</th></tr><tr><td>EU Carbon Permits</td><td>n/a</td></tr></table>`
+	metaTag      = `<meta name="description" content="EU Carbon Permits rose to 71.45 EUR on January 15, 2024, up 1.2% from the previous day.">`
+	brokenMeta   = `<meta name="description" content="EU Carbon Permits data is temporarily unavailable">`
+)
+
+// TestExtractPrice test extractor order, fallback on layout changes and confidence
+func TestExtractPrice(t *testing.T) {
+	tests := []struct {
+		name       string
+		page       []byte
+		extractor  string
+		confidence Confidence
+		price      float64
+		kind       ErrorKind
+	}{
+		{name: "Embedded data", page: carbonPage(chartScript, quoteTable, metaTag), extractor: "script", confidence: ConfidenceHigh, price: 71.35},
+		{name: "Unrelated data skipped", page: carbonPage(otherScript, quoteTable), extractor: "table", confidence: ConfidenceMedium, price: 71.40},
+		{name: "Unlabelled data is medium", page: carbonPage(offerScript, quoteTable), extractor: "script", confidence: ConfidenceMedium, price: 71.10},
+		{name: "Generic value skipped", page: carbonPage(valueScript, quoteTable), extractor: "table", confidence: ConfidenceMedium, price: 71.40},
+		{name: "Later assignment", page: carbonPage(laterScript, quoteTable), extractor: "script", confidence: ConfidenceHigh, price: 71.30},
+		{name: "Broken script falls back", page: carbonPage(brokenScript, quoteTable), extractor: "table", confidence: ConfidenceMedium, price: 71.40},
+		{name: "Table without date", page: carbonPage(undatedTable), extractor: "table", confidence: ConfidenceLow, price: 71.40},
+		{name: "Meta only", page: carbonPage(brokenTable, metaTag), extractor: "meta", confidence: ConfidenceLow, price: 71.45},
+		{name: "Nothing", page: carbonPage(otherScript), kind: KindSelectorNotFound},
+		{name: "Everything broken", page: carbonPage(brokenScript, brokenTable, brokenMeta), kind: KindParseFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			priceInfo, err := extractPrice("tradingeconomics", carbonExtractors(), tt.page)
+			if tt.kind != "" {
+				if Classify(err) != tt.kind {
+					t.Fatalf("Expected %s, got %v", tt.kind, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Extraction failed: %v", err)
+			}
+			if priceInfo.Extractor != tt.extractor || priceInfo.Confidence != string(tt.confidence) || priceInfo.Price != decimal.FromFloat(tt.price) {
+				t.Errorf("Expected %.2f from %s with %s confidence, got %+v", tt.price, tt.extractor, tt.confidence, priceInfo)
+			}
+			if priceInfo.Source != "tradingeconomics" || priceInfo.LastUpdated.IsZero() {
+				t.Errorf("Missing source metadata: %+v", priceInfo)
+			}
+		})
+	}
+
+	priceInfo, _ := extractPrice("tradingeconomics", carbonExtractors(), carbonPage(chartScript))
+	if priceInfo.Date != "January 15, 2024" || priceInfo.DailyChange != decimal.MustParse("1.2") || priceInfo.YearlyChange != decimal.MustParse("-15.3") {
+		t.Errorf("Embedded fields mismatch: %+v", priceInfo)
+	}
+	priceInfo, _ = extractPrice("tradingeconomics", carbonExtractors(), carbonPage(quoteTable))
+	if priceInfo.Date != "January 15, 2024" || priceInfo.DailyChange != decimal.MustParse("1.2") || priceInfo.MonthlyChange != decimal.MustParse("-4.1") || priceInfo.YearlyChange != decimal.MustParse("-15.3") {
+		t.Errorf("Table fields mismatch: %+v", priceInfo)
+	}
+}
+
+// TestNormalizeDate test accepted layouts and the year of short dates
+func TestNormalizeDate(t *testing.T) {
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := map[string]string{
+		"2024-01-15":           "January 15, 2024",
+		"2024-01-15T17:30:00Z": "January 15, 2024",
+		"January 15, 2024":     "January 15, 2024",
+		"Jan/15/2024":          "January 15, 2024",
+		"Jan/02":               "January 2, 2024",
+		"Dec/29":               "December 29, 2023",
+	}
+	for input, want := range tests {
+		if got, err := normalizeDate(input, now); err != nil || got != want {
+			t.Errorf("normalizeDate(%q): expected %q, got %q (%v)", input, want, got, err)
+		}
+	}
+	if _, err := normalizeDate("yesterday", now); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
@@ -0,0 +1,185 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// JSONConfig generic JSON API source configuration
+type JSONConfig struct {
+	Name       string            // Source name, defaults to "jsonapi"
+	URL        string            // Endpoint returning a JSON document
+	Headers    map[string]string // Extra request headers, e.g. an API key
+	PriceField string            // Dotted path to the price, e.g. "data.0.close"
+	DateField  string            // Dotted path to the trading date, optional
+	DateLayout string            // Go time layout of the date field, defaults to RFC3339
+}
+
+// JSONSource reads the carbon price from a JSON API
+type JSONSource struct {
+	pageRecorder
+	config JSONConfig
+	client *http.Client
+}
+
+// NewJSONSource create new JSON API source
+func NewJSONSource(config JSONConfig) *JSONSource {
+	if config.Name == "" {
+		config.Name = "jsonapi"
+	}
+	if config.PriceField == "" {
+		config.PriceField = "price"
+	}
+	if config.DateLayout == "" {
+		config.DateLayout = time.RFC3339
+	}
+	return &JSONSource{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second, Transport: sharedTransport{}},
+	}
+}
+
+// Name source name
+func (s *JSONSource) Name() string {
+	return s.config.Name
+}
+
+// FetchPrice get price from the JSON API
+func (s *JSONSource) FetchPrice(ctx context.Context) (*types.PriceInfo, error) {
+	fmt.Printf("Fetching JSON price from %s...\n", s.config.URL) // Debug log
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range s.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, visitError(s.Name(), s.config.URL, 0, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(s.Name(), s.config.URL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, visitError(s.Name(), s.config.URL, 0, err)
+	}
+	s.archivePage(s.Name(), s.config.URL, resp.StatusCode, resp.Header, body)
+
+	priceInfo, err := s.Reparse(body)
+	if err != nil {
+		return nil, withURL(err, s.config.URL)
+	}
+	return priceInfo, nil
+}
+
+// Reparse extract price info from an archived JSON response
+func (s *JSONSource) Reparse(body []byte) (*types.PriceInfo, error) {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, NewFetchError(KindParseFailed, s.Name(), "", fmt.Errorf("Cannot decode JSON response: %w", err))
+	}
+	priceInfo, err := s.parseDocument(doc)
+	if err != nil {
+		return nil, NewFetchError(s.errorKind(err), s.Name(), "", err)
+	}
+	return priceInfo, nil
+}
+
+// errMissingField configured field absent from the document
+var errMissingField = errors.New("field not found")
+
+// errorKind missing fields are selector errors, everything else failed to parse
+func (s *JSONSource) errorKind(err error) ErrorKind {
+	if errors.Is(err, errMissingField) {
+		return KindSelectorNotFound
+	}
+	return KindParseFailed
+}
+
+// parseDocument extract price info from a decoded JSON document
+func (s *JSONSource) parseDocument(doc interface{}) (*types.PriceInfo, error) {
+	fetchedAt := fetchTime()
+
+	rawPrice, ok := lookupPath(doc, s.config.PriceField)
+	if !ok {
+		return nil, fmt.Errorf("Price field %q: %w", s.config.PriceField, errMissingField)
+	}
+	price, err := jsonNumber(rawPrice)
+	if err != nil {
+		return nil, fmt.Errorf("Price field %q: %w", s.config.PriceField, err)
+	}
+
+	date := fetchedAt
+	if s.config.DateField != "" {
+		rawDate, ok := lookupPath(doc, s.config.DateField)
+		if !ok {
+			return nil, fmt.Errorf("Date field %q: %w", s.config.DateField, errMissingField)
+		}
+		text, ok := rawDate.(string)
+		if !ok {
+			return nil, fmt.Errorf("Date field %q is not a string", s.config.DateField)
+		}
+		if date, err = time.Parse(s.config.DateLayout, text); err != nil {
+			return nil, fmt.Errorf("Cannot parse date %q: %w", text, err)
+		}
+	}
+
+	return &types.PriceInfo{
+		Price:       price,
+		Date:        date.Format(DateLayout),
+		LastUpdated: fetchedAt,
+		Source:      s.Name(),
+		Missing:     []string{FieldDailyChange, FieldMonthlyChange, FieldYearlyChange},
+	}, nil
+}
+
+// lookupPath walk a dotted path through decoded JSON, numeric segments index arrays
+func lookupPath(doc interface{}, path string) (interface{}, bool) {
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			var index int
+			if _, err := fmt.Sscanf(key, "%d", &index); err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// jsonNumber convert a JSON number or numeric string to a decimal
+func jsonNumber(value interface{}) (decimal.Decimal, error) {
+	switch v := value.(type) {
+	case float64:
+		return decimal.FromFloat(v), nil
+	case string:
+		return parseNumber(v)
+	default:
+		return decimal.Zero, fmt.Errorf("unsupported value type %T", value)
+	}
+}
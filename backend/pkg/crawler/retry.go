@@ -0,0 +1,105 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"backend/pkg/types"
+)
+
+// RetryPolicy how often and how patiently a source is fetched
+type RetryPolicy struct {
+	MaxAttempts    int           // Total attempts including the first, at least 1
+	Timeout        time.Duration // Deadline of a single attempt, 0 relies on the caller's context
+	InitialBackoff time.Duration // Wait before the second attempt
+	MaxBackoff     time.Duration // Upper bound of a single wait
+	Multiplier     float64       // Backoff growth per attempt
+	Jitter         float64       // Random fraction of the wait added or removed, 0 to 1
+}
+
+// DefaultRetryPolicy 3 attempts of at most 20s, waiting about 2s then 4s
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		Timeout:        20 * time.Second,
+		InitialBackoff: 2 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// Backoff wait before attempt+1, attempt counts from 1
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(wait)
+}
+
+// Retryable whether another attempt may succeed. Only network errors, server side HTTP
+// errors and unclassified errors are retried: a ban, an expired session or a page without
+// the price would only be fetched again to fail the same way.
+func Retryable(err error) bool {
+	switch Classify(err) {
+	case KindNetwork, KindUnknown:
+		return true
+	case KindHTTPStatus:
+		var fe *FetchError
+		return errors.As(err, &fe) && (fe.StatusCode >= 500 || fe.StatusCode == http.StatusRequestTimeout)
+	default:
+		return false
+	}
+}
+
+// FetchWithRetry fetch a source under the policy, returns the number of attempts made
+func FetchWithRetry(ctx context.Context, source PriceSource, policy RetryPolicy) (*types.PriceInfo, int, error) {
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		var priceInfo *types.PriceInfo
+		priceInfo, err = FetchAttempt(ctx, source, policy.Timeout)
+		if err == nil {
+			return priceInfo, attempt, nil
+		}
+		if attempt >= attempts || !Retryable(err) || ctx.Err() != nil {
+			return nil, attempt, err
+		}
+
+		wait := policy.Backoff(attempt)
+		fmt.Printf("Attempt %d/%d for %s failed: %v, retrying in %s\n", attempt, attempts, source.Name(), err, wait.Round(time.Millisecond)) // Debug log
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, attempt, fmt.Errorf("%w, last error: %v", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// FetchAttempt single attempt bounded by timeout, 0 relies on the caller's context
+func FetchAttempt(ctx context.Context, source PriceSource, timeout time.Duration) (*types.PriceInfo, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return source.FetchPrice(ctx)
+}
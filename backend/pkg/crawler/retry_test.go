@@ -0,0 +1,148 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// flakySource fails a fixed number of times before returning a price
+type flakySource struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (s *flakySource) Name() string { return "flaky" }
+
+func (s *flakySource) FetchPrice(ctx context.Context) (*types.PriceInfo, error) {
+	s.calls++
+	if s.calls <= s.failures {
+		return nil, s.err
+	}
+	return &types.PriceInfo{Price: decimal.New(70, 0), Source: s.Name()}, nil
+}
+
+// hangingSource blocks until its context is done
+type hangingSource struct{}
+
+func (hangingSource) Name() string { return "hanging" }
+
+func (hangingSource) FetchPrice(ctx context.Context) (*types.PriceInfo, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// deadlineSource fails when its context carries a deadline
+type deadlineSource struct{}
+
+func (deadlineSource) Name() string { return "deadline" }
+
+func (deadlineSource) FetchPrice(ctx context.Context) (*types.PriceInfo, error) {
+	if _, ok := ctx.Deadline(); ok {
+		return nil, errors.New("Unexpected deadline")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &types.PriceInfo{Price: decimal.New(70, 0)}, nil
+}
+
+// fastPolicy retry policy without noticeable waits
+func fastPolicy(attempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: attempts, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Multiplier: 2}
+}
+
+// TestFetchWithRetry test retries, permanent errors and exhausted attempts
+func TestFetchWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		source       *flakySource
+		attempts     int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "First attempt", source: &flakySource{}, attempts: 3, wantAttempts: 1},
+		{name: "Recovers", source: &flakySource{failures: 2, err: errors.New("timeout")}, attempts: 3, wantAttempts: 3},
+		{name: "Exhausted", source: &flakySource{failures: 5, err: errors.New("timeout")}, attempts: 3, wantAttempts: 3, wantErr: true},
+		{name: "Session expired is final", source: &flakySource{failures: 5, err: fmt.Errorf("%w: login page", ErrSessionExpired)}, attempts: 3, wantAttempts: 1, wantErr: true},
+		{name: "Zero attempts still fetches", source: &flakySource{}, attempts: 0, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			priceInfo, attempts, err := FetchWithRetry(context.Background(), tt.source, fastPolicy(tt.attempts))
+			if attempts != tt.wantAttempts {
+				t.Errorf("Attempts mismatch: expected %d, got %d", tt.wantAttempts, attempts)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Error mismatch: expected error %v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && priceInfo.Price != decimal.New(70, 0) {
+				t.Errorf("Price mismatch: expected 70, got %.2f", priceInfo.Price)
+			}
+		})
+	}
+}
+
+// TestFetchWithRetryDeadlines test the per-attempt timeout and cancellation during backoff
+func TestFetchWithRetryDeadlines(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 2, Timeout: 20 * time.Millisecond, InitialBackoff: time.Millisecond}
+	start := time.Now()
+	_, attempts, err := FetchWithRetry(context.Background(), hangingSource{}, policy)
+	if !errors.Is(err, context.DeadlineExceeded) || attempts != 2 {
+		t.Fatalf("Expected 2 timed out attempts, got %d: %v", attempts, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Attempt timeout not applied, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	source := &flakySource{failures: 5, err: errors.New("timeout")}
+	policy = RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+	start = time.Now()
+	_, attempts, err = FetchWithRetry(ctx, source, policy)
+	if !errors.Is(err, context.DeadlineExceeded) || attempts != 1 {
+		t.Fatalf("Expected cancellation during backoff after 1 attempt, got %d: %v", attempts, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Backoff not interrupted, took %s", elapsed)
+	}
+}
+
+// TestFetchAttempt test a zero timeout leaves the caller's context unbounded
+func TestFetchAttempt(t *testing.T) {
+	if _, err := FetchAttempt(context.Background(), deadlineSource{}, 0); err != nil {
+		t.Fatalf("Zero timeout attempt failed: %v", err)
+	}
+	if _, err := FetchAttempt(context.Background(), deadlineSource{}, time.Second); err == nil {
+		t.Error("Positive timeout not applied")
+	}
+	_, err := FetchAttempt(context.Background(), hangingSource{}, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+// TestBackoff test exponential growth, cap and jitter bounds
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second} {
+		if got := policy.Backoff(attempt); got != want {
+			t.Errorf("Attempt %d: expected %s, got %s", attempt, want, got)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(2); got < time.Second || got > 3*time.Second {
+			t.Fatalf("Jittered backoff %s outside [1s, 3s]", got)
+		}
+	}
+}
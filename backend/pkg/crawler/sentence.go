@@ -0,0 +1,304 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// Field names used in provenance and missing lists, same as the JSON names
+const (
+	FieldPrice         = "price"
+	FieldDate          = "date"
+	FieldDailyChange   = "dailyChange"
+	FieldMonthlyChange = "monthlyChange"
+	FieldYearlyChange  = "yearlyChange"
+)
+
+// priceFields every field an extractor can fill, in report order
+var priceFields = []string{FieldPrice, FieldDate, FieldDailyChange, FieldMonthlyChange, FieldYearlyChange}
+
+// Direction words of a change. Verbs and adverbs precede the number ("up 1.2%", "has fallen by 4%"),
+// nouns and comparatives follow it ("a 2.5% gain", "15% higher than a year ago").
+var (
+	risingWords  = "up|higher|rose|risen|rise|rises|rising|gained|gains|gain|increased|increases|increase|climbed|climbs|added|adds|jumped|jumps|jump|advanced|advances|advance|surged|surges|soared|soars|rallied|rallies|rebounded|rebounds"
+	fallingWords = "down|lower|fell|fallen|fall|falls|falling|lost|loses|loss|declined|declines|decline|dropped|drops|drop|decreased|decreases|decrease|slipped|slips|shed|sheds|slid|slides|eased|eases|plunged|plunges|tumbled|tumbles|retreated|retreats|sank|sunk|slumped|slump"
+	risingSet    = wordSet(risingWords)
+)
+
+// changePattern a percentage change with its direction word before or after the number
+var changePattern = regexp.MustCompile(`(?i)(?:\b(` + risingWords + `|` + fallingWords + `)` +
+	`(?:\s+(?:by|of|about|around|nearly|almost|roughly|some|over|only|just|more\s+than|less\s+than))*\s+)?` +
+	`([+-]?\d+(?:\.\d+)?)\s?%` +
+	`(?:\s+(` + risingWords + `|` + fallingWords + `)\b)?`)
+
+// unchangedPattern a change of exactly zero
+var unchangedPattern = regexp.MustCompile(`(?i)\b(?:unchanged|flat)\b`)
+
+// priceExpr a price in euros, "71.35 EUR", "EUR 71.35" or "€71.35"
+const priceExpr = `(?:(\d+(?:\.\d+)?)\s*(?:EUR\b|euros?\b|€)|(?:EUR|€)\s*(\d+(?:\.\d+)?))`
+
+var (
+	// quotedPricePattern price introduced as a level, preferred over amounts like "rose 1.20 EUR"
+	quotedPricePattern = regexp.MustCompile(`(?i)\b(?:to|at|of|reached|hit|near|around)\s+` + priceExpr)
+	// pricePattern any price
+	pricePattern = regexp.MustCompile(`(?i)` + priceExpr)
+)
+
+// sentenceDatePattern trading date, "January 15, 2024", "Jan 15, 2024", "15 January 2024" or "2024-01-15"
+var sentenceDatePattern = regexp.MustCompile(`(?i)\b(?:(?:January|February|March|April|May|June|July|August|September|October|November|December|Jan|Feb|Mar|Apr|Jun|Jul|Aug|Sep|Sept|Oct|Nov|Dec)\.?\s+\d{1,2},\s*\d{4}` +
+	`|\d{1,2}\s+(?:January|February|March|April|May|June|July|August|September|October|November|December)\s+\d{4}` +
+	`|\d{4}-\d{2}-\d{2})\b`)
+
+// periodPatterns phrases naming the period of a change. Week and year-to-date changes are
+// recognised only so they are not taken for the daily or yearly change.
+var periodPatterns = []struct {
+	field   string
+	pattern *regexp.Regexp
+}{
+	{"", regexp.MustCompile(`(?i)\b(?:year[\s-]to[\s-]date|ytd|since\s+the\s+(?:start|beginning)\s+of\s+(?:the\s+)?year|so\s+far\s+this\s+year)\b`)},
+	{"", regexp.MustCompile(`(?i)\b(?:week|weeks|weekly|week-on-week|w/w)\b`)},
+	{FieldYearlyChange, regexp.MustCompile(`(?i)\b(?:year|years|yearly|annual|annually|year-on-year|y/y|yoy|12\s+months|twelve\s+months)\b`)},
+	{FieldMonthlyChange, regexp.MustCompile(`(?i)\b(?:month|monthly|month-on-month|m/m|30\s+days|four\s+weeks)\b`)},
+	{FieldDailyChange, regexp.MustCompile(`(?i)\b(?:(?:previous|prior|last|past|single)\s+(?:trading\s+)?(?:day|session)|yesterday|daily|day-on-day|d/d|on\s+the\s+day|today|24\s+hours|intraday|session)\b`)},
+}
+
+// clauseBoundary separators between the clauses of a sentence
+var clauseBoundary = regexp.MustCompile(`(?i)[,;:()]|\s(?:and|but|while|whereas|although|though|after|before)\s`)
+
+// sentenceEnd full stop followed by a space or the end of the text, see splitSentences
+var sentenceEnd = regexp.MustCompile(`[.!?](?:\s+|$)`)
+
+// wordSet lookup set of a "|" separated word list
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Split(words, "|") {
+		set[word] = true
+	}
+	return set
+}
+
+// changeMention a percentage change found in the text
+type changeMention struct {
+	value      decimal.Decimal
+	start, end int    // Span of the change itself
+	field      string // Period field, "" when unknown or ignored
+	strength   int    // 2 when the period is in the same clause, 1 elsewhere in the sentence, -1 without period
+	span       types.Span
+}
+
+// parseSentence read price, date and each change with its own direction and period from a
+// free-text description. Every field found records its span; fields not stated are listed in
+// Missing instead of being reported as zero.
+func parseSentence(text string) (*types.PriceInfo, error) {
+	info := &types.PriceInfo{Provenance: make(map[string]types.Span)}
+
+	loc := quotedPricePattern.FindStringSubmatchIndex(text)
+	if loc == nil {
+		loc = pricePattern.FindStringSubmatchIndex(text)
+	}
+	if loc == nil {
+		return nil, errors.New("Cannot parse price info")
+	}
+	number := loc[2:4]
+	if number[0] < 0 {
+		number = loc[4:6]
+	}
+	priceAt := number[0]
+	price, err := decimal.Parse(text[number[0]:number[1]])
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse price %q: %w", text[number[0]:number[1]], err)
+	}
+	info.Price = price
+	info.Provenance[FieldPrice] = spanOf(text, loc[0], loc[1])
+
+	if loc := sentenceDatePattern.FindStringIndex(text); loc != nil {
+		if date, err := normalizeDate(strings.Replace(strings.Replace(text[loc[0]:loc[1]], ".", "", 1), "Sept ", "Sep ", 1), time.Now()); err == nil {
+			info.Date = date
+			info.Provenance[FieldDate] = spanOf(text, loc[0], loc[1])
+		}
+	}
+
+	// Assign each period to the mention with the strongest evidence, the first one on ties
+	claimed := make(map[string]*changeMention)
+	for _, sentence := range splitSentences(text) {
+		for _, mention := range findChanges(text, sentence[0], sentence[1]) {
+			// "rose to 71.35 EUR, up 1.2%" without a period is the daily change
+			if mention.strength < 0 && sentence[0] <= priceAt && priceAt < sentence[1] {
+				mention.field, mention.strength = FieldDailyChange, 0
+			}
+			if mention.field == "" {
+				continue
+			}
+			if current, ok := claimed[mention.field]; !ok || mention.strength > current.strength {
+				claimed[mention.field] = &mention
+			}
+		}
+	}
+	for field, mention := range claimed {
+		switch field {
+		case FieldDailyChange:
+			info.DailyChange = mention.value
+		case FieldMonthlyChange:
+			info.MonthlyChange = mention.value
+		case FieldYearlyChange:
+			info.YearlyChange = mention.value
+		}
+		info.Provenance[field] = mention.span
+	}
+	info.Missing = missingFields(info.Provenance)
+	return info, nil
+}
+
+// splitSentences byte ranges of the sentences of text
+func splitSentences(text string) [][2]int {
+	var sentences [][2]int
+	start := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(text, -1) {
+		// "Jan. 15" is not a sentence end: the next sentence starts with a letter
+		if loc[1] < len(text) && !isLetter(text[loc[1]]) {
+			continue
+		}
+		sentences = append(sentences, [2]int{start, loc[0]})
+		start = loc[1]
+	}
+	if start < len(text) {
+		sentences = append(sentences, [2]int{start, len(text)})
+	}
+	return sentences
+}
+
+// isLetter ASCII letter
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// findChanges changes between from and to with their direction and period
+func findChanges(text string, from, to int) []changeMention {
+	sentence := text[from:to]
+	var mentions []changeMention
+	for _, loc := range changePattern.FindAllStringSubmatchIndex(sentence, -1) {
+		value, err := decimal.Parse(sentence[loc[4]:loc[5]])
+		if err != nil {
+			continue
+		}
+		word := ""
+		if loc[2] >= 0 {
+			word = strings.ToLower(sentence[loc[2]:loc[3]])
+		} else if loc[6] >= 0 {
+			word = strings.ToLower(sentence[loc[6]:loc[7]])
+		}
+		switch {
+		case word != "":
+			value = value.Abs()
+			if !risingSet[word] {
+				value = value.Neg()
+			}
+		case !strings.ContainsAny(sentence[loc[4]:loc[5]], "+-"):
+			// A bare percentage is a share, not a change
+			continue
+		}
+		mentions = append(mentions, changeMention{value: value, start: from + loc[0], end: from + loc[1]})
+	}
+	for _, loc := range unchangedPattern.FindAllStringIndex(sentence, -1) {
+		mentions = append(mentions, changeMention{start: from + loc[0], end: from + loc[1]})
+	}
+	sort.Slice(mentions, func(i, j int) bool { return mentions[i].start < mentions[j].start })
+
+	for i := range mentions {
+		m := &mentions[i]
+		// A mention may look for its period up to its neighbours, preferring its own clause
+		lower, upper := from, to
+		if i > 0 {
+			lower = mentions[i-1].end
+		}
+		if i+1 < len(mentions) {
+			upper = mentions[i+1].start
+		}
+		clauseStart, clauseEnd := clauseBounds(text, lower, m.start, m.end, upper)
+
+		searches := []struct {
+			from, to, strength int
+			after              bool
+		}{
+			{m.end, clauseEnd, 2, true},
+			{clauseStart, m.start, 2, false},
+			{lower, m.start, 1, false},
+			{m.end, upper, 1, true},
+		}
+		m.span = spanOf(text, m.start, m.end)
+		m.strength = -1
+		for _, s := range searches {
+			field, start, end, ok := findPeriod(text, s.from, s.to, s.after)
+			if !ok {
+				continue
+			}
+			m.field, m.strength = field, s.strength
+			if s.after {
+				m.span = spanOf(text, m.start, end)
+			} else {
+				m.span = spanOf(text, start, m.end)
+			}
+			break
+		}
+	}
+	return mentions
+}
+
+// clauseBounds the clause around [start, end), limited to [lower, upper)
+func clauseBounds(text string, lower, start, end, upper int) (int, int) {
+	clauseStart, clauseEnd := lower, upper
+	for _, loc := range clauseBoundary.FindAllStringIndex(text[lower:start], -1) {
+		clauseStart = lower + loc[1]
+	}
+	if loc := clauseBoundary.FindStringIndex(text[end:upper]); loc != nil {
+		clauseEnd = end + loc[0]
+	}
+	return clauseStart, clauseEnd
+}
+
+// findPeriod period phrase in text[from:to], the first one after a change or the last one before it.
+// Longer phrases win at the same position, so "year-to-date" is not read as "year" and "four weeks"
+// not as "weeks".
+func findPeriod(text string, from, to int, after bool) (string, int, int, bool) {
+	if from >= to {
+		return "", 0, 0, false
+	}
+	found := false
+	var field string
+	var start, end int
+	for _, period := range periodPatterns {
+		for _, loc := range period.pattern.FindAllStringIndex(text[from:to], -1) {
+			s, e := from+loc[0], from+loc[1]
+			better := !found ||
+				(after && (s < start || s == start && e > end)) ||
+				(!after && (e > end || e == end && s < start))
+			if better {
+				found, field, start, end = true, period.field, s, e
+			}
+		}
+	}
+	return field, start, end, found
+}
+
+// spanOf span of text[start:end]
+func spanOf(text string, start, end int) types.Span {
+	return types.Span{Text: strings.TrimSpace(text[start:end]), Start: start, End: end}
+}
+
+// missingFields fields without provenance
+func missingFields(provenance map[string]types.Span) []string {
+	var missing []string
+	for _, field := range priceFields {
+		if _, ok := provenance[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,299 @@
+package crawler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"backend/pkg/decimal"
+)
+
+// TestParseSentence corpus of description phrasings, each change must keep its own direction and period
+func TestParseSentence(t *testing.T) {
+	noChanges := []string{FieldDailyChange, FieldMonthlyChange, FieldYearlyChange}
+	tests := []struct {
+		name                   string
+		input                  string
+		price                  float64
+		date                   string
+		daily, monthly, yearly float64
+		missing                []string
+	}{
+		{
+			name: "TradingEconomics rise",
+			input: "EU Carbon Permits rose to 71.35 EUR on January 15, 2024, up 1.20% from the previous day. " +
+				"Over the past month, EU Carbon Permits's price has fallen 4.10%, and is down 15.30% compared to the same time last year, " +
+				"according to trading on a contract for difference (CFD) that tracks the benchmark market for this commodity.",
+			price: 71.35, date: "January 15, 2024", daily: 1.2, monthly: -4.1, yearly: -15.3,
+		},
+		{
+			name: "TradingEconomics fall",
+			input: "EU Carbon Permits fell to 64.80 EUR on February 20, 2024, down 2.35% from the previous day. " +
+				"Over the past month, EU Carbon Permits's price has risen 3.10%, but it is still 25.40% lower than a year ago, " +
+				"according to trading on a contract for difference (CFD) that tracks the benchmark market for this commodity.",
+			price: 64.80, date: "February 20, 2024", daily: -2.35, monthly: 3.1, yearly: -25.4,
+		},
+		{
+			name: "One down does not flip the others",
+			input: "EU Carbon Permits increased to 85.23 EUR on January 15, 2024, up 2.5% from yesterday. " +
+				"The price has risen 5.2% this month and is down 8.5% compared to the same time last year.",
+			price: 85.23, date: "January 15, 2024", daily: 2.5, monthly: 5.2, yearly: -8.5,
+		},
+		{
+			name: "Only a fall in the daily clause",
+			input: "EU Carbon Permits decreased to 82.15 EUR on January 15, 2024, down 3.2% from yesterday. " +
+				"The price has risen 4.1% this month and is up 8.5% compared to the same time last year.",
+			price: 82.15, date: "January 15, 2024", daily: -3.2, monthly: 4.1, yearly: 8.5,
+		},
+		{
+			name:  "Yearly clause is not the daily change",
+			input: "EU Carbon Permits traded at 70.00 EUR on March 1, 2024. Prices are up 12.0% compared to the same time last year.",
+			price: 70, date: "March 1, 2024", yearly: 12,
+			missing: []string{FieldDailyChange, FieldMonthlyChange},
+		},
+		{
+			name:  "Unchanged",
+			input: "EU Carbon Permits was unchanged at 68.40 EUR on April 2, 2024 from the previous day.",
+			price: 68.40, date: "April 2, 2024",
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Flat on the day",
+			input: "EU Carbon Permits were flat on the day at 68.40 EUR on April 3, 2024.",
+			price: 68.40, date: "April 3, 2024",
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Qualified change",
+			input: "EU Carbon Permits climbed to 90.12 EUR on February 5, 2023, up by 0.75% from the previous session.",
+			price: 90.12, date: "February 5, 2023", daily: 0.75,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Noun form",
+			input: "The EUA December contract settled at 72.10 EUR on May 3, 2024, a 1.8% gain on the day, and a 6.2% drop over the past month.",
+			price: 72.10, date: "May 3, 2024", daily: 1.8, monthly: -6.2,
+			missing: []string{FieldYearlyChange},
+		},
+		{
+			name:  "Signed numbers",
+			input: "EU Carbon Permits: 65.20 EUR (May 6, 2024), daily change -0.95%, monthly change +2.10%, yearly change -18.75%.",
+			price: 65.20, date: "May 6, 2024", daily: -0.95, monthly: 2.1, yearly: -18.75,
+		},
+		{
+			name:  "Euro sign and compact periods",
+			input: "Carbon permits closed at €80.45 on June 12, 2024, down 0.4% on the day, up 3.3% month-on-month and down 9.9% year-on-year.",
+			price: 80.45, date: "June 12, 2024", daily: -0.4, monthly: 3.3, yearly: -9.9,
+		},
+		{
+			name:  "Abbreviations",
+			input: "EUA price 2024-10-01: 63.20 EUR, +0.30% d/d, -1.50% m/m, -12.00% y/y.",
+			price: 63.20, date: "October 1, 2024", daily: 0.3, monthly: -1.5, yearly: -12,
+		},
+		{
+			name:  "Weekly change ignored",
+			input: "EU Carbon Permits rose to 75.00 EUR on July 1, 2024, up 0.5% from the previous day and 4.2% higher over the week.",
+			price: 75, date: "July 1, 2024", daily: 0.5,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Year to date is not yearly",
+			input: "EUAs fell to 60.10 EUR on August 8, 2024, down 1.1% from yesterday, and have lost 12.5% since the start of the year.",
+			price: 60.10, date: "August 8, 2024", daily: -1.1,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "YTD and year on year",
+			input: "EU Carbon Permits stood at 66.00 EUR on May 10, 2024, 3.0% lower year-to-date and 20.1% lower year-on-year.",
+			price: 66, date: "May 10, 2024", yearly: -20.1,
+			missing: []string{FieldDailyChange, FieldMonthlyChange},
+		},
+		{
+			name:  "Twelve months",
+			input: "EU Carbon Permits decreased to 55.55 EUR on September 9, 2024. The price has declined 30.2% over the past 12 months.",
+			price: 55.55, date: "September 9, 2024", yearly: -30.2,
+			missing: []string{FieldDailyChange, FieldMonthlyChange},
+		},
+		{
+			name:  "Four weeks",
+			input: "EU Carbon Permits rose to 70.20 EUR on October 7, 2024, having gained 5.5% over the past four weeks.",
+			price: 70.20, date: "October 7, 2024", monthly: 5.5,
+			missing: []string{FieldDailyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Period before the change",
+			input: "EU Carbon Permits traded at 71.00 EUR on January 10, 2024. Over the past month EU Carbon Permits's price has fallen 4.10%.",
+			price: 71, date: "January 10, 2024", monthly: -4.1,
+			missing: []string{FieldDailyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Abbreviated month with a stop",
+			input: "EU Carbon Permits rose to 71.35 EUR on Jan. 15, 2024, up 1.2% from the previous day.",
+			price: 71.35, date: "January 15, 2024", daily: 1.2,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Day first date and whole price",
+			input: "EU Carbon Permits are trading at 70 EUR per tonne on 15 March 2024, 2% higher than the previous session.",
+			price: 70, date: "March 15, 2024", daily: 2,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:    "Price only",
+			input:   "EU Carbon Permits at 70.50 EUR.",
+			price:   70.50,
+			missing: append([]string{FieldDate}, noChanges...),
+		},
+		{
+			name:  "Price change amount before the level",
+			input: "EU Carbon Permits rose 1.20 EUR to 72.40 EUR on May 20, 2024, up 1.69% from the previous day.",
+			price: 72.40, date: "May 20, 2024", daily: 1.69,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Change without period in the price sentence",
+			input: "EU Carbon Permits rose to 71.00 EUR on June 3, 2024, up 0.8%.",
+			price: 71, date: "June 3, 2024", daily: 0.8,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Upper case",
+			input: "EU CARBON PERMITS FELL TO 66.00 EUR ON JUNE 4, 2024, DOWN 1.5% FROM THE PREVIOUS DAY.",
+			price: 66, date: "June 4, 2024", daily: -1.5,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Single session surge",
+			input: "EU Carbon Permits surged 7.5% in a single session to 78.00 EUR on March 12, 2024.",
+			price: 78, date: "March 12, 2024", daily: 7.5,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Last 24 hours",
+			input: "EU Carbon Permits lost 2.1% over the last 24 hours, trading at 69.30 EUR on March 13, 2024.",
+			price: 69.30, date: "March 13, 2024", daily: -2.1,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Year earlier",
+			input: "At 58.90 EUR on November 4, 2024, EU Carbon Permits are 22% lower compared with a year earlier.",
+			price: 58.90, date: "November 4, 2024", yearly: -22,
+			missing: []string{FieldDailyChange, FieldMonthlyChange},
+		},
+		{
+			name:  "Prior trading day",
+			input: "EU Carbon Permits eased to 67.75 EUR on December 2, 2024, down 0.45% from the prior trading day.",
+			price: 67.75, date: "December 2, 2024", daily: -0.45,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name: "Yearly clause first",
+			input: "Compared to the same time last year, EU Carbon Permits are down 15.3%, " +
+				"and they rose 0.9% from the previous day to 71.10 EUR on January 16, 2024.",
+			price: 71.10, date: "January 16, 2024", daily: 0.9, yearly: -15.3,
+			missing: []string{FieldMonthlyChange},
+		},
+		{
+			name:  "Space before percent",
+			input: "EU Carbon Permits rose to 71.35 EUR on January 15, 2024, up 1.2 % from the previous day.",
+			price: 71.35, date: "January 15, 2024", daily: 1.2,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Decline of",
+			input: "EU Carbon Permits slipped to 69.00 EUR on April 8, 2024, a decline of 2.3% from the previous day.",
+			price: 69, date: "April 8, 2024", daily: -2.3,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Share is not a change",
+			input: "EU Carbon Permits rose to 71.35 EUR on January 15, 2024, up 1.2% from the previous day; 85% of allowances are auctioned.",
+			price: 71.35, date: "January 15, 2024", daily: 1.2,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Increase this month",
+			input: "EU Carbon Permits reached 74.00 EUR on May 31, 2024, an increase of 4% this month.",
+			price: 74, date: "May 31, 2024", monthly: 4,
+			missing: []string{FieldDailyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Today without date",
+			input: "EU Carbon Permits are up 0.6% today at 70.90 EUR.",
+			price: 70.90, daily: 0.6,
+			missing: []string{FieldDate, FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Annual decline",
+			input: "EU Carbon Permits ended at 61.00 EUR on December 31, 2024, an annual decline of 11%.",
+			price: 61, date: "December 31, 2024", yearly: -11,
+			missing: []string{FieldDailyChange, FieldMonthlyChange},
+		},
+		{
+			name: "Rebound after monthly fall",
+			input: "EU Carbon Permits rebounded 3.4% from the previous day to 63.00 EUR on February 23, 2024, " +
+				"though they have tumbled 9.8% over the past month and are 33% lower year-on-year.",
+			price: 63, date: "February 23, 2024", daily: 3.4, monthly: -9.8, yearly: -33,
+		},
+		{
+			name:  "Euro word",
+			input: "EU carbon allowances closed at 68 euros on September 2, 2024, up 1% from the previous session.",
+			price: 68, date: "September 2, 2024", daily: 1,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+		{
+			name:  "Sept abbreviation",
+			input: "EU Carbon Permits rose to 65.40 EUR on Sept 3, 2024, up 0.2% from the previous day.",
+			price: 65.40, date: "September 3, 2024", daily: 0.2,
+			missing: []string{FieldMonthlyChange, FieldYearlyChange},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parseSentence(tt.input)
+			if err != nil {
+				t.Fatalf("Parsing failed: %v", err)
+			}
+			if info.Price != decimal.FromFloat(tt.price) || info.Date != tt.date {
+				t.Errorf("Expected %.2f on %q, got %.2f on %q", tt.price, tt.date, info.Price, info.Date)
+			}
+			if info.DailyChange != decimal.FromFloat(tt.daily) || info.MonthlyChange != decimal.FromFloat(tt.monthly) || info.YearlyChange != decimal.FromFloat(tt.yearly) {
+				t.Errorf("Changes mismatch: expected %.2f/%.2f/%.2f, got %.2f/%.2f/%.2f",
+					tt.daily, tt.monthly, tt.yearly, info.DailyChange, info.MonthlyChange, info.YearlyChange)
+			}
+			if !reflect.DeepEqual(info.Missing, tt.missing) {
+				t.Errorf("Missing mismatch: expected %v, got %v", tt.missing, info.Missing)
+			}
+			for field, span := range info.Provenance {
+				if strings.TrimSpace(tt.input[span.Start:span.End]) != span.Text {
+					t.Errorf("%s span %d-%d does not match %q", field, span.Start, span.End, span.Text)
+				}
+			}
+		})
+	}
+
+	if _, err := parseSentence("EU Carbon Permits data is temporarily unavailable"); err == nil {
+		t.Error("Expected error without a price")
+	}
+}
+
+// TestParseSentenceProvenance test the text recorded for each field
+func TestParseSentenceProvenance(t *testing.T) {
+	info, err := parseSentence("EU Carbon Permits rose to 71.35 EUR on January 15, 2024, up 1.20% from the previous day. " +
+		"Over the past month, EU Carbon Permits's price has fallen 4.10%, and is down 15.30% compared to the same time last year.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		FieldPrice:         "to 71.35 EUR",
+		FieldDate:          "January 15, 2024",
+		FieldDailyChange:   "up 1.20% from the previous day",
+		FieldMonthlyChange: "month, EU Carbon Permits's price has fallen 4.10%",
+		FieldYearlyChange:  "down 15.30% compared to the same time last year",
+	}
+	for field, text := range want {
+		if got := info.Provenance[field].Text; got != text {
+			t.Errorf("%s provenance mismatch: expected %q, got %q", field, text, got)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Session states reported through SessionStatus
+const (
+	SessionAnonymous   = "anonymous"    // No credentials configured, public pages only
+	SessionUnknown     = "unknown"      // Credentials configured but not used yet
+	SessionValid       = "valid"        // Last fetch was served with the session
+	SessionExpired     = "expired"      // Upstream asked for a login and no re-login was possible
+	SessionLoginFailed = "login_failed" // Re-login was attempted and rejected
+)
+
+// Session errors, wrapped with details so callers can match them with errors.Is
+var (
+	ErrSessionExpired = errors.New("Upstream session expired")
+	ErrLoginFailed    = errors.New("Upstream login failed")
+)
+
+// Credentials upstream account, every field is optional
+type Credentials struct {
+	Cookie   string `json:"cookie"`   // Cookie header of an existing browser session, e.g. "ASP.NET_SessionId=...; .ASPXAUTH=..."
+	Username string `json:"username"` // Login form user, used to log in again when the session expires
+	Password string `json:"password"`
+}
+
+// Empty whether no credential is set
+func (c Credentials) Empty() bool {
+	return c.Cookie == "" && c.Username == "" && c.Password == ""
+}
+
+// CredentialsProvider returns the current credentials. It is called again before every
+// re-login so rotated secrets are picked up without a restart.
+type CredentialsProvider func() (Credentials, error)
+
+// LoadCredentialsFile read credentials from a JSON file {"cookie": ..., "username": ..., "password": ...}
+func LoadCredentialsFile(path string) (Credentials, error) {
+	var creds Credentials
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return creds, err
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return creds, fmt.Errorf("Invalid credentials file %s: %w", path, err)
+	}
+	return creds, nil
+}
+
+// SessionStatus session health reported on /health
+type SessionStatus struct {
+	State       string     `json:"state"`
+	LastChecked *time.Time `json:"lastChecked,omitempty"` // Last fetch that revealed the session state
+	LastLogin   *time.Time `json:"lastLogin,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// SessionReporter implemented by sources that keep an upstream session
+type SessionReporter interface {
+	SessionStatus() SessionStatus
+}
+
+// Session cookie jar shared by the fetches of one source, seeded from the configured cookie
+// and refreshed by logging in again with username and password when the upstream asks for it
+type Session struct {
+	mu          sync.Mutex
+	loginURL    string
+	credentials CredentialsProvider
+	jar         http.CookieJar
+	cookie      string // Cookie header the jar was last seeded with
+	status      SessionStatus
+}
+
+// NewSession create session, credentials may be nil for anonymous access
+func NewSession(loginURL string, credentials CredentialsProvider) *Session {
+	jar, _ := cookiejar.New(nil)
+	s := &Session{
+		loginURL:    loginURL,
+		credentials: credentials,
+		jar:         jar,
+		status:      SessionStatus{State: SessionAnonymous},
+	}
+	if creds, err := s.current(); err == nil && !creds.Empty() {
+		s.status.State = SessionUnknown
+	}
+	return s
+}
+
+// current credentials, empty without a provider
+func (s *Session) current() (Credentials, error) {
+	if s.credentials == nil {
+		return Credentials{}, nil
+	}
+	return s.credentials()
+}
+
+// Jar cookie jar to attach to collectors
+func (s *Session) Jar() http.CookieJar {
+	return s.jar
+}
+
+// Status current session status
+func (s *Session) Status() SessionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Prepare seed the jar with the configured cookie before the first request to target
+func (s *Session) Prepare(target string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cookie != "" {
+		return nil
+	}
+	creds, err := s.current()
+	if err != nil {
+		return fmt.Errorf("Cannot load upstream credentials: %w", err)
+	}
+	return s.seed(target, creds.Cookie)
+}
+
+// seed store the cookies of a Cookie header for target, caller holds the lock
+func (s *Session) seed(target, cookie string) error {
+	if cookie == "" {
+		return nil
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	cookies := (&http.Request{Header: http.Header{"Cookie": {cookie}}}).Cookies()
+	for _, c := range cookies {
+		// Site-wide like the browser session they come from, so a re-login replaces them
+		c.Path = "/"
+	}
+	s.jar.SetCookies(u, cookies)
+	s.cookie = cookie
+	return nil
+}
+
+// MarkValid record a fetch served with a working session
+func (s *Session) MarkValid() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	s.status.LastChecked = &now
+	s.status.Error = ""
+	if s.status.State != SessionAnonymous {
+		s.status.State = SessionValid
+	}
+}
+
+// MarkExpired record a session the upstream still rejects
+func (s *Session) MarkExpired(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mark(SessionExpired, err)
+}
+
+// mark record a failed session state, caller holds the lock
+func (s *Session) mark(state string, err error) {
+	now := time.Now().UTC()
+	s.status.State = state
+	s.status.LastChecked = &now
+	s.status.Error = err.Error()
+}
+
+// Refresh restore the session after target answered with a login page. A rotated cookie is
+// seeded directly, otherwise the login form is posted with username and password. The returned
+// error wraps ErrSessionExpired or ErrLoginFailed.
+func (s *Session) Refresh(ctx context.Context, target, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expired := fmt.Errorf("%w: %s", ErrSessionExpired, reason)
+	creds, err := s.current()
+	if err != nil {
+		err = fmt.Errorf("%w, cannot load credentials: %v", expired, err)
+		s.mark(SessionExpired, err)
+		return err
+	}
+
+	// A new cookie was configured since the jar was seeded
+	if creds.Cookie != "" && creds.Cookie != s.cookie {
+		if err := s.seed(target, creds.Cookie); err != nil {
+			s.mark(SessionExpired, err)
+			return err
+		}
+		return nil
+	}
+
+	if creds.Username == "" || creds.Password == "" || s.loginURL == "" {
+		err := fmt.Errorf("%w, no username and password to log in again", expired)
+		s.mark(SessionExpired, err)
+		return err
+	}
+
+	if err := s.login(ctx, creds); err != nil {
+		err = fmt.Errorf("%w: %v", ErrLoginFailed, err)
+		s.mark(SessionLoginFailed, err)
+		return err
+	}
+	now := time.Now().UTC()
+	s.status.LastLogin = &now
+	return nil
+}
+
+// login post the login form, caller holds the lock
+func (s *Session) login(ctx context.Context, creds Credentials) error {
+	fmt.Printf("Logging in to %s as %s...\n", s.loginURL, creds.Username) // Debug log
+	collector := colly.NewCollector(colly.UserAgent(userAgent), colly.StdlibContext(ctx))
+	collector.AllowURLRevisit = true
+	collector.WithTransport(sharedTransport{})
+	collector.SetCookieJar(s.jar)
+
+	var loginPage bool
+	var finalURL string
+	collector.OnHTML("input[type=password]", func(e *colly.HTMLElement) {
+		loginPage = true
+	})
+	collector.OnResponse(func(r *colly.Response) {
+		finalURL = r.Request.URL.String()
+	})
+
+	err := collector.Post(s.loginURL, map[string]string{
+		"email":    creds.Username,
+		"username": creds.Username,
+		"password": creds.Password,
+	})
+	if err != nil {
+		return err
+	}
+	if loginPage {
+		return fmt.Errorf("login form shown again at %s, check username and password", finalURL)
+	}
+	return nil
+}
+
+// isLoginURL whether a URL points at a login or sign-in page
+func isLoginURL(u *url.URL) bool {
+	path := strings.ToLower(u.Path)
+	return strings.Contains(path, "login") || strings.Contains(path, "signin") || strings.Contains(path, "sign-in")
+}
@@ -0,0 +1,108 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/pkg/decimal"
+)
+
+// newSessionServer page that requires the "auth=ok" cookie and redirects to a login form without it
+func newSessionServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/commodity/carbon", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("auth"); err != nil || cookie.Value != "ok" {
+			http.Redirect(w, r, "/user/login", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, `<html><head><meta name="description" content="EU Carbon Permits rose to 71.35 EUR on January 15, 2024, up 1.2% from the previous day."></head></html>`)
+	})
+	mux.HandleFunc("/user/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.FormValue("email") == "user@example.com" && r.FormValue("password") == "secret" {
+			http.SetCookie(w, &http.Cookie{Name: "auth", Value: "ok", Path: "/"})
+			fmt.Fprint(w, `<html><body>Welcome</body></html>`)
+			return
+		}
+		fmt.Fprint(w, `<html><body><form method="post"><input name="email"><input type="password" name="password"></form></body></html>`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestSessionRefresh test login detection, re-login and session status
+func TestSessionRefresh(t *testing.T) {
+	server := newSessionServer(t)
+
+	tests := []struct {
+		name    string
+		creds   *Credentials
+		wantErr error
+		state   string
+	}{
+		{name: "Anonymous", creds: nil, wantErr: ErrSessionExpired, state: SessionExpired},
+		{name: "Valid cookie", creds: &Credentials{Cookie: "auth=ok; theme=dark"}, state: SessionValid},
+		{name: "Expired cookie without password", creds: &Credentials{Cookie: "auth=revoked"}, wantErr: ErrSessionExpired, state: SessionExpired},
+		{name: "Re-login", creds: &Credentials{Cookie: "auth=revoked", Username: "user@example.com", Password: "secret"}, state: SessionValid},
+		{name: "Wrong password", creds: &Credentials{Username: "user@example.com", Password: "wrong"}, wantErr: ErrLoginFailed, state: SessionLoginFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var provider CredentialsProvider
+			if tt.creds != nil {
+				creds := *tt.creds
+				provider = func() (Credentials, error) { return creds, nil }
+			}
+			crawler := &CarbonCrawler{
+				url:     server.URL + "/commodity/carbon",
+				session: NewSession(server.URL+"/user/login", provider),
+			}
+
+			priceInfo, err := crawler.FetchPrice(context.Background())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("FetchPrice failed: %v", err)
+			} else if priceInfo.Price != decimal.MustParse("71.35") {
+				t.Errorf("Price mismatch: expected 71.35, got %.2f", priceInfo.Price)
+			}
+
+			status := crawler.SessionStatus()
+			if status.State != tt.state {
+				t.Errorf("State mismatch: expected %s, got %+v", tt.state, status)
+			}
+			if tt.wantErr != nil && status.Error == "" {
+				t.Error("Failed session should report its error")
+			}
+		})
+	}
+}
+
+// TestSessionRotatedCookie test that a cookie changed after startup is used without a login
+func TestSessionRotatedCookie(t *testing.T) {
+	server := newSessionServer(t)
+	creds := Credentials{Cookie: "auth=old"}
+	crawler := &CarbonCrawler{
+		url:     server.URL + "/commodity/carbon",
+		session: NewSession("", func() (Credentials, error) { return creds, nil }),
+	}
+
+	if _, err := crawler.FetchPrice(context.Background()); !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("Expected expired session, got %v", err)
+	}
+	creds.Cookie = "auth=ok"
+	if _, err := crawler.FetchPrice(context.Background()); err != nil {
+		t.Fatalf("Rotated cookie not picked up: %v", err)
+	}
+	if state := crawler.SessionStatus().State; state != SessionValid {
+		t.Errorf("State mismatch: expected valid, got %s", state)
+	}
+}
@@ -0,0 +1,160 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+// EEXSpotURL EEX environmental spot market page
+const EEXSpotURL = "https://www.eex.com/en/market-data/environmental-markets/spot-market"
+
+// SettlementConfig settlement table source configuration, works for EEX and ICE style result tables
+type SettlementConfig struct {
+	Name             string // Source name, defaults to "eex"
+	URL              string // Page with the settlement table
+	RowSelector      string // CSS selector of table rows, defaults to "table tr"
+	Product          string // Text identifying the product row, e.g. "EUA"
+	DateColumn       int    // Zero based column index of the trading date
+	SettlementColumn int    // Zero based column index of the settlement price
+	DateLayout       string // Go time layout of the date column, defaults to "2006-01-02"
+}
+
+// SettlementSource scrapes the settlement price of a product row from an exchange results table
+type SettlementSource struct {
+	pageRecorder
+	config SettlementConfig
+}
+
+// NewSettlementSource create new settlement table source
+func NewSettlementSource(config SettlementConfig) *SettlementSource {
+	if config.Name == "" {
+		config.Name = "eex"
+	}
+	if config.URL == "" {
+		config.URL = EEXSpotURL
+	}
+	if config.RowSelector == "" {
+		config.RowSelector = "table tr"
+	}
+	if config.Product == "" {
+		config.Product = "EUA"
+	}
+	if config.DateLayout == "" {
+		config.DateLayout = "2006-01-02"
+	}
+	return &SettlementSource{config: config}
+}
+
+// Name source name
+func (s *SettlementSource) Name() string {
+	return s.config.Name
+}
+
+// FetchPrice get settlement price of the configured product
+func (s *SettlementSource) FetchPrice(ctx context.Context) (*types.PriceInfo, error) {
+	fmt.Printf("Fetching settlement price from %s...\n", s.config.URL) // Debug log
+	var priceInfo *types.PriceInfo
+	var err error
+
+	collector := colly.NewCollector(
+		colly.UserAgent(userAgent),
+		colly.StdlibContext(ctx),
+	)
+	collector.WithTransport(sharedTransport{})
+	collector.OnResponse(func(r *colly.Response) {
+		s.archivePage(s.Name(), r.Request.URL.String(), r.StatusCode, *r.Headers, r.Body)
+		priceInfo, err = s.Reparse(r.Body)
+	})
+	var status int
+	collector.OnError(func(r *colly.Response, _ error) {
+		status = r.StatusCode
+		if r.StatusCode > 0 {
+			s.archivePage(s.Name(), r.Request.URL.String(), r.StatusCode, *r.Headers, r.Body)
+		}
+	})
+
+	if visitErr := collector.Visit(s.config.URL); visitErr != nil {
+		return nil, visitError(s.Name(), s.config.URL, status, visitErr)
+	}
+	if err != nil {
+		return nil, withURL(err, s.config.URL)
+	}
+	return priceInfo, nil
+}
+
+// Reparse find the product row in a settlement page
+func (s *SettlementSource) Reparse(body []byte) (*types.PriceInfo, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, NewFetchError(KindParseFailed, s.Name(), "", err)
+	}
+	var cells []string
+	doc.Find(s.config.RowSelector).EachWithBreak(func(_ int, row *goquery.Selection) bool {
+		if !strings.Contains(row.Text(), s.config.Product) {
+			return true
+		}
+		row.Find("td").Each(func(_ int, td *goquery.Selection) {
+			cells = append(cells, strings.TrimSpace(td.Text()))
+		})
+		return false
+	})
+	if cells == nil {
+		return nil, NewFetchError(KindSelectorNotFound, s.Name(), "",
+			fmt.Errorf("No settlement row found for %s", s.config.Product))
+	}
+	priceInfo, err := s.parseRow(cells)
+	if err != nil {
+		return nil, NewFetchError(KindParseFailed, s.Name(), "", err)
+	}
+	return priceInfo, nil
+}
+
+// parseRow build price info from the cells of a settlement row
+func (s *SettlementSource) parseRow(cells []string) (*types.PriceInfo, error) {
+	if s.config.DateColumn >= len(cells) || s.config.SettlementColumn >= len(cells) {
+		return nil, fmt.Errorf("Settlement row has %d cells, expected date column %d and price column %d",
+			len(cells), s.config.DateColumn, s.config.SettlementColumn)
+	}
+
+	price, err := parseNumber(cells[s.config.SettlementColumn])
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse settlement price %q: %w", cells[s.config.SettlementColumn], err)
+	}
+	date, err := time.Parse(s.config.DateLayout, cells[s.config.DateColumn])
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse settlement date %q: %w", cells[s.config.DateColumn], err)
+	}
+
+	return &types.PriceInfo{
+		Price:       price,
+		Date:        date.Format(DateLayout),
+		LastUpdated: fetchTime(),
+		Source:      s.Name(),
+		Missing:     []string{FieldDailyChange, FieldMonthlyChange, FieldYearlyChange},
+	}, nil
+}
+
+// parseNumber parse a decimal number, accepting both "68.45" and the European "68,45"
+func parseNumber(text string) (decimal.Decimal, error) {
+	text = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "%"))
+	text = strings.TrimPrefix(text, "€")
+	if text == "" {
+		return decimal.Zero, errors.New("empty number")
+	}
+	if strings.Contains(text, ",") && !strings.Contains(text, ".") {
+		text = strings.ReplaceAll(text, ",", ".")
+	} else {
+		text = strings.ReplaceAll(text, ",", "")
+	}
+	return decimal.Parse(text)
+}
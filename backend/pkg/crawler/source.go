@@ -0,0 +1,261 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"backend/pkg/types"
+)
+
+// DateLayout layout used for the Date field of every source, matches the TradingEconomics wording
+const DateLayout = types.DateLayout
+
+// fetchTime LastUpdated of a quote fetched now, at the second precision every storage backend keeps
+func fetchTime() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// PriceSource defines a carbon price provider.
+// FetchPrice must set Source to Name() and LastUpdated to the time the quote was fetched,
+// and give up as soon as ctx is done.
+type PriceSource interface {
+	Name() string
+	FetchPrice(ctx context.Context) (*types.PriceInfo, error)
+}
+
+// Registry ordered collection of price sources, earlier sources have higher priority.
+// Every source has a retry policy and a circuit breaker.
+type Registry struct {
+	mu       sync.RWMutex
+	sources  []PriceSource
+	policies map[string]RetryPolicy
+	breakers map[string]*Breaker
+	errors   *ErrorLog
+}
+
+// NewRegistry create new source registry
+func NewRegistry(sources ...PriceSource) *Registry {
+	r := &Registry{
+		policies: make(map[string]RetryPolicy),
+		breakers: make(map[string]*Breaker),
+		errors:   NewErrorLog(200),
+	}
+	for _, s := range sources {
+		r.Register(s)
+	}
+	return r
+}
+
+// Register append a source, replacing any source already registered under the same name
+func (r *Registry) Register(source PriceSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.breakers[source.Name()]; !ok {
+		r.breakers[source.Name()] = NewBreaker(source.Name(), DefaultBreakerConfig())
+	}
+	for i, s := range r.sources {
+		if s.Name() == source.Name() {
+			r.sources[i] = source
+			return
+		}
+	}
+	r.sources = append(r.sources, source)
+}
+
+// Unregister remove a source with its retry policy and circuit breaker
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, s := range r.sources {
+		if s.Name() == name {
+			r.sources = append(r.sources[:i], r.sources[i+1:]...)
+			break
+		}
+	}
+	delete(r.policies, name)
+	delete(r.breakers, name)
+}
+
+// Get get source by name, nil if not registered
+func (r *Registry) Get(name string) PriceSource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.sources {
+		if s.Name() == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// SetPolicy set the retry policy of a source
+func (r *Registry) SetPolicy(name string, policy RetryPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.policies[name] = policy
+}
+
+// Policy retry policy of a source, DefaultRetryPolicy unless set
+func (r *Registry) Policy(name string) RetryPolicy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if policy, ok := r.policies[name]; ok {
+		return policy
+	}
+	return DefaultRetryPolicy()
+}
+
+// SetBreakerConfig replace the circuit breaker of a source with a closed one using config
+func (r *Registry) SetBreakerConfig(name string, config BreakerConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breakers[name] = NewBreaker(name, config)
+}
+
+// Breaker circuit breaker of a source, nil if not registered
+func (r *Registry) Breaker(name string) *Breaker {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.breakers[name]
+}
+
+// BreakerStats breaker snapshots in priority order
+func (r *Registry) BreakerStats() []BreakerStats {
+	var stats []BreakerStats
+	for _, name := range r.Names() {
+		if breaker := r.Breaker(name); breaker != nil {
+			stats = append(stats, breaker.Stats())
+		}
+	}
+	return stats
+}
+
+// Errors error counters and history of all fetches
+func (r *Registry) Errors() *ErrorLog {
+	return r.errors
+}
+
+// SetArchiver archive the responses of every source that supports it
+func (r *Registry) SetArchiver(archiver PageArchiver) {
+	for _, source := range r.Sources() {
+		if archivable, ok := source.(Archivable); ok {
+			archivable.SetArchiver(archiver)
+		}
+	}
+}
+
+// Sources get a snapshot of registered sources in priority order
+func (r *Registry) Sources() []PriceSource {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sources := make([]PriceSource, len(r.sources))
+	copy(sources, r.sources)
+	return sources
+}
+
+// Names get registered source names in priority order
+func (r *Registry) Names() []string {
+	sources := r.Sources()
+	names := make([]string, len(sources))
+	for i, s := range sources {
+		names[i] = s.Name()
+	}
+	return names
+}
+
+// validateQuote sanity check of a fetched price
+func validateQuote(source string, priceInfo *types.PriceInfo) error {
+	if priceInfo == nil {
+		return NewFetchError(KindSelectorNotFound, source, "", errors.New("Source returned no price"))
+	}
+	if priceInfo.Price.Sign() <= 0 {
+		return NewFetchError(KindValidation, source, "", fmt.Errorf("Price %v is not a positive number", priceInfo.Price))
+	}
+	return nil
+}
+
+// FetchResult outcome of fetching a single source
+type FetchResult struct {
+	Source    string
+	PriceInfo *types.PriceInfo
+	Err       error
+	Attempts  int
+	StartedAt time.Time
+	FetchedAt time.Time
+}
+
+// FetchAll fetch up to want healthy sources (0 for all) concurrently under their retry policies.
+// Sources with an open breaker are skipped, and every failed source is replaced by the next healthy
+// one in priority order until want quotes are collected or no source is left. Results are in priority order.
+func (r *Registry) FetchAll(ctx context.Context, want int) []FetchResult {
+	sources := r.Sources()
+	if want <= 0 || want > len(sources) {
+		want = len(sources)
+	}
+
+	var results []FetchResult
+	next, succeeded := 0, 0
+	for succeeded < want && ctx.Err() == nil {
+		var batch []PriceSource
+		for ; next < len(sources) && len(batch) < want-succeeded; next++ {
+			if breaker := r.Breaker(sources[next].Name()); breaker != nil && !breaker.Allow() {
+				fmt.Printf("Skipping %s, circuit breaker open\n", sources[next].Name()) // Debug log
+				continue
+			}
+			batch = append(batch, sources[next])
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, result := range r.fetchBatch(ctx, batch) {
+			if result.Err != nil {
+				r.errors.Record(NewErrorRecord(result.Source, result.Err, result.Attempts))
+			}
+			if breaker := r.Breaker(result.Source); breaker != nil {
+				breaker.Record(result.Err)
+			}
+			if result.Err == nil {
+				succeeded++
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// fetchBatch fetch sources concurrently, results keep the order of sources
+func (r *Registry) fetchBatch(ctx context.Context, sources []PriceSource) []FetchResult {
+	results := make([]FetchResult, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source PriceSource) {
+			defer wg.Done()
+			startedAt := time.Now()
+			priceInfo, attempts, err := FetchWithRetry(ctx, source, r.Policy(source.Name()))
+			if err == nil {
+				err = validateQuote(source.Name(), priceInfo)
+				if err != nil {
+					priceInfo = nil
+				}
+			}
+			results[i] = FetchResult{
+				Source:    source.Name(),
+				PriceInfo: priceInfo,
+				Err:       err,
+				Attempts:  attempts,
+				StartedAt: startedAt,
+				FetchedAt: time.Now(),
+			}
+		}(i, source)
+	}
+	wg.Wait()
+	return results
+}
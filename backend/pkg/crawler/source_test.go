@@ -0,0 +1,140 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"backend/pkg/archive"
+	"backend/pkg/decimal"
+)
+
+// TestJSONSource test price extraction from a JSON API
+func TestJSONSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"close":"71.35","day":"2024-01-15"}]}`)
+	}))
+	defer server.Close()
+
+	source := NewJSONSource(JSONConfig{
+		URL:        server.URL,
+		Headers:    map[string]string{"X-Api-Key": "secret"},
+		PriceField: "data.0.close",
+		DateField:  "data.0.day",
+		DateLayout: "2006-01-02",
+	})
+	priceInfo, err := source.FetchPrice(context.Background())
+	if err != nil {
+		t.Fatalf("FetchPrice failed: %v", err)
+	}
+	if priceInfo.Price != decimal.MustParse("71.35") {
+		t.Errorf("Price mismatch: expected 71.35, got %.2f", priceInfo.Price)
+	}
+	if priceInfo.Date != "January 15, 2024" {
+		t.Errorf("Date mismatch: expected January 15, 2024, got %s", priceInfo.Date)
+	}
+	if priceInfo.Source != "jsonapi" || priceInfo.LastUpdated.IsZero() {
+		t.Errorf("Missing source metadata: %+v", priceInfo)
+	}
+}
+
+// TestSettlementSource test settlement row extraction
+func TestSettlementSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><table>
+			<tr><th>Date</th><th>Product</th><th>Settlement</th></tr>
+			<tr><td>15.01.2024</td><td>EUA Spot</td><td>70,12</td></tr>
+		</table></body></html>`)
+	}))
+	defer server.Close()
+
+	source := NewSettlementSource(SettlementConfig{
+		Name:             "ice",
+		URL:              server.URL,
+		DateColumn:       0,
+		SettlementColumn: 2,
+		DateLayout:       "02.01.2006",
+	})
+	priceInfo, err := source.FetchPrice(context.Background())
+	if err != nil {
+		t.Fatalf("FetchPrice failed: %v", err)
+	}
+	if priceInfo.Price != decimal.MustParse("70.12") || priceInfo.Date != "January 15, 2024" || priceInfo.Source != "ice" {
+		t.Errorf("Unexpected price info: %+v", priceInfo)
+	}
+}
+
+// TestRegistry test registration order, replacement and removal
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry(NewCarbonCrawler(), NewJSONSource(JSONConfig{}))
+	registry.Register(NewJSONSource(JSONConfig{URL: "http://example.com"}))
+
+	names := registry.Names()
+	if len(names) != 2 || names[0] != "tradingeconomics" || names[1] != "jsonapi" {
+		t.Errorf("Unexpected source order: %v", names)
+	}
+	if registry.Get("missing") != nil {
+		t.Error("Expected nil for unknown source")
+	}
+
+	registry.Unregister("tradingeconomics")
+	if names := registry.Names(); len(names) != 1 || names[0] != "jsonapi" || registry.Breaker("tradingeconomics") != nil {
+		t.Errorf("Unregister left %v", names)
+	}
+}
+
+// TestArchiveReparse test that fetched responses are archived and parse again to the same price
+func TestArchiveReparse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json" {
+			fmt.Fprint(w, `{"close":"71.35"}`)
+			return
+		}
+		fmt.Fprint(w, `<table><tr><td>15.01.2024</td><td>EUA Spot</td><td>70,12</td></tr></table>`)
+	}))
+	defer server.Close()
+
+	pages, err := archive.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := NewRegistry(
+		NewJSONSource(JSONConfig{URL: server.URL + "/json", PriceField: "close"}),
+		NewSettlementSource(SettlementConfig{Name: "ice", URL: server.URL + "/html", SettlementColumn: 2, DateLayout: "02.01.2006"}),
+	)
+	registry.SetArchiver(pages)
+	for _, source := range registry.Sources() {
+		if _, err := source.FetchPrice(context.Background()); err != nil {
+			t.Fatalf("%s fetch failed: %v", source.Name(), err)
+		}
+	}
+
+	list, err := pages.List(archive.Filter{})
+	if err != nil || len(list) != 2 {
+		t.Fatalf("Expected 2 archived pages, got %d (%v)", len(list), err)
+	}
+	want := map[string]decimal.Decimal{"jsonapi": decimal.MustParse("71.35"), "ice": decimal.MustParse("70.12")}
+	for _, page := range list {
+		if page.Status != 200 || page.URL == "" {
+			t.Errorf("Page metadata mismatch: %+v", page)
+		}
+		_, body, err := pages.Load(page.ID)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		priceInfo, err := registry.Get(page.Source).(Reparser).Reparse(body)
+		if err != nil || priceInfo.Price != want[page.Source] {
+			t.Errorf("%s reparse mismatch: %+v, %v", page.Source, priceInfo, err)
+		}
+	}
+
+	if _, err := registry.Get("ice").(Reparser).Reparse([]byte("<p>maintenance</p>")); Classify(err) != KindSelectorNotFound {
+		t.Errorf("Expected selector_not_found for a page without the row, got %v", err)
+	}
+}
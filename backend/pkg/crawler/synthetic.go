@@ -0,0 +1,220 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// Synthetic price models
+const (
+	ModelGBM           = "gbm"            // Geometric Brownian motion with drift
+	ModelMeanReverting = "mean-reverting" // Log price pulled back towards Mean (exponential Ornstein-Uhlenbeck)
+)
+
+// SyntheticConfig synthetic price source configuration
+type SyntheticConfig struct {
+	Name       string        // Source name, defaults to "synthetic"
+	Model      string        // ModelGBM (default) or ModelMeanReverting
+	Initial    float64       // Price at Start, defaults to 70
+	Drift      float64       // Annual drift of the gbm model, e.g. 0.05 for +5% a year
+	Volatility float64       // Annual volatility, e.g. 0.4 for 40%
+	Mean       float64       // Long run price of the mean-reverting model, defaults to Initial
+	Reversion  float64       // Mean reversion speed per year, defaults to 2
+	Seed       int64         // The same seed, start and tick always give the same path
+	Tick       time.Duration // Time between two price moves, defaults to 5 minutes
+	Start      time.Time     // Beginning of the path, defaults to 2024-01-01 UTC so changes have a year of history
+}
+
+// SyntheticSource generates a random but reproducible price path instead of scraping,
+// for demos and staging. Every price it returns is marked Synthetic. Like the exchange it only
+// moves on business days: on Saturday and Sunday it serves Friday's close, dated Friday.
+type SyntheticSource struct {
+	config SyntheticConfig
+	now    func() time.Time
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	tick   int64     // Ticks simulated so far
+	price  float64   // Price at tick
+	closes []float64 // Last price of every business day since Start
+}
+
+// NewSyntheticSource create new synthetic source
+func NewSyntheticSource(config SyntheticConfig) (*SyntheticSource, error) {
+	if config.Name == "" {
+		config.Name = "synthetic"
+	}
+	if config.Model == "" {
+		config.Model = ModelGBM
+	}
+	if config.Initial == 0 {
+		config.Initial = 70
+	}
+	if config.Mean == 0 {
+		config.Mean = config.Initial
+	}
+	if config.Reversion == 0 {
+		config.Reversion = 2
+	}
+	if config.Tick == 0 {
+		config.Tick = 5 * time.Minute
+	}
+	if config.Start.IsZero() {
+		config.Start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	switch {
+	case config.Model != ModelGBM && config.Model != ModelMeanReverting:
+		return nil, fmt.Errorf("Unknown synthetic model %q, use %s or %s", config.Model, ModelGBM, ModelMeanReverting)
+	case config.Initial < 0 || config.Mean < 0:
+		return nil, fmt.Errorf("Synthetic prices must be positive")
+	case config.Volatility < 0 || config.Reversion < 0:
+		return nil, fmt.Errorf("Synthetic volatility and reversion cannot be negative")
+	case config.Tick < time.Second:
+		return nil, fmt.Errorf("Synthetic tick %s is shorter than a second", config.Tick)
+	}
+	return &SyntheticSource{
+		config: config,
+		now:    time.Now,
+		rng:    rand.New(rand.NewSource(config.Seed)),
+		price:  config.Initial,
+		closes: []float64{config.Initial},
+	}, nil
+}
+
+// Name source name
+func (s *SyntheticSource) Name() string {
+	return s.config.Name
+}
+
+// Tick time between two price moves
+func (s *SyntheticSource) Tick() time.Duration {
+	return s.config.Tick
+}
+
+// FetchPrice price at the current tick, with changes against the close of the previous business
+// day, 30 days and 365 days ago. Changes before Start are reported missing.
+func (s *SyntheticSource) FetchPrice(ctx context.Context) (*types.PriceInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, NewFetchError(KindCancelled, s.Name(), "", err)
+	}
+	now := s.now().UTC()
+	if now.Before(s.config.Start) {
+		return nil, NewFetchError(KindValidation, s.Name(), "", fmt.Errorf("Synthetic path starts at %s", s.config.Start.Format(time.RFC3339)))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance(int64(s.businessTime(now) / s.config.Tick))
+
+	// On a weekend the ticks may already have reached Monday's first close
+	tradingDay := lastBusinessDay(now)
+	day := min(len(s.closes)-1, s.closeIndex(tradingDay))
+	price := s.price
+	if day < len(s.closes)-1 {
+		price = s.closes[day]
+	}
+	priceInfo := &types.PriceInfo{
+		Price:       round2(price),
+		Date:        tradingDay.Format(DateLayout),
+		LastUpdated: now.Truncate(time.Second),
+		Source:      s.Name(),
+		Extractor:   "synthetic",
+		Synthetic:   true,
+	}
+	changes := []struct {
+		field string
+		close int // Index in closes
+		value *decimal.Decimal
+	}{
+		{FieldDailyChange, day - 1, &priceInfo.DailyChange},
+		{FieldMonthlyChange, s.closeIndex(tradingDay.AddDate(0, 0, -30)), &priceInfo.MonthlyChange},
+		{FieldYearlyChange, s.closeIndex(tradingDay.AddDate(0, 0, -365)), &priceInfo.YearlyChange},
+	}
+	for _, change := range changes {
+		if change.close < 0 {
+			priceInfo.Missing = append(priceInfo.Missing, change.field)
+			continue
+		}
+		*change.value = round2((price/s.closes[change.close] - 1) * 100)
+	}
+	return priceInfo, nil
+}
+
+// businessTime time between Start and t without Saturdays and Sundays, the time the price moves in
+func (s *SyntheticSource) businessTime(t time.Time) time.Duration {
+	const day = 24 * time.Hour
+	if !t.After(s.config.Start) {
+		return 0
+	}
+	// Whole weeks hold five business days, then the days left are walked one by one
+	weeks := int(t.Sub(s.config.Start) / (7 * day))
+	elapsed := time.Duration(weeks) * 5 * day
+	for from := s.config.Start.AddDate(0, 0, 7*weeks); from.Before(t); {
+		to := from.Truncate(day).Add(day)
+		if t.Before(to) {
+			to = t
+		}
+		if !isWeekend(from) {
+			elapsed += to.Sub(from)
+		}
+		from = to
+	}
+	return elapsed
+}
+
+// closeIndex index in closes of the last business day on or before date, -1 before Start
+func (s *SyntheticSource) closeIndex(date time.Time) int {
+	if date.Before(s.config.Start.Truncate(24 * time.Hour)) {
+		return -1
+	}
+	endOfDay := lastBusinessDay(date).Truncate(24 * time.Hour).Add(24*time.Hour - time.Nanosecond)
+	return int(s.businessTime(endOfDay) / (24 * time.Hour))
+}
+
+// lastBusinessDay t, or the Friday before when t falls on a weekend
+func lastBusinessDay(t time.Time) time.Time {
+	for isWeekend(t) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// isWeekend t falls on a Saturday or Sunday
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// advance simulate up to tick of business time, recording the close of every business day passed
+func (s *SyntheticSource) advance(tick int64) {
+	years := s.config.Tick.Hours() / (365 * 24)
+	sigma := s.config.Volatility
+	for s.tick < tick {
+		s.tick++
+		shock := sigma * math.Sqrt(years) * s.rng.NormFloat64()
+		if s.config.Model == ModelMeanReverting {
+			logPrice := math.Log(s.price)
+			logPrice += s.config.Reversion*(math.Log(s.config.Mean)-logPrice)*years + shock
+			s.price = math.Exp(logPrice)
+		} else {
+			s.price *= math.Exp((s.config.Drift-sigma*sigma/2)*years + shock)
+		}
+
+		day := int(time.Duration(s.tick) * s.config.Tick / (24 * time.Hour))
+		for len(s.closes) <= day {
+			s.closes = append(s.closes, s.price)
+		}
+		s.closes[day] = s.price
+	}
+}
+
+// round2 round to cents or hundredths of a percent
+func round2(value float64) decimal.Decimal {
+	return decimal.FromFloat(value).Round(2)
+}
@@ -0,0 +1,134 @@
+package crawler
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// syntheticAt synthetic source whose clock reads at
+func syntheticAt(t *testing.T, config SyntheticConfig, at time.Time) *SyntheticSource {
+	t.Helper()
+	source, err := NewSyntheticSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.now = func() time.Time { return at }
+	return source
+}
+
+// TestSyntheticSource test reproducible paths, computed changes and labelling
+func TestSyntheticSource(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := start.AddDate(1, 2, 3).Add(9 * time.Hour)
+	config := SyntheticConfig{Volatility: 0.4, Drift: 0.05, Seed: 42, Tick: time.Hour, Start: start}
+
+	first, err := syntheticAt(t, config, at).FetchPrice(context.Background())
+	if err != nil {
+		t.Fatalf("FetchPrice failed: %v", err)
+	}
+	second, _ := syntheticAt(t, config, at).FetchPrice(context.Background())
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Same seed gave different prices: %+v vs %+v", first, second)
+	}
+	config.Seed = 43
+	other, _ := syntheticAt(t, config, at).FetchPrice(context.Background())
+	if other.Price == first.Price {
+		t.Errorf("Different seeds gave the same price %.2f", first.Price)
+	}
+
+	if !first.Synthetic || first.Source != "synthetic" || first.Extractor != "synthetic" {
+		t.Errorf("Price not labelled synthetic: %+v", first)
+	}
+	if first.Date != at.Format(DateLayout) || first.Missing != nil {
+		t.Errorf("Expected date %s and no missing fields, got %s %v", at.Format(DateLayout), first.Date, first.Missing)
+	}
+	if first.Price.Sign() <= 0 || first.DailyChange.IsZero() || first.MonthlyChange.IsZero() || first.YearlyChange.IsZero() {
+		t.Errorf("Expected a moving positive price, got %+v", first)
+	}
+
+	// Fetching step by step follows the same path as jumping to the end
+	config.Seed = 42
+	stepped := syntheticAt(t, config, at)
+	for day := at.AddDate(0, 0, -10); day.Before(at); day = day.Add(7 * time.Hour) {
+		stepped.now = func() time.Time { return day }
+		stepped.FetchPrice(context.Background())
+	}
+	stepped.now = func() time.Time { return at }
+	if priceInfo, _ := stepped.FetchPrice(context.Background()); !reflect.DeepEqual(priceInfo, first) {
+		t.Errorf("Stepped path diverged: %+v vs %+v", priceInfo, first)
+	}
+
+	// The daily change is measured against the previous day's close
+	yesterday := syntheticAt(t, config, at.Truncate(24*time.Hour).Add(-time.Second))
+	closeInfo, _ := yesterday.FetchPrice(context.Background())
+	if want := (first.Price.Float64()/closeInfo.Price.Float64() - 1) * 100; math.Abs(first.DailyChange.Float64()-want) > 0.05 {
+		t.Errorf("Daily change %.2f, expected about %.2f", first.DailyChange, want)
+	}
+
+	// Changes before the path started are missing rather than zero
+	early, _ := syntheticAt(t, config, start.AddDate(0, 0, 40)).FetchPrice(context.Background())
+	if !reflect.DeepEqual(early.Missing, []string{FieldYearlyChange}) || !early.YearlyChange.IsZero() {
+		t.Errorf("Expected only yearlyChange missing, got %v", early.Missing)
+	}
+	if _, err := syntheticAt(t, config, start.Add(-time.Hour)).FetchPrice(context.Background()); Classify(err) != KindValidation {
+		t.Errorf("Expected validation error before the start, got %v", err)
+	}
+}
+
+// TestSyntheticBusinessDays test that the path rests on weekends and weekend samples repeat Friday's close
+func TestSyntheticBusinessDays(t *testing.T) {
+	config := SyntheticConfig{Volatility: 0.4, Seed: 7, Tick: time.Hour}
+	friday := time.Date(2024, 3, 8, 23, 30, 0, 0, time.UTC)
+	closeInfo, _ := syntheticAt(t, config, friday).FetchPrice(context.Background())
+	for _, at := range []time.Time{friday.Add(12 * time.Hour), friday.Add(44 * time.Hour)} {
+		weekend, _ := syntheticAt(t, config, at).FetchPrice(context.Background())
+		if weekend.Date != "March 8, 2024" || weekend.Price != closeInfo.Price || weekend.DailyChange != closeInfo.DailyChange ||
+			weekend.MonthlyChange != closeInfo.MonthlyChange || weekend.YearlyChange != closeInfo.YearlyChange {
+			t.Errorf("%s: expected Friday's close %+v, got %+v", at.Weekday(), closeInfo, weekend)
+		}
+	}
+
+	monday, _ := syntheticAt(t, config, friday.Add(58*time.Hour)).FetchPrice(context.Background())
+	if want := (monday.Price.Float64()/closeInfo.Price.Float64() - 1) * 100; monday.Date != "March 11, 2024" || math.Abs(monday.DailyChange.Float64()-want) > 0.05 {
+		t.Errorf("Monday %s daily change %.2f, expected about %.2f against Friday", monday.Date, monday.DailyChange, want)
+	}
+	// Half an hour of Friday and nine and a half of Monday, the weekend does not move the price
+	source := syntheticAt(t, config, friday)
+	if elapsed := source.businessTime(friday.Add(58*time.Hour)) - source.businessTime(friday); elapsed != 10*time.Hour {
+		t.Errorf("Expected 10h of business time from Friday 23:30 to Monday 09:30, got %s", elapsed)
+	}
+}
+
+// TestSyntheticMeanReverting test that the mean-reverting model stays around its mean
+func TestSyntheticMeanReverting(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for seed := int64(1); seed <= 5; seed++ {
+		config := SyntheticConfig{Model: ModelMeanReverting, Initial: 150, Mean: 70, Reversion: 8,
+			Volatility: 0.2, Seed: seed, Tick: time.Hour, Start: start}
+		priceInfo, err := syntheticAt(t, config, start.AddDate(2, 0, 0)).FetchPrice(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if price := priceInfo.Price.Float64(); price < 50 || price > 100 {
+			t.Errorf("Seed %d: price %.2f did not revert towards 70", seed, priceInfo.Price)
+		}
+	}
+}
+
+// TestSyntheticConfig test configuration checks
+func TestSyntheticConfig(t *testing.T) {
+	invalid := []SyntheticConfig{
+		{Model: "random-walk"},
+		{Initial: -1},
+		{Volatility: -0.1},
+		{Tick: time.Millisecond},
+	}
+	for _, config := range invalid {
+		if _, err := NewSyntheticSource(config); err == nil {
+			t.Errorf("Expected error for %+v", config)
+		}
+	}
+}
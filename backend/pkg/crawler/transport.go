@@ -0,0 +1,34 @@
+package crawler
+
+import (
+	"net/http"
+	"sync"
+)
+
+// transport round tripper of every crawler request, see SetTransport
+var transport = struct {
+	sync.RWMutex
+	next http.RoundTripper
+}{next: http.DefaultTransport}
+
+// SetTransport send every crawler request, page fetches and logins alike, through next,
+// e.g. a cassette recorder. Nil restores http.DefaultTransport.
+func SetTransport(next http.RoundTripper) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	transport.Lock()
+	defer transport.Unlock()
+	transport.next = next
+}
+
+// sharedTransport looks the transport up per request, so sources created before SetTransport follow it
+type sharedTransport struct{}
+
+// RoundTrip send the request through the current transport
+func (sharedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport.RLock()
+	next := transport.next
+	transport.RUnlock()
+	return next.RoundTrip(req)
+}
@@ -0,0 +1,358 @@
+package decimal
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxScale most fractional digits a Decimal keeps, longer values are rounded
+const MaxScale = 18
+
+// Integer encodings consumed on chain
+const (
+	OracleDecimals = 8  // CarbonPriceOracle carbonPriceEUR and carbonPriceUSD, Chainlink feed answers
+	TokenDecimals  = 18 // CarbonToken and other ERC-20 amounts
+)
+
+// Decimal exact fixed-point number units × 10^-scale. Values are kept with the fewest fractional
+// digits, so equal numbers compare equal with ==, and the zero value is 0. Magnitudes beyond what
+// int64 units can hold lose fractional digits first and saturate at the int64 limits.
+type Decimal struct {
+	units int64
+	scale int32
+}
+
+// Zero value 0
+var Zero = Decimal{}
+
+// New units × 10^-scale, e.g. New(7135, 2) is 71.35
+func New(units int64, scale int) Decimal {
+	return fromBig(big.NewInt(units), scale)
+}
+
+// Parse read a decimal such as "71.35", "-0.29" or "+1.5e2". Thousands separators and units are
+// not stripped, that is up to the caller. More than MaxScale fractional digits are rounded.
+func Parse(text string) (Decimal, error) {
+	text = strings.TrimSpace(text)
+	mantissa, exponent := text, 0
+	if i := strings.IndexAny(text, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(text[i+1:])
+		if err != nil || exp > 100 || exp < -100 {
+			return Zero, fmt.Errorf("Invalid decimal %q", text)
+		}
+		mantissa, exponent = text[:i], exp
+	}
+	negative := strings.HasPrefix(mantissa, "-")
+	if negative || strings.HasPrefix(mantissa, "+") {
+		mantissa = mantissa[1:]
+	}
+	whole, frac, _ := strings.Cut(mantissa, ".")
+	digits := whole + frac
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return Zero, fmt.Errorf("Invalid decimal %q", text)
+	}
+	units, _ := new(big.Int).SetString(digits, 10)
+	if negative {
+		units.Neg(units)
+	}
+	scale := len(frac) - exponent
+	if scale < 0 {
+		units.Mul(units, pow10(-scale))
+		scale = 0
+	}
+	d := fromBig(units, scale)
+	if d.units == math.MaxInt64 || d.units == math.MinInt64 {
+		return Zero, fmt.Errorf("Decimal %q out of range", text)
+	}
+	return d, nil
+}
+
+// MustParse Parse that panics on invalid input, for constants and tests
+func MustParse(text string) Decimal {
+	d, err := Parse(text)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// FromFloat exact value of the shortest decimal form of f, so 0.1 is 0.1 and not the nearest
+// binary fraction. NaN and infinities become zero.
+func FromFloat(f float64) Decimal {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Zero
+	}
+	d, err := Parse(strconv.FormatFloat(f, 'g', -1, 64))
+	if err != nil {
+		return Zero
+	}
+	return d
+}
+
+// Float64 nearest float, for statistics and display only
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// Scale fractional digits of the value
+func (d Decimal) Scale() int {
+	return int(d.scale)
+}
+
+// Sign -1, 0 or +1
+func (d Decimal) Sign() int {
+	switch {
+	case d.units < 0:
+		return -1
+	case d.units > 0:
+		return 1
+	}
+	return 0
+}
+
+// IsZero value is 0
+func (d Decimal) IsZero() bool {
+	return d.units == 0
+}
+
+// Cmp -1, 0 or +1 as d is less than, equal to or greater than e
+func (d Decimal) Cmp(e Decimal) int {
+	a, b := d.aligned(e)
+	return a.Cmp(b)
+}
+
+// Add d + e
+func (d Decimal) Add(e Decimal) Decimal {
+	a, b := d.aligned(e)
+	return fromBig(a.Add(a, b), max(d.Scale(), e.Scale()))
+}
+
+// Sub d - e
+func (d Decimal) Sub(e Decimal) Decimal {
+	a, b := d.aligned(e)
+	return fromBig(a.Sub(a, b), max(d.Scale(), e.Scale()))
+}
+
+// Mul d × e
+func (d Decimal) Mul(e Decimal) Decimal {
+	units := new(big.Int).Mul(big.NewInt(d.units), big.NewInt(e.units))
+	return fromBig(units, d.Scale()+e.Scale())
+}
+
+// Div d ÷ e rounded half away from zero to scale fractional digits, zero when e is zero
+func (d Decimal) Div(e Decimal, scale int) Decimal {
+	if e.units == 0 {
+		return Zero
+	}
+	// d/e = (d.units × 10^(e.scale + scale + 1 - d.scale)) / e.units × 10^-(scale + 1), one guard digit for rounding
+	shift := e.Scale() + scale + 1 - d.Scale()
+	num, den := big.NewInt(d.units), big.NewInt(e.units)
+	if shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+	return fromBig(num.Quo(num, den), scale+1).Round(scale)
+}
+
+// Abs |d|
+func (d Decimal) Abs() Decimal {
+	if d.units < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+// Neg -d
+func (d Decimal) Neg() Decimal {
+	return fromBig(new(big.Int).Neg(big.NewInt(d.units)), d.Scale())
+}
+
+// Round to scale fractional digits, half away from zero
+func (d Decimal) Round(scale int) Decimal {
+	if scale >= d.Scale() {
+		return d
+	}
+	return fromBig(roundShift(big.NewInt(d.units), d.Scale()-scale), scale)
+}
+
+// Scaled integer d × 10^decimals as a contract stores it, rounded half away from zero,
+// e.g. 71.35 is 7135000000 at OracleDecimals and 71350000000000000000 at TokenDecimals
+func (d Decimal) Scaled(decimals int) *big.Int {
+	units := big.NewInt(d.units)
+	if decimals >= d.Scale() {
+		return units.Mul(units, pow10(decimals-d.Scale()))
+	}
+	return roundShift(units, d.Scale()-decimals)
+}
+
+// String plain decimal notation without exponent or trailing zeros, e.g. "71.35"
+func (d Decimal) String() string {
+	text := strconv.FormatInt(d.units, 10)
+	if d.scale == 0 {
+		return text
+	}
+	sign := ""
+	if d.units < 0 {
+		sign, text = "-", text[1:]
+	}
+	if len(text) <= d.Scale() {
+		text = strings.Repeat("0", d.Scale()-len(text)+1) + text
+	}
+	point := len(text) - d.Scale()
+	return sign + text[:point] + "." + text[point:]
+}
+
+// Format support the float verbs so prices can be logged with e.g. %.2f; %f with a precision
+// rounds the decimal itself, the other float verbs format Float64
+func (d Decimal) Format(f fmt.State, verb rune) {
+	precision, hasPrecision := f.Precision()
+	floatVerb := strings.ContainsRune("eEfFgG", verb)
+	var text string
+	switch {
+	case (verb == 'f' || verb == 'F') && hasPrecision:
+		text = d.Round(precision).fixed(precision)
+	case floatVerb:
+		if !hasPrecision {
+			precision = -1
+		}
+		if verb == 'F' {
+			verb = 'f'
+		}
+		text = strconv.FormatFloat(d.Float64(), byte(verb), precision, 64)
+	default:
+		text = d.String()
+	}
+	// %+v of a record asks for field names, not signs
+	if floatVerb && f.Flag('+') && d.Sign() >= 0 {
+		text = "+" + text
+	}
+	if width, ok := f.Width(); ok && len(text) < width {
+		padding := strings.Repeat(" ", width-len(text))
+		if f.Flag('-') {
+			text += padding
+		} else {
+			text = padding + text
+		}
+	}
+	fmt.Fprint(f, text)
+}
+
+// fixed String padded with zeros to exactly digits fractional digits
+func (d Decimal) fixed(digits int) string {
+	text := d.String()
+	if d.Scale() < digits {
+		if d.scale == 0 && digits > 0 {
+			text += "."
+		}
+		text += strings.Repeat("0", digits-d.Scale())
+	}
+	return text
+}
+
+// MarshalJSON encode as a JSON number with the exact digits. Most JSON parsers, JavaScript's
+// included, read numbers into a double, so a client only gets these digits exactly if its parser
+// keeps them; integrators that need exact values read priceE8 or priceE18 instead.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON decode a JSON number, a numeric string or null
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "null" || text == "" {
+		*d = Zero
+		return nil
+	}
+	parsed, err := Parse(text)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// Value store as the exact decimal text, for NUMERIC or TEXT columns
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Scan read a NUMERIC, TEXT or older float column
+func (d *Decimal) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Zero
+	case float64:
+		*d = FromFloat(v)
+	case int64:
+		*d = New(v, 0)
+	case []byte:
+		return d.UnmarshalJSON(v)
+	case string:
+		return d.UnmarshalJSON([]byte(v))
+	default:
+		return fmt.Errorf("Cannot scan %T into a decimal", src)
+	}
+	return nil
+}
+
+// aligned units of d and e at their common scale
+func (d Decimal) aligned(e Decimal) (*big.Int, *big.Int) {
+	scale := max(d.Scale(), e.Scale())
+	a := new(big.Int).Mul(big.NewInt(d.units), pow10(scale-d.Scale()))
+	b := new(big.Int).Mul(big.NewInt(e.units), pow10(scale-e.Scale()))
+	return a, b
+}
+
+// fromBig canonical Decimal of units × 10^-scale: at most MaxScale digits, trailing zeros removed,
+// fractional digits dropped (rounded) while the units do not fit int64
+func fromBig(units *big.Int, scale int) Decimal {
+	if scale < 0 {
+		units, scale = new(big.Int).Mul(units, pow10(-scale)), 0
+	}
+	if scale > MaxScale {
+		units, scale = roundShift(units, scale-MaxScale), MaxScale
+	}
+	ten, rem := big.NewInt(10), new(big.Int)
+	for scale > 0 {
+		quo, r := new(big.Int).QuoRem(units, ten, rem)
+		if r.Sign() != 0 {
+			break
+		}
+		units, scale = quo, scale-1
+	}
+	for !units.IsInt64() && scale > 0 {
+		units, scale = roundShift(units, 1), scale-1
+	}
+	if !units.IsInt64() {
+		if units.Sign() < 0 {
+			return Decimal{units: math.MinInt64}
+		}
+		return Decimal{units: math.MaxInt64}
+	}
+	return Decimal{units: units.Int64(), scale: int32(scale)}
+}
+
+// roundShift units ÷ 10^digits rounded half away from zero
+func roundShift(units *big.Int, digits int) *big.Int {
+	quo, rem := new(big.Int).QuoRem(units, pow10(digits), new(big.Int))
+	half := new(big.Int).Mul(rem.Abs(rem), big.NewInt(2))
+	if half.Cmp(pow10(digits)) >= 0 {
+		if units.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	return quo
+}
+
+// pow10 10^n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,128 @@
+package decimal
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// TestParse test parsing and canonical form
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text  string
+		want  string
+		scale int
+		err   bool
+	}{
+		{text: "71.35", want: "71.35", scale: 2},
+		{text: "71.350", want: "71.35", scale: 2},
+		{text: " -0.29 ", want: "-0.29", scale: 2},
+		{text: "+5", want: "5", scale: 0},
+		{text: ".5", want: "0.5", scale: 1},
+		{text: "1.5e2", want: "150", scale: 0},
+		{text: "1e-05", want: "0.00001", scale: 5},
+		{text: "0.1234567890123456789", want: "0.123456789012345679", scale: 18},
+		{text: "", err: true},
+		{text: "1.2.3", err: true},
+		{text: "1,234.5", err: true},
+		{text: "--1", err: true},
+		{text: "99999999999999999999", err: true},
+	}
+	for _, tt := range tests {
+		d, err := Parse(tt.text)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Parse(%q) = %s, expected an error", tt.text, d)
+			}
+			continue
+		}
+		if err != nil || d.String() != tt.want || d.Scale() != tt.scale {
+			t.Errorf("Parse(%q) = %s scale %d (%v), expected %s scale %d", tt.text, d, d.Scale(), err, tt.want, tt.scale)
+		}
+	}
+	if MustParse("71.350") != MustParse("71.35") {
+		t.Error("Equal values do not compare equal")
+	}
+}
+
+// TestArithmetic test exact arithmetic and rounding
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		{name: "add", got: MustParse("0.1").Add(MustParse("0.2")), want: "0.3"},
+		{name: "sub", got: MustParse("85.23").Sub(MustParse("85.2")), want: "0.03"},
+		{name: "mul", got: MustParse("85.23").Mul(MustParse("1.08")), want: "92.0484"},
+		{name: "div", got: MustParse("2").Div(MustParse("3"), 4), want: "0.6667"},
+		{name: "div negative", got: MustParse("-1").Div(MustParse("8"), 2), want: "-0.13"},
+		{name: "div by zero", got: MustParse("1").Div(Zero, 2), want: "0"},
+		{name: "median of two", got: MustParse("85.23").Add(MustParse("85.24")).Div(New(2, 0), MaxScale), want: "85.235"},
+		{name: "round half up", got: MustParse("1.005").Round(2), want: "1.01"},
+		{name: "round half away from zero", got: MustParse("-1.005").Round(2), want: "-1.01"},
+		{name: "round down", got: MustParse("1.004").Round(2), want: "1"},
+		{name: "abs", got: MustParse("-0.29").Abs(), want: "0.29"},
+		{name: "from exponent float", got: FromFloat(1e-7), want: "0.0000001"},
+		{name: "from short float", got: FromFloat(85.23), want: "85.23"},
+	}
+	for _, tt := range tests {
+		if tt.got.String() != tt.want {
+			t.Errorf("%s = %s, expected %s", tt.name, tt.got, tt.want)
+		}
+	}
+	if MustParse("9.5").Cmp(MustParse("10")) != -1 || MustParse("10.0").Cmp(New(10, 0)) != 0 {
+		t.Error("Cmp does not order values")
+	}
+}
+
+// TestScaled test integer encodings against what CarbonPriceOracle and CarbonToken store
+func TestScaled(t *testing.T) {
+	tests := []struct {
+		price    string
+		decimals int
+		want     string
+	}{
+		{price: "85.23", decimals: OracleDecimals, want: "8523000000"},
+		{price: "85.23", decimals: TokenDecimals, want: "85230000000000000000"},
+		{price: "0.1", decimals: OracleDecimals, want: "10000000"},
+		{price: "70.12345678", decimals: OracleDecimals, want: "7012345678"},
+		{price: "70.123456785", decimals: OracleDecimals, want: "7012345679"},
+		{price: "1.005", decimals: 2, want: "101"},
+		{price: "5", decimals: 0, want: "5"},
+	}
+	for _, tt := range tests {
+		if got := MustParse(tt.price).Scaled(tt.decimals).String(); got != tt.want {
+			t.Errorf("Scaled(%s, %d) = %s, expected %s", tt.price, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+// TestEncoding test JSON, SQL and fmt round trips
+func TestEncoding(t *testing.T) {
+	var record struct {
+		Price  Decimal `json:"price"`
+		Change Decimal `json:"change"`
+		Spread Decimal `json:"spread"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": 71.35, "change": "-0.29", "spread": null}`), &record); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	data, _ := json.Marshal(record)
+	if string(data) != `{"price":71.35,"change":-0.29,"spread":0}` {
+		t.Errorf("Marshal = %s", data)
+	}
+
+	var scanned Decimal
+	value, _ := record.Price.Value()
+	if err := scanned.Scan(value); err != nil || scanned != record.Price {
+		t.Errorf("Scan(%v) = %s (%v), expected %s", value, scanned, err, record.Price)
+	}
+
+	if got := fmt.Sprintf("%.2f|%8.3f|%-6.1f|%v|%.1f|%+.2f", record.Price, record.Change, MustParse("2.25"), record.Price, MustParse("7"), MustParse("1.2")); got != "71.35|  -0.290|2.3   |71.35|7.0|+1.20" {
+		t.Errorf("Sprintf = %q", got)
+	}
+	if got := fmt.Sprintf("%+v", record); got != "{Price:71.35 Change:-0.29 Spread:0}" {
+		t.Errorf("Sprintf = %q", got)
+	}
+}
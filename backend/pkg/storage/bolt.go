@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"backend/pkg/logger"
+	"backend/pkg/types"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	historyBucket    = []byte("history")
+	metaBucket       = []byte("meta")
+	quarantineBucket = []byte("quarantine")
+	latestKey        = []byte("latest")
+)
+
+// BoltStorage persistent storage backed by an embedded bbolt database.
+// History keys are "<trading date>/<update time>" so a cursor walks them in trading date order;
+// saves keep a single key per trading date.
+type BoltStorage struct {
+	db *bolt.DB
+}
+
+// NewBoltStorage open (or create) bolt database at path
+func NewBoltStorage(path string) (Storage, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("Cannot create storage directory: %w", err)
+		}
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot open bolt database %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{historyBucket, metaBucket, quarantineBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Cannot initialize bolt buckets: %w", err)
+	}
+	return &BoltStorage{db: db}, nil
+}
+
+// historyKey sortable key of a record
+func historyKey(priceInfo types.PriceInfo) []byte {
+	return []byte(sortKey(priceInfo))
+}
+
+// Save save price info as latest, replacing the history record of its trading date
+func (bs *BoltStorage) Save(priceInfo types.PriceInfo) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		data, err := upsertHistory(tx.Bucket(historyBucket), priceInfo, false)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(metaBucket).Put(latestKey, data)
+	})
+}
+
+// Backfill add a record to history, keeping the latest price unless it is the record replaced
+func (bs *BoltStorage) Backfill(priceInfo types.PriceInfo) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		priceInfo = priceInfo.Normalized()
+		data, err := upsertHistory(tx.Bucket(historyBucket), priceInfo, true)
+		if err != nil {
+			return err
+		}
+		meta := tx.Bucket(metaBucket)
+		var latest types.PriceInfo
+		if raw := meta.Get(latestKey); raw != nil && json.Unmarshal(raw, &latest) == nil && latest.TradingDay().Equal(priceInfo.TradingDay()) {
+			return meta.Put(latestKey, data)
+		}
+		return nil
+	})
+}
+
+// upsertHistory replace every record of the trading date with priceInfo, returns the stored JSON.
+// With keepNewer a record updated after priceInfo is kept and ErrNewerRecord returned.
+func upsertHistory(history *bolt.Bucket, priceInfo types.PriceInfo, keepNewer bool) ([]byte, error) {
+	priceInfo = priceInfo.Normalized()
+	prefix := []byte(priceInfo.TradingDay().Format("2006-01-02") + "/")
+	var stored *types.PriceInfo
+	var keys [][]byte
+	c := history.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		stored = &types.PriceInfo{}
+		if err := json.Unmarshal(v, stored); err != nil {
+			return nil, err
+		}
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	if keepNewer && stored != nil && stored.LastUpdated.After(priceInfo.LastUpdated) {
+		return nil, ErrNewerRecord
+	}
+	for _, k := range keys {
+		if err := history.Delete(k); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.Marshal(withStatus(priceInfo, stored))
+	if err != nil {
+		return nil, err
+	}
+	return data, history.Put(historyKey(priceInfo), data)
+}
+
+// Replace overwrite a record in history, and the latest price if it is that record
+func (bs *BoltStorage) Replace(old, updated types.PriceInfo) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		history := tx.Bucket(historyBucket)
+		current := history.Get(historyKey(old))
+		if current == nil {
+			return ErrRecordNotFound
+		}
+		var stored types.PriceInfo
+		if err := json.Unmarshal(current, &stored); err != nil {
+			return err
+		}
+		data, err := json.Marshal(withStatus(updated.Normalized(), &stored))
+		if err != nil {
+			return err
+		}
+		if err := history.Delete(historyKey(old)); err != nil {
+			return err
+		}
+		if err := history.Put(historyKey(updated), data); err != nil {
+			return err
+		}
+
+		meta := tx.Bucket(metaBucket)
+		var latest types.PriceInfo
+		if raw := meta.Get(latestKey); raw != nil && json.Unmarshal(raw, &latest) == nil && sortKey(latest) == sortKey(old) {
+			return meta.Put(latestKey, data)
+		}
+		return nil
+	})
+}
+
+// GetLatest get latest price info
+func (bs *BoltStorage) GetLatest() *types.PriceInfo {
+	var latest *types.PriceInfo
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(metaBucket).Get(latestKey)
+		if data == nil {
+			return nil
+		}
+		latest = &types.PriceInfo{}
+		return json.Unmarshal(data, latest)
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to read latest price: %v", err)
+		return nil
+	}
+	return latest
+}
+
+// GetHistory get full price history ordered by trading date
+func (bs *BoltStorage) GetHistory() []types.PriceInfo {
+	history := make([]types.PriceInfo, 0)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(historyBucket).ForEach(func(_, v []byte) error {
+			var priceInfo types.PriceInfo
+			if err := json.Unmarshal(v, &priceInfo); err != nil {
+				return err
+			}
+			history = append(history, priceInfo)
+			return nil
+		})
+	})
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to read price history: %v", err)
+	}
+	return history
+}
+
+// Query seek to the requested trading date range, then sample and paginate
+func (bs *BoltStorage) Query(q HistoryQuery) (*HistoryPage, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	start := ""
+	if !q.From.IsZero() {
+		start = q.From.Format("2006-01-02")
+	}
+	if after, _ := decodeCursor(q.Cursor); after > start {
+		start = after
+	}
+	end := ""
+	if !q.To.IsZero() {
+		// Every key of the last day sorts before "<day>0"
+		end = q.To.Format("2006-01-02") + "0"
+	}
+
+	records := make([]types.PriceInfo, 0)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(historyBucket).Cursor()
+		for k, v := cursor.Seek([]byte(start)); k != nil; k, v = cursor.Next() {
+			if end != "" && string(k) >= end {
+				break
+			}
+			var priceInfo types.PriceInfo
+			if err := json.Unmarshal(v, &priceInfo); err != nil {
+				return err
+			}
+			records = append(records, priceInfo)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applyQuery(records, q)
+}
+
+// Close close the database file
+func (bs *BoltStorage) Close() error {
+	return bs.db.Close()
+}
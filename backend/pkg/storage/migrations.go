@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Migration versioned schema change. Statements may use the %ID%, %REAL%, %DECIMAL%, %BOOL% and
+// %TIMESTAMP% macros, expanded per dialect, so one definition serves SQLite and PostgreSQL.
+// Prices are %DECIMAL%, %REAL% keeps only 15 significant digits.
+type Migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// migrations schema history, append only: never edit a migration that has been released
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create prices",
+		Statements: []string{
+			`CREATE TABLE prices (
+				id %ID%,
+				trading_date TEXT NOT NULL,
+				date_text TEXT NOT NULL,
+				price %REAL% NOT NULL,
+				daily_change %REAL% NOT NULL DEFAULT 0,
+				monthly_change %REAL% NOT NULL DEFAULT 0,
+				yearly_change %REAL% NOT NULL DEFAULT 0,
+				last_updated %TIMESTAMP% NOT NULL,
+				source TEXT NOT NULL DEFAULT '',
+				spread %REAL% NOT NULL DEFAULT 0,
+				spread_percent %REAL% NOT NULL DEFAULT 0,
+				disagreement %BOOL% NOT NULL DEFAULT FALSE
+			)`,
+			`CREATE INDEX idx_prices_trading_date ON prices (trading_date, last_updated)`,
+		},
+	},
+	{
+		Version: 2,
+		Name:    "create price sources",
+		Statements: []string{
+			`CREATE TABLE price_sources (
+				id %ID%,
+				price_id BIGINT NOT NULL REFERENCES prices (id) ON DELETE CASCADE,
+				source TEXT NOT NULL,
+				price %REAL% NOT NULL DEFAULT 0,
+				date_text TEXT NOT NULL DEFAULT '',
+				fetched_at %TIMESTAMP% NOT NULL,
+				error TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE INDEX idx_price_sources_price_id ON price_sources (price_id)`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "create crawl attempts",
+		Statements: []string{
+			`CREATE TABLE crawl_attempts (
+				id %ID%,
+				source TEXT NOT NULL,
+				started_at %TIMESTAMP% NOT NULL,
+				finished_at %TIMESTAMP% NOT NULL,
+				success %BOOL% NOT NULL,
+				price %REAL% NOT NULL DEFAULT 0,
+				error TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE INDEX idx_crawl_attempts_source ON crawl_attempts (source, started_at)`,
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add quote extraction",
+		Statements: []string{
+			`ALTER TABLE price_sources ADD COLUMN extractor TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE price_sources ADD COLUMN confidence TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add missing fields",
+		Statements: []string{
+			`ALTER TABLE prices ADD COLUMN missing TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add synthetic flag",
+		Statements: []string{
+			`ALTER TABLE prices ADD COLUMN synthetic %BOOL% NOT NULL DEFAULT FALSE`,
+			`ALTER TABLE price_sources ADD COLUMN synthetic %BOOL% NOT NULL DEFAULT FALSE`,
+		},
+	},
+	{
+		Version: 7,
+		Name:    "create quarantine",
+		Statements: []string{
+			`CREATE TABLE quarantine (
+				id %ID%,
+				quarantined_at %TIMESTAMP% NOT NULL,
+				status TEXT NOT NULL,
+				trading_date TEXT NOT NULL,
+				price %REAL% NOT NULL,
+				source TEXT NOT NULL DEFAULT '',
+				price_info TEXT NOT NULL,
+				violations TEXT NOT NULL
+			)`,
+			`CREATE INDEX idx_quarantine_status ON quarantine (status, id)`,
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add quarantine review",
+		Statements: []string{
+			`ALTER TABLE quarantine ADD COLUMN reviewed_by TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE quarantine ADD COLUMN reviewed_at %TIMESTAMP%`,
+			`ALTER TABLE quarantine ADD COLUMN review_comment TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version: 9,
+		Name:    "one price per trading date",
+		Statements: []string{
+			`ALTER TABLE prices ADD COLUMN status TEXT NOT NULL DEFAULT 'updated'`,
+			// Keep the last saved record of every trading date
+			`DELETE FROM price_sources WHERE price_id NOT IN (SELECT MAX(id) FROM prices GROUP BY trading_date)`,
+			`DELETE FROM prices WHERE id NOT IN (SELECT MAX(id) FROM prices GROUP BY trading_date)`,
+			`CREATE UNIQUE INDEX idx_prices_trading_day ON prices (trading_date)`,
+		},
+	},
+	{
+		Version: 10,
+		Name:    "add canonical record fields",
+		Statements: []string{
+			// Rows written before have version 1 and are upgraded with types.PriceInfo.Normalized
+			`ALTER TABLE prices ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+			`ALTER TABLE prices ADD COLUMN instrument TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE prices ADD COLUMN currency TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE prices ADD COLUMN unit TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE prices ADD COLUMN extractor TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE prices ADD COLUMN confidence TEXT NOT NULL DEFAULT ''`,
+			`ALTER TABLE prices ADD COLUMN provenance TEXT NOT NULL DEFAULT ''`,
+		},
+	},
+	{
+		Version: 11,
+		Name:    "store prices as exact decimals",
+		Statements: decimalColumns(map[string][]string{
+			"prices":         {"price", "daily_change", "monthly_change", "yearly_change", "spread", "spread_percent"},
+			"price_sources":  {"price"},
+			"crawl_attempts": {"price"},
+			"quarantine":     {"price"},
+		}),
+	},
+}
+
+// decimalColumns statements changing %REAL% columns to %DECIMAL%. SQLite cannot change a column
+// type, so each column is copied into a new one that takes its name; stored floats keep the
+// digits they were written with.
+func decimalColumns(tables map[string][]string) []string {
+	names := make([]string, 0, len(tables))
+	for table := range tables {
+		names = append(names, table)
+	}
+	sort.Strings(names)
+
+	var statements []string
+	for _, table := range names {
+		for _, column := range tables[table] {
+			statements = append(statements,
+				fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s_decimal %%DECIMAL%% NOT NULL DEFAULT 0`, table, column),
+				fmt.Sprintf(`UPDATE %s SET %s_decimal = %s`, table, column, column),
+				fmt.Sprintf(`ALTER TABLE %s DROP COLUMN %s`, table, column),
+				fmt.Sprintf(`ALTER TABLE %s RENAME COLUMN %s_decimal TO %s`, table, column, column),
+			)
+		}
+	}
+	return statements
+}
+
+// dialectMacros macro expansion per dialect
+var dialectMacros = map[string]*strings.Replacer{
+	BackendSQLite: strings.NewReplacer(
+		"%ID%", "INTEGER PRIMARY KEY AUTOINCREMENT",
+		"%REAL%", "REAL",
+		"%DECIMAL%", "TEXT", // REAL affinity would turn digits back into a float
+		"%BOOL%", "BOOLEAN",
+		"%TIMESTAMP%", "TIMESTAMP",
+	),
+	BackendPostgres: strings.NewReplacer(
+		"%ID%", "BIGSERIAL PRIMARY KEY",
+		"%REAL%", "DOUBLE PRECISION",
+		"%DECIMAL%", "NUMERIC(38,18)",
+		"%BOOL%", "BOOLEAN",
+		"%TIMESTAMP%", "TIMESTAMPTZ",
+	),
+}
+
+// MigrationStatus applied state of a migration
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Migrate apply pending migrations in version order, returns the versions applied
+func Migrate(db *sql.DB, dialect string) ([]int, error) {
+	macros, ok := dialectMacros[dialect]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported SQL dialect %q", dialect)
+	}
+	_, err := db.Exec(macros.Replace(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at %TIMESTAMP% NOT NULL
+	)`))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot create schema_migrations: %w", err)
+	}
+
+	current, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []int
+	for _, m := range migrations {
+		if _, ok := current[m.Version]; ok {
+			continue
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			return applied, err
+		}
+		for _, stmt := range m.Statements {
+			if _, err := tx.Exec(macros.Replace(stmt)); err != nil {
+				tx.Rollback()
+				return applied, fmt.Errorf("Migration %d (%s) failed: %w", m.Version, m.Name, err)
+			}
+		}
+		_, err = tx.Exec(rebind(dialect, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+			m.Version, m.Name, time.Now().UTC())
+		if err != nil {
+			tx.Rollback()
+			return applied, fmt.Errorf("Cannot record migration %d: %w", m.Version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return applied, err
+		}
+		applied = append(applied, m.Version)
+	}
+	return applied, nil
+}
+
+// MigrationsStatus list known migrations with their applied time
+func MigrationsStatus(db *sql.DB) ([]MigrationStatus, error) {
+	current, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+	status := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		status[i] = MigrationStatus{Version: m.Version, Name: m.Name}
+		if appliedAt, ok := current[m.Version]; ok {
+			status[i].AppliedAt = &appliedAt
+		}
+	}
+	return status, nil
+}
+
+// appliedVersions read schema_migrations
+func appliedVersions(db *sql.DB) (map[int]time.Time, error) {
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = appliedAt
+	}
+	return versions, rows.Err()
+}
+
+// rebind convert ? placeholders to $n for PostgreSQL
+func rebind(dialect, query string) string {
+	if dialect != BackendPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
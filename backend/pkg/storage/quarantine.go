@@ -0,0 +1,362 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"backend/pkg/types"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// newEntry fill the defaults of a quarantine entry
+func newEntry(entry types.QuarantinedPrice) types.QuarantinedPrice {
+	if entry.QuarantinedAt.IsZero() {
+		entry.QuarantinedAt = time.Now().UTC()
+	}
+	if entry.Status == "" {
+		entry.Status = types.QuarantinePending
+	}
+	return entry
+}
+
+// applyReview record a decision on entry, which must still be pending
+func applyReview(entry *types.QuarantinedPrice, status, reviewer, comment string) error {
+	if status != types.QuarantineApproved && status != types.QuarantineRejected {
+		return fmt.Errorf("Unknown review status %q", status)
+	}
+	if entry.Status != types.QuarantinePending {
+		return ErrAlreadyReviewed
+	}
+	now := time.Now().UTC()
+	entry.Status = status
+	entry.ReviewedBy = reviewer
+	entry.ReviewedAt = &now
+	entry.ReviewComment = comment
+	return nil
+}
+
+// reopen clear the decision on entry
+func reopen(entry *types.QuarantinedPrice) {
+	entry.Status = types.QuarantinePending
+	entry.ReviewedBy = ""
+	entry.ReviewedAt = nil
+	entry.ReviewComment = ""
+}
+
+// Quarantine hold a rejected price in memory
+func (ms *MemoryStorage) Quarantine(entry types.QuarantinedPrice) (types.QuarantinedPrice, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	entry = newEntry(entry)
+	ms.quarantineSeq++
+	entry.ID = ms.quarantineSeq
+	ms.quarantine = append(ms.quarantine, entry)
+	for len(ms.quarantine) > memoryQuarantineRetention {
+		i := ms.oldestReviewed()
+		ms.quarantine = append(ms.quarantine[:i], ms.quarantine[i+1:]...)
+	}
+	return entry, nil
+}
+
+// oldestReviewed position of the oldest decided entry, or of the oldest entry when all are pending; caller holds the lock
+func (ms *MemoryStorage) oldestReviewed() int {
+	for i, entry := range ms.quarantine {
+		if entry.Status != types.QuarantinePending {
+			return i
+		}
+	}
+	return 0
+}
+
+// quarantineIndex position of the entry with id; caller holds the lock
+func (ms *MemoryStorage) quarantineIndex(id int64) (int, bool) {
+	i := sort.Search(len(ms.quarantine), func(i int) bool { return ms.quarantine[i].ID >= id })
+	return i, i < len(ms.quarantine) && ms.quarantine[i].ID == id
+}
+
+// Quarantined list quarantined prices newest first
+func (ms *MemoryStorage) Quarantined(status string, limit int) ([]types.QuarantinedPrice, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	entries := make([]types.QuarantinedPrice, 0)
+	for i := len(ms.quarantine) - 1; i >= 0 && (limit <= 0 || len(entries) < limit); i-- {
+		if status == "" || ms.quarantine[i].Status == status {
+			entries = append(entries, ms.quarantine[i])
+		}
+	}
+	return entries, nil
+}
+
+// GetQuarantined quarantined price by ID
+func (ms *MemoryStorage) GetQuarantined(id int64) (types.QuarantinedPrice, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	i, ok := ms.quarantineIndex(id)
+	if !ok {
+		return types.QuarantinedPrice{}, ErrRecordNotFound
+	}
+	return ms.quarantine[i], nil
+}
+
+// Review record the decision on a quarantined price
+func (ms *MemoryStorage) Review(id int64, status, reviewer, comment string) (types.QuarantinedPrice, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	i, ok := ms.quarantineIndex(id)
+	if !ok {
+		return types.QuarantinedPrice{}, ErrRecordNotFound
+	}
+	entry := ms.quarantine[i]
+	if err := applyReview(&entry, status, reviewer, comment); err != nil {
+		return entry, err
+	}
+	ms.quarantine[i] = entry
+	return entry, nil
+}
+
+// Reopen return a quarantined price to pending
+func (ms *MemoryStorage) Reopen(id int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	i, ok := ms.quarantineIndex(id)
+	if !ok {
+		return ErrRecordNotFound
+	}
+	reopen(&ms.quarantine[i])
+	return nil
+}
+
+// quarantineKey big-endian sequence number, so a cursor walks entries in insertion order
+func quarantineKey(id int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+// Quarantine hold a rejected price in the quarantine bucket
+func (bs *BoltStorage) Quarantine(entry types.QuarantinedPrice) (types.QuarantinedPrice, error) {
+	entry = newEntry(entry)
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(quarantineBucket)
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		entry.ID = int64(id)
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(quarantineKey(entry.ID), data)
+	})
+	return entry, err
+}
+
+// Quarantined list quarantined prices newest first
+func (bs *BoltStorage) Quarantined(status string, limit int) ([]types.QuarantinedPrice, error) {
+	entries := make([]types.QuarantinedPrice, 0)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(quarantineBucket).Cursor()
+		for k, v := c.Last(); k != nil && (limit <= 0 || len(entries) < limit); k, v = c.Prev() {
+			var entry types.QuarantinedPrice
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			if status == "" || entry.Status == status {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// GetQuarantined quarantined price by ID
+func (bs *BoltStorage) GetQuarantined(id int64) (types.QuarantinedPrice, error) {
+	var entry types.QuarantinedPrice
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(quarantineBucket).Get(quarantineKey(id))
+		if data == nil {
+			return ErrRecordNotFound
+		}
+		return json.Unmarshal(data, &entry)
+	})
+	return entry, err
+}
+
+// Review record the decision on a quarantined price
+func (bs *BoltStorage) Review(id int64, status, reviewer, comment string) (types.QuarantinedPrice, error) {
+	var entry types.QuarantinedPrice
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(quarantineBucket)
+		data := bucket.Get(quarantineKey(id))
+		if data == nil {
+			return ErrRecordNotFound
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		if err := applyReview(&entry, status, reviewer, comment); err != nil {
+			return err
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(quarantineKey(id), data)
+	})
+	return entry, err
+}
+
+// Reopen return a quarantined price to pending
+func (bs *BoltStorage) Reopen(id int64) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(quarantineBucket)
+		data := bucket.Get(quarantineKey(id))
+		if data == nil {
+			return ErrRecordNotFound
+		}
+		var entry types.QuarantinedPrice
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		reopen(&entry)
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(quarantineKey(id), data)
+	})
+}
+
+// Quarantine insert a rejected price into the quarantine table
+func (ss *SQLStorage) Quarantine(entry types.QuarantinedPrice) (types.QuarantinedPrice, error) {
+	entry = newEntry(entry)
+	priceInfo, err := json.Marshal(entry.PriceInfo)
+	if err != nil {
+		return entry, err
+	}
+	violations, err := json.Marshal(entry.Violations)
+	if err != nil {
+		return entry, err
+	}
+	err = ss.db.QueryRow(ss.rebind(`INSERT INTO quarantine (quarantined_at, status, trading_date, price, source, price_info, violations)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		entry.QuarantinedAt.UTC(), entry.Status, entry.PriceInfo.TradingDay().Format("2006-01-02"),
+		entry.PriceInfo.Price, entry.PriceInfo.Source, string(priceInfo), string(violations),
+	).Scan(&entry.ID)
+	if err != nil {
+		return entry, fmt.Errorf("Cannot quarantine price: %w", err)
+	}
+	return entry, nil
+}
+
+// quarantineColumns columns read by scanQuarantined
+const quarantineColumns = `id, quarantined_at, status, price_info, violations, reviewed_by, reviewed_at, review_comment`
+
+// Quarantined list quarantined prices newest first
+func (ss *SQLStorage) Quarantined(status string, limit int) ([]types.QuarantinedPrice, error) {
+	query := `SELECT ` + quarantineColumns + ` FROM quarantine`
+	args := []interface{}{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY id DESC`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := ss.db.Query(ss.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]types.QuarantinedPrice, 0)
+	for rows.Next() {
+		entry, err := scanQuarantined(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// GetQuarantined quarantined price by ID
+func (ss *SQLStorage) GetQuarantined(id int64) (types.QuarantinedPrice, error) {
+	row := ss.db.QueryRow(ss.rebind(`SELECT `+quarantineColumns+` FROM quarantine WHERE id = ?`), id)
+	entry, err := scanQuarantined(row)
+	if err == sql.ErrNoRows {
+		return entry, ErrRecordNotFound
+	}
+	return entry, err
+}
+
+// Review record the decision on a quarantined price, only a pending entry is updated
+func (ss *SQLStorage) Review(id int64, status, reviewer, comment string) (types.QuarantinedPrice, error) {
+	entry, err := ss.GetQuarantined(id)
+	if err != nil {
+		return entry, err
+	}
+	if err := applyReview(&entry, status, reviewer, comment); err != nil {
+		return entry, err
+	}
+	result, err := ss.db.Exec(ss.rebind(`UPDATE quarantine SET status = ?, reviewed_by = ?, reviewed_at = ?, review_comment = ?
+		WHERE id = ? AND status = ?`),
+		entry.Status, entry.ReviewedBy, entry.ReviewedAt.UTC(), entry.ReviewComment, id, types.QuarantinePending)
+	if err != nil {
+		return entry, fmt.Errorf("Cannot review quarantined price: %w", err)
+	}
+	// Another reviewer decided in between
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return entry, ErrAlreadyReviewed
+	}
+	return entry, nil
+}
+
+// Reopen return a quarantined price to pending
+func (ss *SQLStorage) Reopen(id int64) error {
+	result, err := ss.db.Exec(ss.rebind(`UPDATE quarantine SET status = ?, reviewed_by = '', reviewed_at = NULL, review_comment = ''
+		WHERE id = ?`), types.QuarantinePending, id)
+	if err != nil {
+		return fmt.Errorf("Cannot reopen quarantined price: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// scanQuarantined read one quarantine row selected with quarantineColumns
+func scanQuarantined(row interface{ Scan(...interface{}) error }) (types.QuarantinedPrice, error) {
+	var entry types.QuarantinedPrice
+	var priceInfo, violations string
+	var reviewedAt sql.NullTime
+	err := row.Scan(&entry.ID, &entry.QuarantinedAt, &entry.Status, &priceInfo, &violations,
+		&entry.ReviewedBy, &reviewedAt, &entry.ReviewComment)
+	if err != nil {
+		return entry, err
+	}
+	if reviewedAt.Valid {
+		entry.ReviewedAt = &reviewedAt.Time
+	}
+	if err := json.Unmarshal([]byte(priceInfo), &entry.PriceInfo); err != nil {
+		return entry, fmt.Errorf("Corrupt quarantine entry %d: %w", entry.ID, err)
+	}
+	if err := json.Unmarshal([]byte(violations), &entry.Violations); err != nil {
+		return entry, fmt.Errorf("Corrupt quarantine entry %d: %w", entry.ID, err)
+	}
+	return entry, nil
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"backend/pkg/types"
+)
+
+// Sampling intervals for history queries
+const (
+	IntervalDaily   = "daily"
+	IntervalWeekly  = "weekly"
+	IntervalMonthly = "monthly"
+)
+
+// MaxQueryLimit upper bound of records per page
+const MaxQueryLimit = 1000
+
+// HistoryQuery filter for price history. Zero values mean unbounded.
+type HistoryQuery struct {
+	From     time.Time // First trading date, inclusive
+	To       time.Time // Last trading date, inclusive
+	Limit    int       // Max records returned, 0 for MaxQueryLimit
+	Cursor   string    // NextCursor of the previous page
+	Interval string    // Keep only the last record of each day, week or month
+}
+
+// HistoryPage one page of history in trading date order
+type HistoryPage struct {
+	Items      []types.PriceInfo `json:"items"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+}
+
+// ErrInvalidCursor cursor was not produced by a previous query
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// Validate check query parameters
+func (q HistoryQuery) Validate() error {
+	switch q.Interval {
+	case "", IntervalDaily, IntervalWeekly, IntervalMonthly:
+	default:
+		return fmt.Errorf("Unknown interval %q, expected daily, weekly or monthly", q.Interval)
+	}
+	if q.Limit < 0 || q.Limit > MaxQueryLimit {
+		return fmt.Errorf("Limit must be between 1 and %d", MaxQueryLimit)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return errors.New("To must not be before from")
+	}
+	if _, err := decodeCursor(q.Cursor); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sortKey total order of records: trading date, then update time
+func sortKey(priceInfo types.PriceInfo) string {
+	return priceInfo.TradingDay().Format("2006-01-02") + "/" + priceInfo.LastUpdated.UTC().Format(time.RFC3339Nano)
+}
+
+// encodeCursor opaque cursor pointing after the record
+func encodeCursor(priceInfo types.PriceInfo) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(sortKey(priceInfo)))
+}
+
+// decodeCursor recover the sort key from a cursor
+func decodeCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(key) < len("2006-01-02/") || key[10] != '/' {
+		return "", ErrInvalidCursor
+	}
+	return string(key), nil
+}
+
+// bucketKey sampling bucket of a record for the interval
+func bucketKey(priceInfo types.PriceInfo, interval string) string {
+	day := priceInfo.TradingDay()
+	switch interval {
+	case IntervalWeekly:
+		year, week := day.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case IntervalMonthly:
+		return day.Format("2006-01")
+	default:
+		return day.Format("2006-01-02")
+	}
+}
+
+// applyQuery run a query over records, shared by backends that filter in memory.
+// records need not be sorted; they are not modified.
+func applyQuery(records []types.PriceInfo, q HistoryQuery) (*HistoryPage, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	after, _ := decodeCursor(q.Cursor)
+
+	sorted := make([]types.PriceInfo, 0, len(records))
+	for _, p := range records {
+		day := p.TradingDay()
+		if !q.From.IsZero() && day.Before(q.From) {
+			continue
+		}
+		if !q.To.IsZero() && day.After(q.To) {
+			continue
+		}
+		if after != "" && sortKey(p) <= after {
+			continue
+		}
+		sorted = append(sorted, p)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sortKey(sorted[i]) < sortKey(sorted[j])
+	})
+
+	// Sample the last record of every bucket
+	if q.Interval != "" {
+		sampled := sorted[:0]
+		for i, p := range sorted {
+			if i+1 < len(sorted) && bucketKey(sorted[i+1], q.Interval) == bucketKey(p, q.Interval) {
+				continue
+			}
+			sampled = append(sampled, p)
+		}
+		sorted = sampled
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = MaxQueryLimit
+	}
+	page := &HistoryPage{Items: sorted}
+	if len(sorted) > limit {
+		page.Items = sorted[:limit]
+		page.NextCursor = encodeCursor(page.Items[limit-1])
+	}
+	return page, nil
+}
@@ -0,0 +1,385 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"backend/pkg/logger"
+	"backend/pkg/types"
+
+	_ "github.com/lib/pq"  // PostgreSQL driver
+	_ "modernc.org/sqlite" // Pure Go SQLite driver
+)
+
+// SQLStorage database/sql storage, SQLite for local runs and PostgreSQL in production
+type SQLStorage struct {
+	db      *sql.DB
+	dialect string
+}
+
+// OpenSQL open a database connection for the dialect without running migrations
+func OpenSQL(dialect, dsn string) (*sql.DB, error) {
+	switch dialect {
+	case BackendSQLite:
+		if dir := filepath.Dir(dsn); dsn != ":memory:" && dir != "" {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return nil, fmt.Errorf("Cannot create storage directory: %w", err)
+			}
+		}
+		if !strings.Contains(dsn, "_pragma") {
+			separator := "?"
+			if strings.Contains(dsn, "?") {
+				separator = "&"
+			}
+			dsn += separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+		}
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			return nil, err
+		}
+		// SQLite allows a single writer
+		db.SetMaxOpenConns(1)
+		return db, nil
+	case BackendPostgres:
+		return sql.Open("postgres", dsn)
+	default:
+		return nil, fmt.Errorf("Unsupported SQL dialect %q", dialect)
+	}
+}
+
+// NewSQLStorage open database and apply pending migrations
+func NewSQLStorage(dialect, dsn string) (Storage, error) {
+	db, err := OpenSQL(dialect, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Cannot connect to %s database: %w", dialect, err)
+	}
+	applied, err := Migrate(db, dialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if len(applied) > 0 {
+		logger.InfoLogger.Printf("Applied schema migrations: %v", applied)
+	}
+	return &SQLStorage{db: db, dialect: dialect}, nil
+}
+
+// Save insert price info and its source quotes, replacing the record of its trading date
+func (ss *SQLStorage) Save(priceInfo types.PriceInfo) error {
+	return ss.save(priceInfo, false)
+}
+
+// save insert price info, with keepNewer a record of the trading date updated later is kept and ErrNewerRecord returned
+func (ss *SQLStorage) save(priceInfo types.PriceInfo, keepNewer bool) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	priceInfo = priceInfo.Normalized()
+	tradingDate := priceInfo.TradingDate
+	stored, err := ss.storedFigures(tx, tradingDate)
+	if err != nil {
+		return err
+	}
+	if keepNewer && stored != nil && stored.LastUpdated.After(priceInfo.LastUpdated) {
+		return ErrNewerRecord
+	}
+	priceInfo = withStatus(priceInfo, stored)
+	if stored != nil {
+		if _, err := tx.Exec(ss.rebind(`DELETE FROM price_sources WHERE price_id IN (SELECT id FROM prices WHERE trading_date = ?)`), tradingDate); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ss.rebind(`DELETE FROM prices WHERE trading_date = ?`), tradingDate); err != nil {
+			return err
+		}
+	}
+
+	provenance, err := encodeProvenance(priceInfo.Provenance)
+	if err != nil {
+		return err
+	}
+	var priceID int64
+	err = tx.QueryRow(ss.rebind(`INSERT INTO prices (trading_date, date_text, price, daily_change, monthly_change, yearly_change,
+			last_updated, source, spread, spread_percent, disagreement, missing, synthetic, status,
+			version, instrument, currency, unit, extractor, confidence, provenance)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		tradingDate, priceInfo.Date, priceInfo.Price,
+		priceInfo.DailyChange, priceInfo.MonthlyChange, priceInfo.YearlyChange,
+		priceInfo.LastUpdated.UTC(), priceInfo.Source,
+		priceInfo.Spread, priceInfo.SpreadPercent, priceInfo.Disagreement, strings.Join(priceInfo.Missing, ","), priceInfo.Synthetic,
+		priceInfo.Status, priceInfo.Version, priceInfo.Instrument, priceInfo.Currency, priceInfo.Unit,
+		priceInfo.Extractor, priceInfo.Confidence, provenance,
+	).Scan(&priceID)
+	if err != nil {
+		return fmt.Errorf("Cannot insert price: %w", err)
+	}
+
+	if err := ss.insertQuotes(tx, priceID, priceInfo.Quotes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// storedFigures figures and update time of the stored record of a trading date, nil when there is none
+func (ss *SQLStorage) storedFigures(tx *sql.Tx, tradingDate string) (*types.PriceInfo, error) {
+	var stored types.PriceInfo
+	err := tx.QueryRow(ss.rebind(`SELECT price, daily_change, monthly_change, yearly_change, last_updated FROM prices
+		WHERE trading_date = ? ORDER BY last_updated DESC, id DESC LIMIT 1`), tradingDate).
+		Scan(&stored.Price, &stored.DailyChange, &stored.MonthlyChange, &stored.YearlyChange, &stored.LastUpdated)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// insertQuotes insert the source quotes of a price
+func (ss *SQLStorage) insertQuotes(tx *sql.Tx, priceID int64, quotes []types.SourceQuote) error {
+	for _, q := range quotes {
+		_, err := tx.Exec(ss.rebind(`INSERT INTO price_sources (price_id, source, price, date_text, fetched_at, error, extractor, confidence, synthetic)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			priceID, q.Source, q.Price, q.Date, q.FetchedAt.UTC(), q.Error, q.Extractor, q.Confidence, q.Synthetic)
+		if err != nil {
+			return fmt.Errorf("Cannot insert price source %s: %w", q.Source, err)
+		}
+	}
+	return nil
+}
+
+// Backfill insert a past price unless a later one is stored for its trading date. GetLatest orders by
+// update time, so it never becomes the latest unless it replaced the latest record.
+func (ss *SQLStorage) Backfill(priceInfo types.PriceInfo) error {
+	return ss.save(priceInfo, true)
+}
+
+// Replace overwrite a price and its source quotes
+func (ss *SQLStorage) Replace(old, updated types.PriceInfo) error {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Timestamps are matched in Go, their text form differs between drivers
+	rows, err := tx.Query(ss.rebind(`SELECT id, last_updated, price, daily_change, monthly_change, yearly_change
+		FROM prices WHERE trading_date = ?`),
+		old.TradingDay().Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	var priceID int64
+	for rows.Next() {
+		var id int64
+		var stored types.PriceInfo
+		if err := rows.Scan(&id, &stored.LastUpdated, &stored.Price, &stored.DailyChange, &stored.MonthlyChange, &stored.YearlyChange); err != nil {
+			rows.Close()
+			return err
+		}
+		if stored.LastUpdated.Equal(old.LastUpdated) {
+			priceID = id
+			updated = withStatus(updated.Normalized(), &stored)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if priceID == 0 {
+		return ErrRecordNotFound
+	}
+	provenance, err := encodeProvenance(updated.Provenance)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ss.rebind(`UPDATE prices SET trading_date = ?, date_text = ?, price = ?, daily_change = ?,
+			monthly_change = ?, yearly_change = ?, last_updated = ?, source = ?, spread = ?, spread_percent = ?, disagreement = ?,
+			missing = ?, synthetic = ?, status = ?, version = ?, instrument = ?, currency = ?, unit = ?,
+			extractor = ?, confidence = ?, provenance = ?
+		WHERE id = ?`),
+		updated.TradingDate, updated.Date, updated.Price,
+		updated.DailyChange, updated.MonthlyChange, updated.YearlyChange,
+		updated.LastUpdated.UTC(), updated.Source,
+		updated.Spread, updated.SpreadPercent, updated.Disagreement, strings.Join(updated.Missing, ","), updated.Synthetic, updated.Status,
+		updated.Version, updated.Instrument, updated.Currency, updated.Unit, updated.Extractor, updated.Confidence, provenance, priceID)
+	if err != nil {
+		return fmt.Errorf("Cannot update price: %w", err)
+	}
+	if _, err := tx.Exec(ss.rebind(`DELETE FROM price_sources WHERE price_id = ?`), priceID); err != nil {
+		return err
+	}
+	if err := ss.insertQuotes(tx, priceID, updated.Quotes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordAttempt insert a crawl attempt
+func (ss *SQLStorage) RecordAttempt(attempt types.CrawlAttempt) error {
+	_, err := ss.db.Exec(ss.rebind(`INSERT INTO crawl_attempts (source, started_at, finished_at, success, price, error)
+		VALUES (?, ?, ?, ?, ?, ?)`),
+		attempt.Source, attempt.StartedAt.UTC(), attempt.FinishedAt.UTC(), attempt.Success, attempt.Price, attempt.Error)
+	return err
+}
+
+// GetLatest get most recently updated price info, ties go to the last saved
+func (ss *SQLStorage) GetLatest() *types.PriceInfo {
+	prices, err := ss.queryPrices(`ORDER BY last_updated DESC, id DESC LIMIT 1`)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to read latest price: %v", err)
+		return nil
+	}
+	if len(prices) == 0 {
+		return nil
+	}
+	return &prices[0]
+}
+
+// GetHistory get full price history ordered by trading date
+func (ss *SQLStorage) GetHistory() []types.PriceInfo {
+	prices, err := ss.queryPrices(`ORDER BY trading_date, last_updated, id`)
+	if err != nil {
+		logger.ErrorLogger.Printf("Failed to read price history: %v", err)
+		return make([]types.PriceInfo, 0)
+	}
+	return prices
+}
+
+// Query narrow the range in SQL, then sample and paginate
+func (ss *SQLStorage) Query(q HistoryQuery) (*HistoryPage, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	var conditions []string
+	var args []interface{}
+	if !q.From.IsZero() {
+		conditions = append(conditions, "trading_date >= ?")
+		args = append(args, q.From.Format("2006-01-02"))
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "trading_date <= ?")
+		args = append(args, q.To.Format("2006-01-02"))
+	}
+	if after, _ := decodeCursor(q.Cursor); after != "" {
+		// Coarse filter on the date part, applyQuery drops the rest
+		conditions = append(conditions, "trading_date >= ?")
+		args = append(args, after[:10])
+	}
+
+	clause := ""
+	if len(conditions) > 0 {
+		clause = "WHERE " + strings.Join(conditions, " AND ") + " "
+	}
+	clause += "ORDER BY trading_date, last_updated, id"
+
+	records, err := ss.queryPrices(clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return applyQuery(records, q)
+}
+
+// Close close the database connection pool
+func (ss *SQLStorage) Close() error {
+	return ss.db.Close()
+}
+
+// queryPrices select prices with the given clause and attach their source quotes
+func (ss *SQLStorage) queryPrices(clause string, args ...interface{}) ([]types.PriceInfo, error) {
+	rows, err := ss.db.Query(ss.rebind(`SELECT id, date_text, price, daily_change, monthly_change, yearly_change,
+			last_updated, source, spread, spread_percent, disagreement, missing, synthetic, status,
+			trading_date, version, instrument, currency, unit, extractor, confidence, provenance
+		FROM prices `+clause), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := make([]types.PriceInfo, 0)
+	index := make(map[int64]int)
+	for rows.Next() {
+		var id int64
+		var p types.PriceInfo
+		var missing, provenance string
+		err := rows.Scan(&id, &p.Date, &p.Price, &p.DailyChange, &p.MonthlyChange, &p.YearlyChange,
+			&p.LastUpdated, &p.Source, &p.Spread, &p.SpreadPercent, &p.Disagreement, &missing, &p.Synthetic, &p.Status,
+			&p.TradingDate, &p.Version, &p.Instrument, &p.Currency, &p.Unit, &p.Extractor, &p.Confidence, &provenance)
+		if err != nil {
+			return nil, err
+		}
+		if provenance != "" {
+			if err := json.Unmarshal([]byte(provenance), &p.Provenance); err != nil {
+				return nil, fmt.Errorf("Corrupt provenance of price %d: %w", id, err)
+			}
+		}
+		if missing != "" {
+			p.Missing = strings.Split(missing, ",")
+		}
+		index[id] = len(prices)
+		prices = append(prices, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(prices) == 0 {
+		return prices, nil
+	}
+
+	if err := ss.attachQuotes(prices, index); err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+// attachQuotes load price_sources rows for the selected prices
+func (ss *SQLStorage) attachQuotes(prices []types.PriceInfo, index map[int64]int) error {
+	ids := make([]interface{}, 0, len(index))
+	placeholders := make([]string, 0, len(index))
+	for id := range index {
+		ids = append(ids, id)
+		placeholders = append(placeholders, "?")
+	}
+	rows, err := ss.db.Query(ss.rebind(`SELECT price_id, source, price, date_text, fetched_at, error, extractor, confidence, synthetic
+		FROM price_sources WHERE price_id IN (`+strings.Join(placeholders, ", ")+`) ORDER BY id`), ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var priceID int64
+		var q types.SourceQuote
+		if err := rows.Scan(&priceID, &q.Source, &q.Price, &q.Date, &q.FetchedAt, &q.Error, &q.Extractor, &q.Confidence, &q.Synthetic); err != nil {
+			return err
+		}
+		i := index[priceID]
+		prices[i].Quotes = append(prices[i].Quotes, q)
+	}
+	return rows.Err()
+}
+
+// encodeProvenance provenance as a JSON column, empty when there is none
+func encodeProvenance(provenance map[string]types.Span) (string, error) {
+	if len(provenance) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(provenance)
+	return string(data), err
+}
+
+// rebind convert placeholders for the dialect
+func (ss *SQLStorage) rebind(query string) string {
+	return rebind(ss.dialect, query)
+}
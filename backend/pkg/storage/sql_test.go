@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// TestSQLStorageSQLite test save, reload and migrations against SQLite
+func TestSQLStorageSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.sqlite")
+
+	store, err := New(BackendSQLite, path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	fetchedAt := time.Date(2024, 1, 17, 12, 0, 0, 0, time.UTC)
+	consensusPrice := types.PriceInfo{
+		Price:       decimal.MustParse("71.20"),
+		Date:        "January 17, 2024",
+		DailyChange: decimal.MustParse("-1.5"),
+		LastUpdated: fetchedAt,
+		Source:      "consensus",
+		Quotes: []types.SourceQuote{
+			{Source: "tradingeconomics", Price: decimal.MustParse("71.20"), Date: "January 17, 2024", FetchedAt: fetchedAt},
+			{Source: "eex", Error: "timeout", FetchedAt: fetchedAt},
+		},
+		Spread:       decimal.Zero,
+		Disagreement: true,
+	}
+	for _, p := range append(samplePrices()[:1], consensusPrice) {
+		if err := store.Save(p); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	recorder, ok := store.(AttemptRecorder)
+	if !ok {
+		t.Fatal("SQL storage should record crawl attempts")
+	}
+	err = recorder.RecordAttempt(types.CrawlAttempt{Source: "eex", StartedAt: fetchedAt, FinishedAt: fetchedAt, Error: "timeout"})
+	if err != nil {
+		t.Fatalf("RecordAttempt failed: %v", err)
+	}
+	store.Close()
+
+	// Reopen: migrations must be idempotent and data must survive
+	store, err = New(BackendSQLite, path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer store.Close()
+
+	latest := store.GetLatest()
+	if latest == nil {
+		t.Fatal("Latest price missing after reopen")
+	}
+	if latest.Price != decimal.MustParse("71.20") || latest.DailyChange != decimal.MustParse("-1.5") || !latest.Disagreement || !latest.LastUpdated.Equal(fetchedAt) {
+		t.Errorf("Latest mismatch: %+v", latest)
+	}
+	if len(latest.Quotes) != 2 || latest.Quotes[1].Error != "timeout" {
+		t.Errorf("Quotes mismatch: %+v", latest.Quotes)
+	}
+	if history := store.GetHistory(); len(history) != 2 || history[0].Date != "January 16, 2024" {
+		t.Errorf("History mismatch: %+v", history)
+	}
+
+	status, err := MigrationsStatus(store.(*SQLStorage).db)
+	if err != nil {
+		t.Fatalf("MigrationsStatus failed: %v", err)
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			t.Errorf("Migration %d not applied", s.Version)
+		}
+	}
+}
+
+// TestSQLStorageDecimals test prices beyond float precision survive storage, and float rows
+// written before migration 11 keep their value
+func TestSQLStorageDecimals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.sqlite")
+	fetchedAt := time.Date(2024, 1, 17, 12, 0, 0, 0, time.UTC)
+
+	// Schema before exact decimals
+	released := migrations
+	migrations = migrations[:10]
+	store, err := New(BackendSQLite, path)
+	migrations = released
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	old := types.PriceInfo{Price: decimal.MustParse("70.1"), Date: "January 16, 2024", DailyChange: decimal.MustParse("-0.29"),
+		LastUpdated: fetchedAt.Add(-24 * time.Hour), Source: "eex"}
+	if err := store.Save(old); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	store.Close()
+
+	store, err = New(BackendSQLite, path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer store.Close()
+	exact := decimal.MustParse("7.123456789012345678") // 19 significant digits, a float keeps 15 to 17
+	price := types.PriceInfo{
+		Price:       exact,
+		Date:        "January 17, 2024",
+		DailyChange: decimal.MustParse("-0.000000000000000001"),
+		LastUpdated: fetchedAt,
+		Source:      "consensus",
+		Quotes:      []types.SourceQuote{{Source: "eex", Price: exact, Date: "January 17, 2024", FetchedAt: fetchedAt}},
+	}
+	if err := store.Save(price); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	history := store.GetHistory()
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 prices, got %d", len(history))
+	}
+	if history[0].Price != old.Price || history[0].DailyChange != old.DailyChange {
+		t.Errorf("Migrated price mismatch: %s %s", history[0].Price, history[0].DailyChange)
+	}
+	latest := history[1]
+	if latest.Price != exact || latest.DailyChange != price.DailyChange || len(latest.Quotes) != 1 || latest.Quotes[0].Price != exact {
+		t.Errorf("Exact price mismatch: %s %s %+v", latest.Price, latest.DailyChange, latest.Quotes)
+	}
+
+	var stored string
+	if err := store.(*SQLStorage).db.QueryRow(`SELECT price FROM prices WHERE date_text = 'January 17, 2024'`).Scan(&stored); err != nil || stored != exact.String() {
+		t.Errorf("Stored column %q (%v), expected %s", stored, err, exact)
+	}
+}
+
+// TestRebind test placeholder conversion
+func TestRebind(t *testing.T) {
+	query := `SELECT * FROM prices WHERE trading_date >= ? AND trading_date <= ?`
+	if got := rebind(BackendSQLite, query); got != query {
+		t.Errorf("SQLite query should be unchanged, got %s", got)
+	}
+	if got := rebind(BackendPostgres, query); got != `SELECT * FROM prices WHERE trading_date >= $1 AND trading_date <= $2` {
+		t.Errorf("Unexpected PostgreSQL query: %s", got)
+	}
+}
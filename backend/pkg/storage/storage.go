@@ -2,21 +2,104 @@ package storage
 
 import (
 	"backend/pkg/types"
+	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"time"
 )
 
 // Storage defines storage interface
 type Storage interface {
+	// Save store priceInfo as the latest price, replacing the record of the same trading date
 	Save(priceInfo types.PriceInfo) error
 	GetLatest() *types.PriceInfo
 	GetHistory() []types.PriceInfo
+	Query(q HistoryQuery) (*HistoryPage, error)
+	Close() error
 }
 
-// MemoryStorage memory storage implementation
+// AttemptRecorder optional storage capability for recording every crawl attempt
+type AttemptRecorder interface {
+	RecordAttempt(attempt types.CrawlAttempt) error
+}
+
+// Corrector optional storage capability for repairing history, used to reparse archived pages
+type Corrector interface {
+	// Backfill add a history record without making it the latest price, replacing the record of the same trading date
+	// unless that one was updated later (ErrNewerRecord). A replaced latest record is replaced as the latest price too.
+	Backfill(priceInfo types.PriceInfo) error
+	// Replace overwrite the record with the trading date and update time of old
+	Replace(old, updated types.PriceInfo) error
+}
+
+// Quarantiner optional storage capability for holding prices rejected by validation
+type Quarantiner interface {
+	// Quarantine store a rejected price, the returned entry carries its ID
+	Quarantine(entry types.QuarantinedPrice) (types.QuarantinedPrice, error)
+	// Quarantined list entries with status newest first, status "" for any and limit 0 for all
+	Quarantined(status string, limit int) ([]types.QuarantinedPrice, error)
+	// GetQuarantined entry by ID, ErrRecordNotFound when unknown
+	GetQuarantined(id int64) (types.QuarantinedPrice, error)
+	// Review record the decision on a pending entry, ErrAlreadyReviewed once decided
+	Review(id int64, status, reviewer, comment string) (types.QuarantinedPrice, error)
+	// Reopen return a reviewed entry to pending, undoing a decision whose follow-up failed
+	Reopen(id int64) error
+}
+
+// Storage errors
+var (
+	ErrRecordNotFound  = errors.New("Price record not found")                       // Record to replace or review does not exist
+	ErrAlreadyReviewed = errors.New("Quarantined price already reviewed")           // Decision on a quarantine entry is final
+	ErrNewerRecord     = errors.New("A newer price is stored for the trading date") // Backfill would overwrite a later sample
+)
+
+// Storage backends
+const (
+	BackendMemory   = "memory"
+	BackendBolt     = "bolt"
+	BackendSQLite   = "sqlite"
+	BackendPostgres = "postgres"
+)
+
+// New create storage for the configured backend. path is a file for bolt and sqlite,
+// a connection string for postgres, and ignored by the memory backend.
+func New(backend, path string) (Storage, error) {
+	switch backend {
+	case BackendMemory, "":
+		return NewMemoryStorage(), nil
+	case BackendBolt:
+		return NewBoltStorage(path)
+	case BackendSQLite, BackendPostgres:
+		return NewSQLStorage(backend, path)
+	default:
+		return nil, fmt.Errorf("Unknown storage backend %q", backend)
+	}
+}
+
+// Memory backend retention
+const (
+	memoryRetention           = 30  // Trading days of history
+	memoryQuarantineRetention = 100 // Quarantine entries, reviewed ones are dropped first
+)
+
+// withStatus mark a record updated, or unchanged when stored holds the same figures
+func withStatus(priceInfo types.PriceInfo, stored *types.PriceInfo) types.PriceInfo {
+	priceInfo.Status = types.StatusUpdated
+	if stored != nil && stored.Price == priceInfo.Price && stored.DailyChange == priceInfo.DailyChange &&
+		stored.MonthlyChange == priceInfo.MonthlyChange && stored.YearlyChange == priceInfo.YearlyChange {
+		priceInfo.Status = types.StatusUnchanged
+	}
+	return priceInfo
+}
+
+// MemoryStorage memory storage implementation, history holds one record per trading date in date order
 type MemoryStorage struct {
-	latest  *types.PriceInfo
-	history []types.PriceInfo
-	mu      sync.RWMutex
+	latest        *types.PriceInfo
+	history       []types.PriceInfo
+	quarantine    []types.QuarantinedPrice // In ID order
+	quarantineSeq int64
+	mu            sync.RWMutex
 }
 
 // NewMemoryStorage create new memory storage instance
@@ -31,18 +114,72 @@ func (ms *MemoryStorage) Save(priceInfo types.PriceInfo) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	// Update latest price
+	priceInfo = ms.upsert(priceInfo)
 	ms.latest = &priceInfo
+	return nil
+}
+
+// Backfill add a record to history, keeping the latest price unless it is the record replaced
+func (ms *MemoryStorage) Backfill(priceInfo types.PriceInfo) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 
-	// Add to history
-	ms.history = append(ms.history, priceInfo)
+	priceInfo = priceInfo.Normalized()
+	if i, found := ms.index(priceInfo.TradingDay()); found && ms.history[i].LastUpdated.After(priceInfo.LastUpdated) {
+		return ErrNewerRecord
+	}
+	priceInfo = ms.upsert(priceInfo)
+	if ms.latest != nil && ms.latest.TradingDay().Equal(priceInfo.TradingDay()) {
+		ms.latest = &priceInfo
+	}
+	return nil
+}
 
-	// Keep only recent 30 days data
-	if len(ms.history) > 30 {
-		ms.history = ms.history[len(ms.history)-30:]
+// index position of the trading date in history, or where it would be inserted; caller holds the lock
+func (ms *MemoryStorage) index(day time.Time) (int, bool) {
+	i := sort.Search(len(ms.history), func(i int) bool {
+		return !ms.history[i].TradingDay().Before(day)
+	})
+	return i, i < len(ms.history) && ms.history[i].TradingDay().Equal(day)
+}
+
+// upsert replace the record of the trading date or insert one in date order, then keep the most
+// recent memoryRetention trading days; caller holds the lock
+func (ms *MemoryStorage) upsert(priceInfo types.PriceInfo) types.PriceInfo {
+	priceInfo = priceInfo.Normalized()
+	if i, found := ms.index(priceInfo.TradingDay()); found {
+		priceInfo = withStatus(priceInfo, &ms.history[i])
+		ms.history[i] = priceInfo
+	} else {
+		priceInfo = withStatus(priceInfo, nil)
+		ms.history = append(ms.history, types.PriceInfo{})
+		copy(ms.history[i+1:], ms.history[i:])
+		ms.history[i] = priceInfo
 	}
 
-	return nil
+	if len(ms.history) > memoryRetention {
+		ms.history = ms.history[len(ms.history)-memoryRetention:]
+	}
+	return priceInfo
+}
+
+// Replace overwrite a record in history, and the latest price if it is that record
+func (ms *MemoryStorage) Replace(old, updated types.PriceInfo) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	key := sortKey(old)
+	for i := range ms.history {
+		if sortKey(ms.history[i]) == key {
+			updated = withStatus(updated.Normalized(), &ms.history[i])
+			ms.history[i] = updated
+			if ms.latest != nil && sortKey(*ms.latest) == key {
+				ms.latest = &updated
+			}
+			return nil
+		}
+	}
+	return ErrRecordNotFound
 }
 
 // GetLatest get latest price info
@@ -56,5 +193,20 @@ func (ms *MemoryStorage) GetLatest() *types.PriceInfo {
 func (ms *MemoryStorage) GetHistory() []types.PriceInfo {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
-	return ms.history
+	// A copy, upserts rewrite the slice in place
+	history := make([]types.PriceInfo, len(ms.history))
+	copy(history, ms.history)
+	return history
+}
+
+// Query filter, sample and paginate history
+func (ms *MemoryStorage) Query(q HistoryQuery) (*HistoryPage, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return applyQuery(ms.history, q)
+}
+
+// Close nothing to release for memory storage
+func (ms *MemoryStorage) Close() error {
+	return nil
 }
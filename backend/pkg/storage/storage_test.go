@@ -0,0 +1,413 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// samplePrices three records saved out of trading date order
+func samplePrices() []types.PriceInfo {
+	base := time.Date(2024, 1, 17, 12, 0, 0, 0, time.UTC)
+	return []types.PriceInfo{
+		{Price: decimal.MustParse("70.10"), Date: "January 16, 2024", LastUpdated: base.Add(-24 * time.Hour), Source: "tradingeconomics"},
+		{Price: decimal.MustParse("71.20"), Date: "January 17, 2024", LastUpdated: base, Source: "tradingeconomics"},
+		{Price: decimal.MustParse("69.90"), Date: "January 15, 2024", LastUpdated: base.Add(-48 * time.Hour), Source: "eex"},
+	}
+}
+
+// TestBoltStorageSurvivesRestart test history persistence across reopen
+func TestBoltStorageSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.db")
+
+	store, err := New(BackendBolt, path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for _, p := range samplePrices() {
+		if err := store.Save(p); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	store, err = New(BackendBolt, path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer store.Close()
+
+	latest := store.GetLatest()
+	if latest == nil || latest.Price != decimal.MustParse("69.90") || latest.Source != "eex" {
+		t.Errorf("Latest mismatch after restart: %+v", latest)
+	}
+
+	history := store.GetHistory()
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 history records, got %d", len(history))
+	}
+	for i, expected := range []string{"January 15, 2024", "January 16, 2024", "January 17, 2024"} {
+		if history[i].Date != expected {
+			t.Errorf("History[%d] date mismatch: expected %s, got %s", i, expected, history[i].Date)
+		}
+	}
+}
+
+// eachBackend run test against a fresh store of every embedded backend
+func eachBackend(t *testing.T, test func(t *testing.T, store Storage)) {
+	t.Helper()
+	for _, backend := range []string{BackendMemory, BackendBolt, BackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			store, err := New(backend, filepath.Join(t.TempDir(), "prices."+backend))
+			if err != nil {
+				t.Fatalf("Open %s failed: %v", backend, err)
+			}
+			defer store.Close()
+			test(t, store)
+		})
+	}
+}
+
+// TestMemoryStorage test latest and history in memory
+func TestMemoryStorage(t *testing.T) {
+	store, err := New(BackendMemory, "")
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if store.GetLatest() != nil {
+		t.Error("Expected no latest price on empty storage")
+	}
+	for _, p := range samplePrices() {
+		store.Save(p)
+	}
+	if len(store.GetHistory()) != 3 || store.GetLatest().Price != decimal.MustParse("69.90") {
+		t.Errorf("Unexpected memory storage state: %+v", store.GetHistory())
+	}
+}
+
+// TestQuery test range, interval and cursor pagination on every embedded backend
+func TestQuery(t *testing.T) {
+	eachBackend(t, func(t *testing.T, store Storage) {
+		// Two scrapes per weekday over three weeks, the second replaces the first
+		for day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); day.Day() < 20; day = day.AddDate(0, 0, 1) {
+			if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+				continue
+			}
+			for _, hour := range []int{0, 12} {
+				store.Save(types.PriceInfo{
+					Price:       decimal.New(int64(day.Day()*100+hour), 2),
+					Date:        day.Format(types.DateLayout),
+					LastUpdated: day.Add(time.Duration(hour) * time.Hour),
+				})
+			}
+		}
+
+		page, err := store.Query(HistoryQuery{
+			From: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC),
+		})
+		if err != nil || len(page.Items) != 2 || page.NextCursor != "" || page.Items[0].Price != decimal.MustParse("8.12") {
+			t.Fatalf("Range query mismatch: %+v, %v", page, err)
+		}
+
+		page, err = store.Query(HistoryQuery{Interval: IntervalWeekly})
+		if err != nil || len(page.Items) != 3 {
+			t.Fatalf("Weekly query mismatch: %+v, %v", page, err)
+		}
+		if page.Items[0].Price != decimal.MustParse("5.12") {
+			t.Errorf("Weekly sample should be the last close of the week, got %.2f", page.Items[0].Price)
+		}
+
+		// Page through the daily samples three at a time
+		var prices []string
+		cursor := ""
+		for i := 0; i < 10; i++ {
+			page, err = store.Query(HistoryQuery{Interval: IntervalDaily, Limit: 3, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("Paged query failed: %v", err)
+			}
+			for _, p := range page.Items {
+				prices = append(prices, p.Price.String())
+			}
+			if cursor = page.NextCursor; cursor == "" {
+				break
+			}
+		}
+		if len(prices) != 15 || prices[0] != "1.12" || prices[14] != "19.12" {
+			t.Errorf("Paged daily samples mismatch: %v", prices)
+		}
+	})
+
+	if _, err := NewMemoryStorage().Query(HistoryQuery{Cursor: "not-a-cursor"}); err == nil {
+		t.Error("Expected invalid cursor error")
+	}
+}
+
+// TestReplace test correcting a history record on every embedded backend
+func TestReplace(t *testing.T) {
+	eachBackend(t, func(t *testing.T, store Storage) {
+		// Chronological order, the last saved record is also the newest
+		prices := samplePrices()
+		prices[0], prices[1], prices[2] = prices[2], prices[0], prices[1]
+		for _, p := range prices {
+			store.Save(p)
+		}
+		corrector, ok := store.(Corrector)
+		if !ok {
+			t.Fatal("Storage does not support corrections")
+		}
+
+		// Latest record is the last one saved
+		updated := prices[2]
+		updated.Price = decimal.MustParse("69.95")
+		updated.Synthetic = true
+		updated.Quotes = []types.SourceQuote{{Source: "tradingeconomics", Price: decimal.MustParse("69.95"), FetchedAt: updated.LastUpdated, Synthetic: true}}
+		if err := corrector.Replace(prices[2], updated); err != nil {
+			t.Fatalf("Replace failed: %v", err)
+		}
+		if latest := store.GetLatest(); latest == nil || latest.Price != decimal.MustParse("69.95") || !latest.Synthetic || !latest.Quotes[0].Synthetic {
+			t.Errorf("Latest not corrected: %+v", latest)
+		}
+
+		history := store.GetHistory()
+		corrected := 0
+		for _, p := range history {
+			if p.Date == updated.Date && p.Price == decimal.MustParse("69.95") && len(p.Quotes) == 1 {
+				corrected++
+			}
+		}
+		if len(history) != 3 || corrected != 1 {
+			t.Errorf("History not corrected: %+v", history)
+		}
+
+		// Backfilled records join history but never become the latest price
+		backfilled := types.PriceInfo{Price: decimal.MustParse("68.50"), Date: "January 12, 2024", LastUpdated: prices[0].LastUpdated.Add(-72 * time.Hour), Source: "eex"}
+		if err := corrector.Backfill(backfilled); err != nil {
+			t.Fatalf("Backfill failed: %v", err)
+		}
+		if latest := store.GetLatest(); latest == nil || latest.Price != decimal.MustParse("69.95") || len(store.GetHistory()) != 4 {
+			t.Errorf("Backfill mismatch: latest %+v, history %+v", latest, store.GetHistory())
+		}
+
+		missing := prices[0]
+		missing.LastUpdated = missing.LastUpdated.Add(time.Minute)
+		if err := corrector.Replace(missing, updated); err != ErrRecordNotFound {
+			t.Errorf("Expected ErrRecordNotFound, got %v", err)
+		}
+	})
+}
+
+// TestQuarantine test holding rejected prices in every backend
+func TestQuarantine(t *testing.T) {
+	eachBackend(t, func(t *testing.T, store Storage) {
+		quarantiner, ok := store.(Quarantiner)
+		if !ok {
+			t.Fatal("Storage does not support quarantine")
+		}
+		for i, p := range samplePrices() {
+			entry, err := quarantiner.Quarantine(types.QuarantinedPrice{
+				PriceInfo:  p,
+				Violations: []types.Violation{{Rule: "jump", Message: "Price moved too much"}},
+			})
+			if err != nil {
+				t.Fatalf("Quarantine failed: %v", err)
+			}
+			if entry.ID != int64(i+1) || entry.Status != types.QuarantinePending || entry.QuarantinedAt.IsZero() {
+				t.Errorf("Unexpected entry %+v", entry)
+			}
+		}
+
+		entries, err := quarantiner.Quarantined("", 2)
+		if err != nil {
+			t.Fatalf("Quarantined failed: %v", err)
+		}
+		if len(entries) != 2 || entries[0].ID != 3 || entries[0].PriceInfo.Price != decimal.MustParse("69.90") || entries[0].Violations[0].Rule != "jump" {
+			t.Errorf("Expected the two newest entries, got %+v", entries)
+		}
+		if all, _ := quarantiner.Quarantined("", 0); len(all) != 3 {
+			t.Errorf("Expected 3 entries, got %d", len(all))
+		}
+		if store.GetLatest() != nil {
+			t.Error("Quarantined prices must not become the latest price")
+		}
+
+		// Decisions are recorded once with the operator and their comment
+		reviewed, err := quarantiner.Review(2, types.QuarantineRejected, "ops (k1)", "Misplaced decimal point")
+		if err != nil {
+			t.Fatalf("Review failed: %v", err)
+		}
+		if reviewed.Status != types.QuarantineRejected || reviewed.ReviewedAt == nil {
+			t.Errorf("Review not applied: %+v", reviewed)
+		}
+		if _, err := quarantiner.Review(2, types.QuarantineApproved, "ops (k1)", "Changed my mind"); err != ErrAlreadyReviewed {
+			t.Errorf("Expected ErrAlreadyReviewed, got %v", err)
+		}
+		if _, err := quarantiner.Review(1, "maybe", "ops (k1)", ""); err == nil {
+			t.Error("Expected error for an unknown review status")
+		}
+		if _, err := quarantiner.Review(9, types.QuarantineApproved, "ops (k1)", ""); err != ErrRecordNotFound {
+			t.Errorf("Expected ErrRecordNotFound, got %v", err)
+		}
+
+		entry, err := quarantiner.GetQuarantined(2)
+		if err != nil || entry.ReviewedBy != "ops (k1)" || entry.ReviewComment != "Misplaced decimal point" || entry.ReviewedAt == nil {
+			t.Errorf("Review not stored: %+v, %v", entry, err)
+		}
+		if _, err := quarantiner.GetQuarantined(9); err != ErrRecordNotFound {
+			t.Errorf("Expected ErrRecordNotFound, got %v", err)
+		}
+		pending, _ := quarantiner.Quarantined(types.QuarantinePending, 0)
+		if len(pending) != 2 || pending[0].ID != 3 || pending[1].ID != 1 {
+			t.Errorf("Expected entries 3 and 1 pending, got %+v", pending)
+		}
+
+		// A decision whose promotion failed is undone
+		if err := quarantiner.Reopen(2); err != nil {
+			t.Fatalf("Reopen failed: %v", err)
+		}
+		if entry, _ := quarantiner.GetQuarantined(2); entry.Status != types.QuarantinePending || entry.ReviewedBy != "" || entry.ReviewedAt != nil {
+			t.Errorf("Entry not reopened: %+v", entry)
+		}
+		if err := quarantiner.Reopen(9); err != ErrRecordNotFound {
+			t.Errorf("Expected ErrRecordNotFound, got %v", err)
+		}
+	})
+}
+
+// TestUpsertByTradingDate test one record per trading date with its status on every embedded backend
+func TestUpsertByTradingDate(t *testing.T) {
+	eachBackend(t, func(t *testing.T, store Storage) {
+		morning := time.Date(2024, 1, 17, 8, 0, 0, 0, time.UTC)
+		steps := []struct {
+			price  float64
+			at     time.Time
+			status string
+		}{
+			{71.20, morning, types.StatusUpdated},
+			{71.20, morning.Add(4 * time.Hour), types.StatusUnchanged},
+			{71.45, morning.Add(8 * time.Hour), types.StatusUpdated},
+		}
+		for _, step := range steps {
+			store.Save(types.PriceInfo{Price: decimal.FromFloat(step.price), Date: "January 17, 2024", LastUpdated: step.at, Source: "tradingeconomics"})
+			if latest := store.GetLatest(); latest == nil || latest.Status != step.status || !latest.LastUpdated.Equal(step.at) {
+				t.Errorf("Expected %s at %s, got %+v", step.status, step.at, latest)
+			}
+		}
+		history := store.GetHistory()
+		if len(history) != 1 || history[0].Price != decimal.MustParse("71.45") || history[0].Status != types.StatusUpdated {
+			t.Errorf("Expected a single record for the trading date, got %+v", history)
+		}
+
+		// Without a date, 23:30 UTC on Wednesday is already Thursday on the exchange
+		store.Save(types.PriceInfo{Price: decimal.MustParse("71.60"), LastUpdated: time.Date(2024, 1, 17, 23, 30, 0, 0, time.UTC)})
+		if history := store.GetHistory(); len(history) != 2 || !history[1].TradingDay().Equal(time.Date(2024, 1, 18, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected a record for January 18, got %+v", history)
+		}
+	})
+}
+
+// TestBackfillKeepsNewer test that a backfilled sample never replaces a later one of its trading date
+func TestBackfillKeepsNewer(t *testing.T) {
+	eachBackend(t, func(t *testing.T, store Storage) {
+		evening := time.Date(2024, 1, 17, 17, 0, 0, 0, time.UTC)
+		store.Save(types.PriceInfo{Price: decimal.MustParse("71.45"), Date: "January 17, 2024", LastUpdated: evening, Source: "tradingeconomics"})
+		corrector := store.(Corrector)
+
+		morning := types.PriceInfo{Price: decimal.MustParse("71.20"), Date: "January 17, 2024", LastUpdated: evening.Add(-8 * time.Hour), Source: "eex"}
+		if err := corrector.Backfill(morning); err != ErrNewerRecord {
+			t.Errorf("Expected ErrNewerRecord, got %v", err)
+		}
+		if history := store.GetHistory(); len(history) != 1 || history[0].Price != decimal.MustParse("71.45") {
+			t.Errorf("Older sample replaced the record: %+v", history)
+		}
+
+		// A later sample of the latest trading date replaces it in history and as the latest price
+		corrected := types.PriceInfo{Price: decimal.MustParse("71.50"), Date: "January 17, 2024", LastUpdated: evening, Source: "reparse"}
+		if err := corrector.Backfill(corrected); err != nil {
+			t.Fatalf("Backfill failed: %v", err)
+		}
+		history, latest := store.GetHistory(), store.GetLatest()
+		if len(history) != 1 || history[0].Price != corrected.Price || latest == nil || latest.Price != corrected.Price {
+			t.Errorf("History and latest disagree: %+v, %+v", history, latest)
+		}
+	})
+}
+
+// TestMemoryRetention test that memory keeps the most recent trading dates
+func TestMemoryRetention(t *testing.T) {
+	store := NewMemoryStorage()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for day := 0; day < memoryRetention+10; day++ {
+		date := start.AddDate(0, 0, day)
+		for _, hour := range []int{9, 17} {
+			store.Save(types.PriceInfo{Price: decimal.New(70, 0), Date: date.Format(types.DateLayout), LastUpdated: date.Add(time.Duration(hour) * time.Hour)})
+		}
+	}
+	// A late correction of a day that already left the window
+	store.(Corrector).Backfill(types.PriceInfo{Price: decimal.New(69, 0), Date: "January 2, 2024", LastUpdated: start})
+
+	history := store.GetHistory()
+	if len(history) != memoryRetention {
+		t.Fatalf("Expected %d trading days, got %d", memoryRetention, len(history))
+	}
+	if first := history[0].TradingDay(); !first.Equal(start.AddDate(0, 0, 10)) {
+		t.Errorf("Oldest kept trading date %s, expected %s", first, start.AddDate(0, 0, 10))
+	}
+}
+
+// TestMemoryQuarantineRetention test that memory drops reviewed entries first once the queue is full
+func TestMemoryQuarantineRetention(t *testing.T) {
+	store := NewMemoryStorage().(*MemoryStorage)
+	for i := 0; i < 3; i++ {
+		store.Quarantine(types.QuarantinedPrice{PriceInfo: samplePrices()[i]})
+	}
+	store.Review(2, types.QuarantineRejected, "ops", "Duplicate")
+	for i := 0; i < memoryQuarantineRetention-1; i++ {
+		store.Quarantine(types.QuarantinedPrice{PriceInfo: samplePrices()[0]})
+	}
+
+	entries, _ := store.Quarantined("", 0)
+	if len(entries) != memoryQuarantineRetention || entries[0].ID != memoryQuarantineRetention+2 {
+		t.Fatalf("Expected %d entries up to ID %d, got %d", memoryQuarantineRetention, memoryQuarantineRetention+2, len(entries))
+	}
+	for _, id := range []int64{1, 2} {
+		if _, err := store.GetQuarantined(id); err != ErrRecordNotFound {
+			t.Errorf("Entry %d should be dropped, got %v", id, err)
+		}
+	}
+	if entry, err := store.GetQuarantined(3); err != nil || entry.PriceInfo.Price != decimal.MustParse("69.90") {
+		t.Errorf("Entry 3 should be kept: %+v, %v", entry, err)
+	}
+}
+
+// TestCanonicalRecord test that every backend keeps the canonical fields of a record
+func TestCanonicalRecord(t *testing.T) {
+	eachBackend(t, func(t *testing.T, store Storage) {
+		store.Save(types.PriceInfo{
+			Price:       decimal.MustParse("71.35"),
+			Date:        "January 15, 2024",
+			LastUpdated: time.Date(2024, 1, 15, 17, 0, 0, 0, time.UTC),
+			Source:      "tradingeconomics",
+			Extractor:   "script",
+			Confidence:  "high",
+			Provenance:  map[string]types.Span{"price": {Text: "71.35 EUR", Start: 12, End: 21}},
+			Missing:     []string{"yearlyChange"},
+		})
+		latest := store.GetLatest()
+		if latest == nil {
+			t.Fatal("Latest price missing")
+		}
+		if latest.Version != types.PriceInfoVersion || latest.Instrument != types.CanonicalInstrument ||
+			latest.Currency != types.CanonicalCurrency || latest.Unit != types.CanonicalUnit || latest.TradingDate != "2024-01-15" {
+			t.Errorf("Record not normalized: %+v", latest)
+		}
+		if latest.Extractor != "script" || latest.Confidence != "high" || latest.Provenance["price"].End != 21 {
+			t.Errorf("Extraction details lost: %+v", latest)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package stream
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"backend/pkg/types"
+)
+
+// EventPrice event type of a stored price update
+const EventPrice = "price"
+
+// Event price update delivered to subscribers
+type Event struct {
+	ID   uint64          `json:"id"`   // Increasing event ID, used for resume
+	Type string          `json:"type"` // Event type
+	Time time.Time       `json:"time"` // Publish time
+	Data types.PriceInfo `json:"data"` // Published price
+}
+
+// Subscription live feed of one client. C is closed when the subscription ends;
+// Lagged reports whether it ended because the client could not keep up.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	broker *Broker
+	lagged atomic.Bool
+	once   sync.Once
+}
+
+// Lagged whether the subscription was dropped for falling behind
+func (s *Subscription) Lagged() bool {
+	return s.lagged.Load()
+}
+
+// Close unsubscribe, safe to call more than once
+func (s *Subscription) Close() {
+	s.broker.remove(s)
+}
+
+// Broker fan-out of price events with a replay buffer for resuming clients.
+// Publishing never blocks: a subscriber whose buffer is full is disconnected
+// and can resume from its last event ID while the event is still buffered.
+type Broker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	historySize int
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+
+	published int64
+	dropped   int64
+}
+
+// NewBroker create broker keeping historySize events for replay and
+// bufferSize undelivered events per subscriber
+func NewBroker(historySize, bufferSize int) *Broker {
+	if historySize < 1 {
+		historySize = 1
+	}
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+	return &Broker{
+		// Seed IDs with the boot time so they keep increasing across restarts
+		nextID:      uint64(time.Now().UnixMilli()) * 1000,
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish deliver a price update to every subscriber
+func (b *Broker) Publish(price types.PriceInfo) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event := Event{ID: b.nextID, Type: EventPrice, Time: time.Now(), Data: price}
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			// Slow client, disconnect instead of blocking every other subscriber
+			sub.lagged.Store(true)
+			b.closeLocked(sub)
+			atomic.AddInt64(&b.dropped, 1)
+		}
+	}
+	atomic.AddInt64(&b.published, 1)
+	return event
+}
+
+// Subscribe start a subscription. When lastEventID is non-zero the events published
+// after it are returned for replay; resumed is false when lastEventID is no longer
+// buffered (or unknown), in which case the client should reload the current state.
+func (b *Broker) Subscribe(lastEventID uint64) (sub *Subscription, replay []Event, resumed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, b.bufferSize)
+	sub = &Subscription{C: ch, ch: ch, broker: b}
+	b.subscribers[sub] = struct{}{}
+
+	if lastEventID == 0 {
+		return sub, nil, false
+	}
+	for i, event := range b.history {
+		if event.ID == lastEventID {
+			replay = append(replay, b.history[i+1:]...)
+			return sub, replay, true
+		}
+	}
+	return sub, nil, false
+}
+
+// Stats broker counters
+func (b *Broker) Stats() map[string]interface{} {
+	b.mu.Lock()
+	subscribers := len(b.subscribers)
+	b.mu.Unlock()
+	return map[string]interface{}{
+		"subscribers": subscribers,
+		"published":   atomic.LoadInt64(&b.published),
+		"dropped":     atomic.LoadInt64(&b.dropped),
+	}
+}
+
+// remove unsubscribe
+func (b *Broker) remove(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closeLocked(sub)
+}
+
+// closeLocked remove subscriber and close its channel, caller holds b.mu
+func (b *Broker) closeLocked(sub *Subscription) {
+	delete(b.subscribers, sub)
+	sub.once.Do(func() { close(sub.ch) })
+}
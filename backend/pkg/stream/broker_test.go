@@ -0,0 +1,66 @@
+package stream
+
+import (
+	"testing"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// TestBrokerResume test replay after a known event ID
+func TestBrokerResume(t *testing.T) {
+	broker := NewBroker(3, 4)
+	var ids []uint64
+	for _, price := range []float64{70, 71, 72, 73} {
+		ids = append(ids, broker.Publish(types.PriceInfo{Price: decimal.FromFloat(price)}).ID)
+	}
+
+	// ids[0] fell out of the 3 event replay buffer
+	sub, replay, resumed := broker.Subscribe(ids[0])
+	if resumed || len(replay) != 0 {
+		t.Errorf("Expected no resume from evicted event, got %v %+v", resumed, replay)
+	}
+	sub.Close()
+
+	sub, replay, resumed = broker.Subscribe(ids[1])
+	defer sub.Close()
+	if !resumed || len(replay) != 2 || replay[0].Data.Price != decimal.New(72, 0) || replay[1].Data.Price != decimal.New(73, 0) {
+		t.Fatalf("Unexpected replay: %v %+v", resumed, replay)
+	}
+
+	broker.Publish(types.PriceInfo{Price: decimal.New(74, 0)})
+	if event := <-sub.C; event.Data.Price != decimal.New(74, 0) || event.ID <= replay[1].ID {
+		t.Errorf("Unexpected live event: %+v", event)
+	}
+}
+
+// TestBrokerBackpressure test a slow subscriber is dropped without blocking others
+func TestBrokerBackpressure(t *testing.T) {
+	broker := NewBroker(10, 2)
+	slow, _, _ := broker.Subscribe(0)
+	fast, _, _ := broker.Subscribe(0)
+	defer fast.Close()
+
+	for i := 0; i < 3; i++ {
+		broker.Publish(types.PriceInfo{Price: decimal.New(int64(70+i), 0)})
+		<-fast.C
+	}
+
+	if !slow.Lagged() {
+		t.Error("Slow subscriber should be marked lagged")
+	}
+	count := 0
+	for range slow.C {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Slow subscriber should keep its 2 buffered events, got %d", count)
+	}
+	if fast.Lagged() {
+		t.Error("Fast subscriber should stay connected")
+	}
+	if stats := broker.Stats(); stats["subscribers"] != 1 || stats["dropped"] != int64(1) {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+	slow.Close()
+}
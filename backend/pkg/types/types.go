@@ -1,13 +1,158 @@
 package types
 
-import "time"
+import (
+	"strings"
+	"time"
+	_ "time/tzdata" // Exchange timezones on hosts without a zoneinfo database
 
-// PriceInfo price information struct
+	"backend/pkg/decimal"
+)
+
+// PriceInfoVersion schema version of PriceInfo, bumped when fields are added or change meaning
+const PriceInfoVersion = 2
+
+// What every record quotes. The service tracks the one EUA market and sources convert their
+// prices to EUR per tonne (see crawler priceUnits), so these are constants rather than source
+// settings; a second instrument needs its own history, consensus and oracle feed first.
+const (
+	CanonicalInstrument = "EUA"   // EU Allowance, one tonne of CO2 equivalent under the EU ETS
+	CanonicalCurrency   = "EUR"   // Euro
+	CanonicalUnit       = "tCO2e" // Tonne of CO2 equivalent
+)
+
+// PriceInfo canonical price record: returned by sources, stored, validated and served.
+// Records written before PriceInfoVersion are upgraded by Normalized.
 type PriceInfo struct {
-	Price         float64   `json:"price"`         // Price
-	Date          string    `json:"date"`          // Date
-	DailyChange   float64   `json:"dailyChange"`   // Daily change percentage
-	MonthlyChange float64   `json:"monthlyChange"` // Monthly change percentage
-	YearlyChange  float64   `json:"yearlyChange"`  // Yearly change percentage
-	LastUpdated   time.Time `json:"lastUpdated"`   // Last update time
+	Version    int    `json:"version,omitempty"`    // PriceInfoVersion of the record
+	Instrument string `json:"instrument,omitempty"` // Priced instrument, always CanonicalInstrument
+	Currency   string `json:"currency,omitempty"`   // ISO 4217 currency of Price, always CanonicalCurrency
+	Unit       string `json:"unit,omitempty"`       // Quantity one Price buys, always CanonicalUnit
+
+	// EEX European Carbon Index - EU carbon trading market standard carbon quota unit based on multiple EUA spot trading price index (reference average)
+	Price         decimal.Decimal `json:"price"`                 // Price
+	Date          string          `json:"date"`                  // Trading date as stated by the source, e.g. "January 15, 2024"
+	TradingDate   string          `json:"tradingDate,omitempty"` // Trading date in the exchange timezone, "2006-01-02"
+	DailyChange   decimal.Decimal `json:"dailyChange"`           // Daily change percentage
+	MonthlyChange decimal.Decimal `json:"monthlyChange"`         // Monthly change percentage
+	YearlyChange  decimal.Decimal `json:"yearlyChange"`          // Yearly change percentage
+	LastUpdated   time.Time       `json:"lastUpdated"`           // Fetch time
+	Status        string          `json:"status,omitempty"`      // StatusUpdated or StatusUnchanged versus the record it replaced
+	Source        string          `json:"source"`                // Price source name, "consensus" when aggregated
+	Extractor     string          `json:"extractor,omitempty"`   // Page extractor that found the price, e.g. script, table or meta
+	Confidence    string          `json:"confidence,omitempty"`  // Extraction confidence: high, medium or low
+	Synthetic     bool            `json:"synthetic,omitempty"`   // A quote comes from a synthetic source, not a market price
+
+	// Provenance text each field was read from, keyed by JSON field name; Missing lists the
+	// fields the source did not state, their values are zero but unknown
+	Provenance map[string]Span `json:"provenance,omitempty"`
+	Missing    []string        `json:"missing,omitempty"`
+
+	// Consensus details, filled when the price is aggregated from several sources
+	Quotes        []SourceQuote   `json:"quotes,omitempty"` // Per-source quotes used for the consensus
+	Spread        decimal.Decimal `json:"spread"`           // Max minus min quoted price
+	SpreadPercent decimal.Decimal `json:"spreadPercent"`    // Spread relative to the consensus price
+	Disagreement  bool            `json:"disagreement"`     // Sources disagree beyond the configured tolerance
+}
+
+// Span text a field was read from. Start and End are byte offsets into the parsed sentence,
+// structured extractors leave them zero and name the cell or key in Text.
+type Span struct {
+	Text  string `json:"text"`
+	Start int    `json:"start,omitempty"`
+	End   int    `json:"end,omitempty"`
+}
+
+// Normalized record upgraded to PriceInfoVersion: instrument, currency and unit are set to the
+// canonical EUA quote in EUR per tonne, and TradingDate is derived from Date and LastUpdated
+func (p PriceInfo) Normalized() PriceInfo {
+	p.Version = PriceInfoVersion
+	p.Instrument = CanonicalInstrument
+	p.Currency = CanonicalCurrency
+	p.Unit = CanonicalUnit
+	p.TradingDate = p.TradingDay().Format("2006-01-02")
+	return p
+}
+
+// SourceQuote price reported by a single source
+type SourceQuote struct {
+	Source    string          `json:"source"`          // Source name
+	Price     decimal.Decimal `json:"price"`           // Quoted price
+	Date      string          `json:"date"`            // Quoted trading date
+	FetchedAt time.Time       `json:"fetchedAt"`       // Fetch time
+	Error     string          `json:"error,omitempty"` // Fetch error, quote is ignored when set
+
+	Extractor  string `json:"extractor,omitempty"`  // Page extractor that found the price
+	Confidence string `json:"confidence,omitempty"` // Extraction confidence: high, medium or low
+	Synthetic  bool   `json:"synthetic,omitempty"`  // Generated by a synthetic source
+}
+
+// DateLayout layout of the Date field, e.g. "January 15, 2024"
+const DateLayout = "January 2, 2006"
+
+// dateLayouts accepted forms of the Date field
+var dateLayouts = []string{DateLayout, "Jan 2, 2006", "2006-01-02"}
+
+// Record status set by storage on save
+const (
+	StatusUpdated   = "updated"   // First record of the trading date, or its figures changed
+	StatusUnchanged = "unchanged" // Same figures as the stored record of the trading date
+)
+
+// ExchangeLocation timezone trading dates are counted in, EU carbon allowances trade on EEX in Leipzig
+var ExchangeLocation, _ = time.LoadLocation("Europe/Berlin")
+
+// TradingDay trading date of the record as a calendar day at midnight UTC. Date is read as a day on
+// the exchange; when it cannot be parsed the update time is taken in ExchangeLocation, with weekends
+// falling back to the Friday before.
+func (p PriceInfo) TradingDay() time.Time {
+	for _, layout := range dateLayouts {
+		if day, err := time.Parse(layout, strings.TrimSpace(p.Date)); err == nil {
+			return day
+		}
+	}
+	u := p.LastUpdated.In(ExchangeLocation)
+	day := time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.UTC)
+	switch day.Weekday() {
+	case time.Saturday:
+		return day.AddDate(0, 0, -1)
+	case time.Sunday:
+		return day.AddDate(0, 0, -2)
+	}
+	return day
+}
+
+// CrawlAttempt outcome of one fetch from one source
+type CrawlAttempt struct {
+	Source     string          `json:"source"`          // Source name
+	StartedAt  time.Time       `json:"startedAt"`       // Fetch start time
+	FinishedAt time.Time       `json:"finishedAt"`      // Fetch end time
+	Success    bool            `json:"success"`         // Whether a price was obtained
+	Price      decimal.Decimal `json:"price"`           // Fetched price when successful
+	Error      string          `json:"error,omitempty"` // Error message when failed
+}
+
+// Quarantine review states
+const (
+	QuarantinePending  = "pending"  // Waiting for review
+	QuarantineApproved = "approved" // Promoted into the price history
+	QuarantineRejected = "rejected" // Discarded, kept for the record
+)
+
+// Violation validation check a price failed
+type Violation struct {
+	Rule    string `json:"rule"`    // Check name, e.g. "jump"
+	Message string `json:"message"` // Why the price was rejected
+}
+
+// QuarantinedPrice price rejected by validation, held for review instead of being saved
+type QuarantinedPrice struct {
+	ID            int64       `json:"id"`
+	PriceInfo     PriceInfo   `json:"priceInfo"`     // Record as it would have been saved
+	Violations    []Violation `json:"violations"`    // Checks the record failed
+	QuarantinedAt time.Time   `json:"quarantinedAt"` // Rejection time
+	Status        string      `json:"status"`        // Review state, QuarantinePending until reviewed
+
+	ReviewedBy    string     `json:"reviewedBy,omitempty"`    // Operator who approved or rejected the price
+	ReviewedAt    *time.Time `json:"reviewedAt,omitempty"`    // Decision time
+	ReviewComment string     `json:"reviewComment,omitempty"` // Operator's reason for the decision
 }
@@ -0,0 +1,140 @@
+package validation
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"backend/pkg/types"
+)
+
+// Check names reported in violations
+const (
+	RuleBounds      = "bounds"
+	RuleJump        = "jump"
+	RuleDailyChange = "daily_change"
+	RuleDate        = "date"
+)
+
+// fieldDailyChange Missing entry of a source that did not state the daily change
+const fieldDailyChange = "dailyChange"
+
+// Config validation thresholds, a zero threshold disables its check
+type Config struct {
+	MinPrice        float64 // Lowest plausible price in EUR/t
+	MaxPrice        float64 // Highest plausible price in EUR/t
+	MaxJumpPercent  float64 // Largest move versus the last stored price, in percent
+	ChangeTolerance float64 // Allowed gap in percentage points between the scraped daily change and the one computed from history
+	MaxGapDays      int     // Daily change is only checked against a stored price at most this many days older
+}
+
+// DefaultConfig EU carbon prices between 1 and 500 EUR/t, moving at most 25% per update
+func DefaultConfig() Config {
+	return Config{
+		MinPrice:        1,
+		MaxPrice:        500,
+		MaxJumpPercent:  25,
+		ChangeTolerance: 1.5,
+		MaxGapDays:      4,
+	}
+}
+
+// Baseline stored prices a new record is compared with, both optional
+type Baseline struct {
+	Latest   *types.PriceInfo // Most recently stored price
+	Previous *types.PriceInfo // Last stored price of an earlier trading day
+}
+
+// check one validation rule, returns an empty message when the record passes
+type check struct {
+	rule  string
+	apply func(p types.PriceInfo, baseline Baseline, config Config, now time.Time) string
+}
+
+var checks = []check{
+	{RuleBounds, checkBounds},
+	{RuleJump, checkJump},
+	{RuleDailyChange, checkDailyChange},
+	{RuleDate, checkDate},
+}
+
+// Check run every check on a record about to be saved, nil when it passes
+func Check(p types.PriceInfo, baseline Baseline, config Config, now time.Time) []types.Violation {
+	var violations []types.Violation
+	for _, c := range checks {
+		if message := c.apply(p, baseline, config, now); message != "" {
+			violations = append(violations, types.Violation{Rule: c.rule, Message: message})
+		}
+	}
+	return violations
+}
+
+// checkBounds price within the plausible range
+func checkBounds(p types.PriceInfo, _ Baseline, config Config, _ time.Time) string {
+	switch {
+	case p.Price.Sign() <= 0:
+		return fmt.Sprintf("Price %.2f is not positive", p.Price)
+	case config.MinPrice > 0 && p.Price.Float64() < config.MinPrice:
+		return fmt.Sprintf("Price %.2f is below the minimum %.2f", p.Price, config.MinPrice)
+	case config.MaxPrice > 0 && p.Price.Float64() > config.MaxPrice:
+		return fmt.Sprintf("Price %.2f is above the maximum %.2f", p.Price, config.MaxPrice)
+	}
+	return ""
+}
+
+// checkJump move versus the last stored price
+func checkJump(p types.PriceInfo, baseline Baseline, config Config, _ time.Time) string {
+	if config.MaxJumpPercent <= 0 || baseline.Latest == nil || baseline.Latest.Price.Sign() <= 0 {
+		return ""
+	}
+	jump := (p.Price.Float64()/baseline.Latest.Price.Float64() - 1) * 100
+	if math.Abs(jump) > config.MaxJumpPercent {
+		return fmt.Sprintf("Price %.2f moved %+.2f%% from the last stored %.2f, more than %.2f%%",
+			p.Price, jump, baseline.Latest.Price, config.MaxJumpPercent)
+	}
+	return ""
+}
+
+// checkDailyChange scraped daily change against the change from the previous stored trading day
+func checkDailyChange(p types.PriceInfo, baseline Baseline, config Config, _ time.Time) string {
+	previous := baseline.Previous
+	if config.ChangeTolerance <= 0 || previous == nil || previous.Price.Sign() <= 0 || missing(p, fieldDailyChange) {
+		return ""
+	}
+	day, previousDay := p.TradingDay(), previous.TradingDay()
+	if !previousDay.Before(day) {
+		return ""
+	}
+	if config.MaxGapDays > 0 && day.Sub(previousDay) > time.Duration(config.MaxGapDays)*24*time.Hour {
+		return ""
+	}
+	computed := (p.Price.Float64()/previous.Price.Float64() - 1) * 100
+	if math.Abs(computed-p.DailyChange.Float64()) > config.ChangeTolerance {
+		return fmt.Sprintf("Daily change %+.2f%% differs from %+.2f%% computed against %.2f on %s",
+			p.DailyChange, computed, previous.Price, previous.Date)
+	}
+	return ""
+}
+
+// checkDate trading date neither before the last stored one nor in the future
+func checkDate(p types.PriceInfo, baseline Baseline, _ Config, now time.Time) string {
+	day := p.TradingDay()
+	if baseline.Latest != nil && day.Before(baseline.Latest.TradingDay()) {
+		return fmt.Sprintf("Trading date %s is before the last stored %s", day.Format("2006-01-02"), baseline.Latest.TradingDay().Format("2006-01-02"))
+	}
+	// A day of slack for exchanges ahead of UTC
+	if day.After(now.UTC().Add(24 * time.Hour)) {
+		return fmt.Sprintf("Trading date %s is in the future", day.Format("2006-01-02"))
+	}
+	return ""
+}
+
+// missing whether the source did not state field
+func missing(p types.PriceInfo, field string) bool {
+	for _, f := range p.Missing {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"backend/pkg/decimal"
+	"backend/pkg/types"
+)
+
+// TestCheck test each rule against a stored baseline
+func TestCheck(t *testing.T) {
+	now := time.Date(2024, 1, 17, 18, 0, 0, 0, time.UTC)
+	previous := types.PriceInfo{Price: decimal.MustParse("84.00"), Date: "January 16, 2024", LastUpdated: now.Add(-24 * time.Hour)}
+	latest := types.PriceInfo{Price: decimal.MustParse("85.10"), Date: "January 17, 2024", LastUpdated: now.Add(-time.Hour)}
+	baseline := Baseline{Latest: &latest, Previous: &previous}
+
+	tests := []struct {
+		name     string
+		price    types.PriceInfo
+		baseline Baseline
+		config   Config
+		want     []string
+	}{
+		{
+			name:     "plausible",
+			price:    types.PriceInfo{Price: decimal.MustParse("85.20"), Date: "January 17, 2024", DailyChange: decimal.MustParse("1.43")},
+			baseline: baseline,
+			config:   DefaultConfig(),
+		},
+		{
+			name:     "misplaced decimal point",
+			price:    types.PriceInfo{Price: decimal.MustParse("8.52"), Date: "January 17, 2024", DailyChange: decimal.MustParse("1.43")},
+			baseline: baseline,
+			config:   DefaultConfig(),
+			want:     []string{RuleJump, RuleDailyChange},
+		},
+		{
+			name:   "out of bounds without history",
+			price:  types.PriceInfo{Price: decimal.MustParse("852.0"), Date: "January 17, 2024"},
+			config: DefaultConfig(),
+			want:   []string{RuleBounds},
+		},
+		{
+			name:   "not positive",
+			price:  types.PriceInfo{Price: decimal.Zero, Date: "January 17, 2024"},
+			config: Config{},
+			want:   []string{RuleBounds},
+		},
+		{
+			name:     "daily change inconsistent with history",
+			price:    types.PriceInfo{Price: decimal.MustParse("85.20"), Date: "January 17, 2024", DailyChange: decimal.MustParse("-1.43")},
+			baseline: baseline,
+			config:   DefaultConfig(),
+			want:     []string{RuleDailyChange},
+		},
+		{
+			name:     "daily change not stated",
+			price:    types.PriceInfo{Price: decimal.MustParse("85.20"), Date: "January 17, 2024", Missing: []string{"dailyChange"}},
+			baseline: baseline,
+			config:   DefaultConfig(),
+		},
+		{
+			name:     "previous day too old",
+			price:    types.PriceInfo{Price: decimal.MustParse("85.20"), Date: "January 17, 2024", DailyChange: decimal.MustParse("0.1")},
+			baseline: Baseline{Previous: &types.PriceInfo{Price: decimal.MustParse("80.00"), Date: "January 10, 2024"}},
+			config:   DefaultConfig(),
+		},
+		{
+			name:     "date before the last stored",
+			price:    types.PriceInfo{Price: decimal.MustParse("85.20"), Date: "January 15, 2024"},
+			baseline: baseline,
+			config:   DefaultConfig(),
+			want:     []string{RuleDate},
+		},
+		{
+			name:   "date in the future",
+			price:  types.PriceInfo{Price: decimal.MustParse("85.20"), Date: "January 20, 2024"},
+			config: DefaultConfig(),
+			want:   []string{RuleDate},
+		},
+		{
+			name:     "checks disabled",
+			price:    types.PriceInfo{Price: decimal.MustParse("8.52"), Date: "January 17, 2024", DailyChange: decimal.MustParse("1.43")},
+			baseline: baseline,
+			config:   Config{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules []string
+			for _, v := range Check(tt.price, tt.baseline, tt.config, now) {
+				if v.Message == "" {
+					t.Errorf("Violation %s without message", v.Rule)
+				}
+				rules = append(rules, v.Rule)
+			}
+			if !reflect.DeepEqual(rules, tt.want) {
+				t.Errorf("Expected violations %v, got %v", tt.want, rules)
+			}
+		})
+	}
+}